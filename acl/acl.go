@@ -0,0 +1,257 @@
+// Package acl evaluates the key and channel permissions of Redis ACL rules,
+// for gateways that reimplement Redis access control:
+//
+//	perms, err := acl.Parse("on >secret ~cache:* %R~ro:* &events:* +@all")
+//	perms.CanRead("ro:config")      // true
+//	perms.CanWrite("ro:config")     // false
+//	perms.CanSubscribe("events:42") // true
+//
+// Patterns are compiled with redglob.ModeRedisBytes, so they match exactly
+// as in Redis. Rules that do not concern keys or channels, such as
+// passwords and command permissions, are accepted and ignored.
+package acl
+
+import (
+	"strings"
+
+	"github.com/maolonglong/redglob"
+	"github.com/maolonglong/redglob/internal/caret"
+)
+
+// ErrorCode describes why a rule was rejected.
+type ErrorCode string
+
+// Error codes reported by Parse and Permissions.Apply.
+const (
+	// ErrUnknownRule reports a rule Redis does not know.
+	ErrUnknownRule ErrorCode = "unknown rule"
+	// ErrInvalidPermission reports a '%' rule with flags other than R and
+	// W, with a repeated flag, or without the '~' before the pattern, and
+	// a key rule with an empty pattern, such as "~" or "%R~".
+	ErrInvalidPermission ErrorCode = "invalid key permission"
+	// ErrPatternAfterAll reports a key pattern after allkeys or "~*", or a
+	// channel pattern after allchannels or "&*", which Redis rejects
+	// because it has no effect. resetkeys and resetchannels start over.
+	ErrPatternAfterAll ErrorCode = "pattern after allkeys or allchannels"
+	// ErrSelector reports a "(...)" selector, which is not supported.
+	ErrSelector ErrorCode = "selectors are not supported"
+)
+
+// SyntaxError describes a rejected rule and where it starts.
+type SyntaxError struct {
+	Code   ErrorCode
+	Rules  string
+	Offset int // byte offset of the offending rule
+	Column int // 1-based rune column of the offending rule
+}
+
+// Error formats the error on three lines: the message, the rules, and a
+// caret under the offending rule.
+func (e *SyntaxError) Error() string {
+	return caret.Format("acl", string(e.Code), e.Rules, e.Offset)
+}
+
+// Permissions holds the key and channel patterns of a Redis ACL user. The
+// zero value grants no access, like a new user. A Permissions is safe for
+// concurrent reads, but not for reads concurrent with Apply.
+type Permissions struct {
+	allKeys     bool
+	allChannels bool
+	keys        []keyPattern
+	channels    []channelPattern
+}
+
+type keyPattern struct {
+	source      string
+	pattern     *redglob.Pattern
+	read, write bool
+}
+
+type channelPattern struct {
+	source  string
+	pattern *redglob.Pattern
+}
+
+// Parse returns the permissions granted by rules, a space-separated list as
+// given to ACL SETUSER.
+func Parse(rules string) (*Permissions, error) {
+	p := &Permissions{}
+	if err := p.Apply(rules); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Apply applies rules on top of p, from left to right, as ACL SETUSER does
+// for an existing user. If a rule is rejected, Apply returns a *SyntaxError
+// pointing at it and leaves p unchanged.
+func (p *Permissions) Apply(rules string) error {
+	next := p.clone()
+	for offset := 0; offset < len(rules); {
+		if rules[offset] == ' ' {
+			offset++
+			continue
+		}
+		end := strings.IndexByte(rules[offset:], ' ')
+		if end < 0 {
+			end = len(rules)
+		} else {
+			end += offset
+		}
+		if code := next.apply(rules[offset:end]); code != "" {
+			return &SyntaxError{Code: code, Rules: rules, Offset: offset, Column: caret.Column(rules, offset)}
+		}
+		offset = end
+	}
+	*p = *next
+	return nil
+}
+
+func (p *Permissions) clone() *Permissions {
+	next := *p
+	next.keys = append([]keyPattern(nil), p.keys...)
+	next.channels = append([]channelPattern(nil), p.channels...)
+	return &next
+}
+
+// apply applies a single rule.
+func (p *Permissions) apply(rule string) ErrorCode {
+	switch strings.ToLower(rule) {
+	case "allkeys", "~*":
+		p.allKeys, p.keys = true, nil
+		return ""
+	case "resetkeys":
+		p.allKeys, p.keys = false, nil
+		return ""
+	case "allchannels", "&*":
+		p.allChannels, p.channels = true, nil
+		return ""
+	case "resetchannels":
+		p.allChannels, p.channels = false, nil
+		return ""
+	case "reset":
+		*p = Permissions{}
+		return ""
+	case "on", "off", "nopass", "resetpass", "allcommands", "nocommands",
+		"sanitize-payload", "skip-sanitize-payload", "clearselectors":
+		return ""
+	}
+	switch rule[0] {
+	case '~':
+		if len(rule) == 1 {
+			return ErrInvalidPermission
+		}
+		return p.addKey(rule[1:], true, true)
+	case '%':
+		flags, pattern, ok := strings.Cut(rule[1:], "~")
+		if !ok || flags == "" || pattern == "" {
+			return ErrInvalidPermission
+		}
+		var read, write bool
+		for _, flag := range flags {
+			switch {
+			case (flag == 'R' || flag == 'r') && !read:
+				read = true
+			case (flag == 'W' || flag == 'w') && !write:
+				write = true
+			default:
+				// Redis rejects an unknown or repeated flag.
+				return ErrInvalidPermission
+			}
+		}
+		return p.addKey(pattern, read, write)
+	case '&':
+		return p.addChannel(rule[1:])
+	case '>', '<', '#', '!', '+', '-':
+		// Passwords and commands.
+		return ""
+	case '(':
+		return ErrSelector
+	}
+	return ErrUnknownRule
+}
+
+func (p *Permissions) addKey(pattern string, read, write bool) ErrorCode {
+	if p.allKeys {
+		return ErrPatternAfterAll
+	}
+	if pattern == "*" && read && write {
+		p.allKeys, p.keys = true, nil
+		return ""
+	}
+	p.keys = append(p.keys, keyPattern{source: pattern, pattern: compile(pattern), read: read, write: write})
+	return ""
+}
+
+func (p *Permissions) addChannel(pattern string) ErrorCode {
+	if p.allChannels {
+		return ErrPatternAfterAll
+	}
+	if pattern == "*" {
+		p.allChannels, p.channels = true, nil
+		return ""
+	}
+	p.channels = append(p.channels, channelPattern{source: pattern, pattern: compile(pattern)})
+	return ""
+}
+
+// compile compiles a key or channel pattern of a rule. Redis stores rule
+// patterns unchecked, and a ModeRedisBytes compile returns no error.
+func compile(pattern string) *redglob.Pattern {
+	p, _ := redglob.CompileOptions{Mode: redglob.ModeRedisBytes}.Compile(pattern)
+	return p
+}
+
+// CanRead reports whether a command may read key.
+func (p *Permissions) CanRead(key string) bool {
+	return p.canAccess(key, true, false)
+}
+
+// CanWrite reports whether a command may write key.
+func (p *Permissions) CanWrite(key string) bool {
+	return p.canAccess(key, false, true)
+}
+
+// canAccess reports whether one pattern grants every requested access, as
+// Redis requires a single pattern to cover a key for each flag it checks.
+func (p *Permissions) canAccess(key string, read, write bool) bool {
+	if p.allKeys {
+		return true
+	}
+	for _, k := range p.keys {
+		if (!read || k.read) && (!write || k.write) && k.pattern.Match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanSubscribe reports whether the user may SUBSCRIBE to, or PUBLISH on,
+// channel: whether a channel pattern matches it.
+func (p *Permissions) CanSubscribe(channel string) bool {
+	if p.allChannels {
+		return true
+	}
+	for _, c := range p.channels {
+		if c.pattern.Match(channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanPSubscribe reports whether the user may PSUBSCRIBE to pattern. As in
+// Redis, the pattern must equal one of the allowed channel patterns
+// exactly, since a pattern that merely matches them could match other
+// channels too; with allchannels every pattern is allowed.
+func (p *Permissions) CanPSubscribe(pattern string) bool {
+	if p.allChannels {
+		return true
+	}
+	for _, c := range p.channels {
+		if c.source == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package acl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	perms, err := Parse("on >secret ~cache:* %R~ro:* %W~wo:* &events:* +@all -flushall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key         string
+		read, write bool
+	}{
+		{"cache:1", true, true},
+		{"ro:config", true, false},
+		{"wo:log", false, true},
+		{"other", false, false},
+		{"CACHE:1", false, false},
+	}
+	for _, tt := range tests {
+		if got := perms.CanRead(tt.key); got != tt.read {
+			t.Errorf("CanRead(%q) = %v, want %v", tt.key, got, tt.read)
+		}
+		if got := perms.CanWrite(tt.key); got != tt.write {
+			t.Errorf("CanWrite(%q) = %v, want %v", tt.key, got, tt.write)
+		}
+	}
+	if !perms.CanSubscribe("events:42") || perms.CanSubscribe("news") {
+		t.Error("CanSubscribe")
+	}
+	if !perms.CanPSubscribe("events:*") || perms.CanPSubscribe("events:4*") || perms.CanPSubscribe("*") {
+		t.Error("CanPSubscribe must require a literally equal pattern")
+	}
+}
+
+func TestAllAndReset(t *testing.T) {
+	perms, err := Parse("allkeys allchannels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !perms.CanRead("x") || !perms.CanWrite("x") || !perms.CanSubscribe("x") || !perms.CanPSubscribe("x*") {
+		t.Error("allkeys allchannels does not grant everything")
+	}
+	if err := perms.Apply("resetkeys ~a:* resetchannels &b"); err != nil {
+		t.Fatal(err)
+	}
+	if perms.CanRead("x") || !perms.CanRead("a:1") || perms.CanSubscribe("x") || !perms.CanSubscribe("b") {
+		t.Error("resetkeys and resetchannels did not start over")
+	}
+	if err := perms.Apply("~*"); err != nil || !perms.CanWrite("x") {
+		t.Errorf("~* = %v", err)
+	}
+	if err := perms.Apply("reset"); err != nil || perms.CanRead("x") || perms.CanSubscribe("b") {
+		t.Errorf("reset = %v", err)
+	}
+	// %R~* grants reading only, so it is not allkeys.
+	perms, _ = Parse("%R~*")
+	if !perms.CanRead("x") || perms.CanWrite("x") {
+		t.Error("%R~* grants writing")
+	}
+	if (&Permissions{}).CanRead("x") {
+		t.Error("the zero Permissions grants access")
+	}
+}
+
+func TestRedisPatterns(t *testing.T) {
+	// Patterns match as in Redis, including an unclosed '['.
+	perms, err := Parse(`~user:[0-9 ~a\`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !perms.CanRead("user:5") || !perms.CanRead(`a\`) {
+		t.Error("patterns do not match as in Redis")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		rules  string
+		code   ErrorCode
+		offset int
+	}{
+		{"~a bogus", ErrUnknownRule, 3},
+		{"%X~a", ErrInvalidPermission, 0},
+		{"on %R", ErrInvalidPermission, 3},
+		{"%~a", ErrInvalidPermission, 0},
+		{"~a ~", ErrInvalidPermission, 3},
+		{"%R~", ErrInvalidPermission, 0},
+		{"%RR~x", ErrInvalidPermission, 0},
+		{"%RwW~x", ErrInvalidPermission, 0},
+		{"allkeys ~a", ErrPatternAfterAll, 8},
+		{"~*  %R~a", ErrPatternAfterAll, 4},
+		{"&* &a", ErrPatternAfterAll, 3},
+		{"~a (~b)", ErrSelector, 3},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.rules)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Code != tt.code || syntaxErr.Offset != tt.offset {
+			t.Errorf("Parse(%q) = %v, want %s at %d", tt.rules, err, tt.code, tt.offset)
+		}
+	}
+	perms, _ := Parse("~a")
+	if err := perms.Apply("~b bogus"); err == nil || perms.CanRead("b") {
+		t.Error("a failed Apply changed the permissions")
+	}
+	_, err := Parse("~a nope")
+	if want := "acl: unknown rule at column 4\n\t~a nope\n\t   ^"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func BenchmarkCanRead(b *testing.B) {
+	perms, _ := Parse("~cache:* ~session:* %R~ro:* ~user:[0-9]*:profile")
+	for b.Loop() {
+		perms.CanRead("user:42:profile")
+	}
+}
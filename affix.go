@@ -0,0 +1,7 @@
+//go:build !go1.27 || !goexperiment.simd
+
+package redglob
+
+func equalAffix(a, b string) bool {
+	return a == b
+}
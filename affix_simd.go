@@ -0,0 +1,43 @@
+//go:build go1.27 && goexperiment.simd
+
+package redglob
+
+import (
+	"simd"
+	"unsafe"
+)
+
+const minSIMDAffixLength = 64
+
+// equalAffix reports whether a and b, which have the same length, are equal.
+func equalAffix(a, b string) bool {
+	if len(a) < minSIMDAffixLength {
+		return a == b
+	}
+	return equalAffixSIMD(a, b)
+}
+
+// equalAffixSIMD ORs together the XOR of each pair of vectors and checks
+// the result once, so the loop has no branch on the data.
+func equalAffixSIMD(a, b string) bool {
+	aBytes := unsafe.Slice(unsafe.StringData(a), len(a))
+	bBytes := unsafe.Slice(unsafe.StringData(b), len(b))
+
+	var diff simd.Uint8s
+	width := diff.Len()
+	if width > 64 || width%8 != 0 {
+		return a == b
+	}
+	consumed := 0
+	for ; consumed+width <= len(aBytes); consumed += width {
+		diff = diff.Or(simd.LoadUint8s(aBytes[consumed:]).Xor(simd.LoadUint8s(bBytes[consumed:])))
+	}
+	var words [8]uint64
+	diff.ReshapeToUint64s().Store(words[:width/8])
+	for _, word := range words[:width/8] {
+		if word != 0 {
+			return false
+		}
+	}
+	return a[consumed:] == b[consumed:]
+}
@@ -0,0 +1,139 @@
+package redglob
+
+import "strings"
+
+// MatchMany matches every string of strs against the pattern and records
+// the results in out as a bitset: bit i%64 of out[i/64] is set if strs[i]
+// matches and cleared otherwise. It returns the number of matches. out must
+// hold at least (len(strs)+63)/64 words; MatchMany panics otherwise.
+//
+// The comparison that Match would choose for the pattern is chosen once for
+// the whole batch. Prefix and suffix patterns such as "user:*" compare the
+// first and last eight bytes of each key as single words, then the rest of
+// the prefix and suffix. When built with GOEXPERIMENT=simd, a prefix or
+// suffix of 64 bytes or more is compared with the simd package, as
+// MatchFold compares long ASCII literals.
+func (p *Pattern) MatchMany(strs []string, out []uint64) int {
+	return p.matchMany(strs, out, false)
+}
+
+// MatchManyFold is like MatchMany for MatchFold.
+func (p *Pattern) MatchManyFold(strs []string, out []uint64) int {
+	return p.matchMany(strs, out, true)
+}
+
+// MatchManyBytes is like MatchMany for byte slices.
+func (p *Pattern) MatchManyBytes(bs [][]byte, out []uint64) int {
+	return p.matchManyBytes(bs, out, false)
+}
+
+// MatchManyBytesFold is like MatchManyBytes for MatchFold.
+func (p *Pattern) MatchManyBytesFold(bs [][]byte, out []uint64) int {
+	return p.matchManyBytes(bs, out, true)
+}
+
+func (p *Pattern) matchMany(strs []string, out []uint64, fold bool) int {
+	out = out[:(len(strs)+63)/64]
+	clear(out)
+	match := p.batchFunc(fold)
+	count := 0
+	for i, str := range strs {
+		if match(str) {
+			out[i/64] |= 1 << (i % 64)
+			count++
+		}
+	}
+	return count
+}
+
+func (p *Pattern) matchManyBytes(bs [][]byte, out []uint64, fold bool) int {
+	out = out[:(len(bs)+63)/64]
+	clear(out)
+	match := p.batchFunc(fold)
+	count := 0
+	for i, b := range bs {
+		if match(b2s(b)) {
+			out[i/64] |= 1 << (i % 64)
+			count++
+		}
+	}
+	return count
+}
+
+// batchFunc returns the comparison match makes for the pattern, with the
+// choice between the fast paths and the token walk made up front.
+func (p *Pattern) batchFunc(fold bool) func(string) bool {
+	switch {
+	case p == nil || !p.valid:
+		return func(string) bool { return false }
+	case p.mode == ModeRedisBytes || p.segments != nil || p.prog != nil:
+		return func(str string) bool { return p.match(str, fold) }
+	case p.simple && !p.hasStar && fold:
+		literal := p.prefix
+		return func(str string) bool { return matchLiteralFold(str, literal) }
+	case p.simple && !p.hasStar:
+		literal := p.prefix
+		return func(str string) bool { return str == literal }
+	case p.simple && fold:
+		prefix, suffix := p.prefix, p.suffix
+		return func(str string) bool { return matchSimpleFold(str, prefix, suffix) }
+	case p.simple:
+		return newAffixMatcher(p.prefix, p.suffix).match
+	case p.literalStars && fold:
+		text := p.prefix
+		return func(str string) bool { return matchLiteralStarsValidFold(str, text) }
+	case p.literalStars:
+		text := p.prefix
+		return func(str string) bool { return matchLiteralStarsValid(str, text) }
+	default:
+		return func(str string) bool { return p.match(str, fold) }
+	}
+}
+
+// affixMatcher is matchSimple with the first eight bytes of the prefix and
+// the last eight bytes of the suffix kept as masked words, so that most keys
+// are rejected by two loads and compares.
+type affixMatcher struct {
+	prefix, suffix string
+
+	prefixWord, prefixMask uint64
+	suffixWord, suffixMask uint64
+}
+
+func newAffixMatcher(prefix, suffix string) *affixMatcher {
+	m := &affixMatcher{prefix: prefix, suffix: suffix}
+	for i := range min(len(prefix), 8) {
+		m.prefixWord |= uint64(prefix[i]) << (8 * i)
+		m.prefixMask |= 0xff << (8 * i)
+	}
+	n := min(len(suffix), 8)
+	for i := range n {
+		shift := 8 * (8 - n + i)
+		m.suffixWord |= uint64(suffix[len(suffix)-n+i]) << shift
+		m.suffixMask |= 0xff << shift
+	}
+	return m
+}
+
+func (m *affixMatcher) match(str string) bool {
+	if len(str) < len(m.prefix)+len(m.suffix) {
+		return false
+	}
+	if len(str) < 8 {
+		return strings.HasPrefix(str, m.prefix) && strings.HasSuffix(str, m.suffix)
+	}
+	if loadWord(str)&m.prefixMask != m.prefixWord ||
+		loadWord(str[len(str)-8:])&m.suffixMask != m.suffixWord {
+		return false
+	}
+	return (len(m.prefix) <= 8 || equalAffix(str[8:len(m.prefix)], m.prefix[8:])) &&
+		(len(m.suffix) <= 8 || equalAffix(str[len(str)-len(m.suffix):len(str)-8], m.suffix[:len(m.suffix)-8]))
+}
+
+// loadWord returns the first eight bytes of s as a little-endian word, which
+// the compiler turns into a single load.
+func loadWord(s string) uint64 {
+	_ = s[7]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
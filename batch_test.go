@@ -0,0 +1,159 @@
+package redglob
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestMatchManyAgreesWithMatch(t *testing.T) {
+	r := rand.New(rand.NewPCG(13, 14))
+	random := func(alphabet []string, n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteString(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	patternAlphabet := []string{"a", "B", "*", "?", "[a-b]", "é", "user:", "ABCDEFGHIJ"}
+	strAlphabet := []string{"a", "b", "A", "É", "é", "user:", "abcdefghij", "\xff"}
+	for range 2000 {
+		pattern := random(patternAlphabet, 5)
+		p := Compile(pattern)
+		strs := make([]string, r.IntN(200))
+		bs := make([][]byte, len(strs))
+		for i := range strs {
+			strs[i] = random(strAlphabet, 6)
+			bs[i] = []byte(strs[i])
+		}
+		for _, fold := range []bool{false, true} {
+			out := make([]uint64, (len(strs)+63)/64+1)
+			for i := range out {
+				out[i] = ^uint64(0)
+			}
+			var count, countBytes int
+			outBytes := make([]uint64, len(out))
+			if fold {
+				count, countBytes = p.MatchManyFold(strs, out), p.MatchManyBytesFold(bs, outBytes)
+			} else {
+				count, countBytes = p.MatchMany(strs, out), p.MatchManyBytes(bs, outBytes)
+			}
+			want := 0
+			for i, str := range strs {
+				match := p.Match(str)
+				if fold {
+					match = p.MatchFold(str)
+				}
+				if match {
+					want++
+				}
+				if got := out[i/64]>>(i%64)&1 == 1; got != match {
+					t.Fatalf("Compile(%q) fold=%v: bit for %q = %v, want %v", pattern, fold, str, got, match)
+				}
+				if got := outBytes[i/64]>>(i%64)&1 == 1; got != match {
+					t.Fatalf("Compile(%q) fold=%v: bytes bit for %q = %v, want %v", pattern, fold, str, got, match)
+				}
+			}
+			ones := 0
+			for _, word := range out[:(len(strs)+63)/64] {
+				ones += bits.OnesCount64(word)
+			}
+			if count != want || countBytes != want || ones != want {
+				t.Fatalf("Compile(%q) fold=%v: count = %d, %d with %d bits set, want %d", pattern, fold, count, countBytes, ones, want)
+			}
+			if out[len(out)-1] != ^uint64(0) {
+				t.Fatalf("Compile(%q): word past the batch was written", pattern)
+			}
+		}
+	}
+}
+
+func TestAffixMatcher(t *testing.T) {
+	tests := []struct {
+		prefix, suffix, str string
+		want                bool
+	}{
+		{"user:", "", "user:42", true},
+		{"user:", "", "usex:42", false},
+		{"", ":profile", "user:42:profile", true},
+		{"", ":profile", "user:42:profilx", false},
+		{"tenant:acme:", ":profile", "tenant:acme:42:profile", true},
+		{"tenant:acme:", ":profile", "tenant:acmx:42:profile", false},
+		{"tenant:acme:", ":profile", "tenant:acme:profile", false},
+		{"tenant:acme:", "-suffix-of-length", "tenant:acme:x-suffix-of-length", true},
+		{"tenant:acme:", "-suffix-of-length", "tenant:acme:x-suffiz-of-length", false},
+		{"ab", "ba", "aba", false},
+		{"ab", "ba", "abba", true},
+		{"", "", "", true},
+	}
+	for _, tt := range tests {
+		if got := newAffixMatcher(tt.prefix, tt.suffix).match(tt.str); got != tt.want {
+			t.Errorf("affixMatcher(%q, %q).match(%q) = %v, want %v", tt.prefix, tt.suffix, tt.str, got, tt.want)
+		}
+	}
+	// Long affixes take the simd path under GOEXPERIMENT=simd; change each
+	// byte in turn, including those past the last full vector.
+	long := strings.Repeat("region-eu-west:", 10)
+	m := newAffixMatcher(long, long)
+	if !m.match(long + "x" + long) {
+		t.Errorf("affixMatcher(%q, %q) rejected a match", long, long)
+	}
+	for i := range long {
+		changed := long[:i] + "\x00" + long[i+1:]
+		if m.match(changed+"x"+long) || m.match(long+"x"+changed) {
+			t.Errorf("affixMatcher(%q, %q) ignored byte %d", long, long, i)
+		}
+	}
+}
+
+func TestMatchManyShortOut(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MatchMany with a short bitset did not panic")
+		}
+	}()
+	Compile("*").MatchMany(make([]string, 65), make([]uint64, 1))
+}
+
+// BenchmarkMatchMany compares the word compare of MatchMany with the byte
+// compare of matchSimple, and with calling Match per key.
+func BenchmarkMatchMany(b *testing.B) {
+	for _, affix := range []struct {
+		name   string
+		prefix string
+	}{
+		{"Short", "tenant:acme:"},
+		{"Long", "tenant:acme:" + strings.Repeat("region-eu-west:", 8)},
+	} {
+		strs := make([]string, 4096)
+		for i := range strs {
+			strs[i] = affix.prefix + "user:" + strings.Repeat("x", i%16) + ":profile"
+			if i%3 == 0 {
+				strs[i] = affix.prefix + "user:" + strings.Repeat("x", i%16) + ":session"
+			}
+		}
+		out := make([]uint64, len(strs)/64)
+		p := Compile(affix.prefix + "*:profile")
+		b.Run(affix.name+"/MatchMany", func(b *testing.B) {
+			for b.Loop() {
+				p.MatchMany(strs, out)
+			}
+		})
+		prefix, suffix := p.prefix, p.suffix
+		b.Run(affix.name+"/Bytes", func(b *testing.B) {
+			for b.Loop() {
+				for _, str := range strs {
+					matchSimple(str, prefix, suffix)
+				}
+			}
+		})
+		b.Run(affix.name+"/Match", func(b *testing.B) {
+			for b.Loop() {
+				for _, str := range strs {
+					p.Match(str)
+				}
+			}
+		})
+	}
+}
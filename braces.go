@@ -0,0 +1,513 @@
+package redglob
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// alternation holds the alternatives of a tokenAlt, each a token sequence
+// that may itself contain alternations. numeric marks a {lo..hi} range,
+// whose alternatives are generated digit classes.
+type alternation struct {
+	alts    [][]token
+	numeric bool
+}
+
+// group compiles the brace group whose '{' is at open and returns it as a
+// tokenAlt with the offset just past its '}'. A group without commas that
+// contains ".." is a numeric range.
+func (t *tokenizer) group(open int) (token, int, *SyntaxError) {
+	t.grouped = true
+	var alts [][]token
+	pos := open + 1
+	for {
+		seq, end, err := t.sequence(pos, true)
+		if err != nil {
+			return token{}, end, err
+		}
+		if end == len(t.source) {
+			return token{}, end, newSyntaxError(ErrUnterminatedBrace, t.source, open)
+		}
+		if len(alts) == 0 && t.source[end] == '}' {
+			if body := t.source[open+1 : end]; strings.Contains(body, "..") {
+				alts, ok := numericRange(body)
+				if !ok {
+					return token{}, end, newSyntaxError(ErrInvalidRange, t.source, open)
+				}
+				return token{kind: tokenAlt, alt: &alternation{alts: alts, numeric: true}}, end + 1, nil
+			}
+		}
+		alts = append(alts, seq)
+		pos = end + 1
+		if t.source[end] == '}' {
+			return token{kind: tokenAlt, alt: &alternation{alts: alts}}, pos, nil
+		}
+	}
+}
+
+// numericRange compiles "lo..hi" into alternatives of digit classes, one per
+// run of numbers sharing a digit pattern, so "{1..12}" becomes
+// [1-9] | 1[0-2]. As in shells, the numbers are zero-padded to the longer
+// endpoint if either endpoint has a leading zero, and the endpoints may be
+// in either order.
+func numericRange(body string) ([][]token, bool) {
+	loText, hiText, _ := strings.Cut(body, "..")
+	for _, text := range []string{loText, hiText} {
+		if text == "" || strings.Trim(text, "0123456789") != "" {
+			return nil, false
+		}
+	}
+	lo, errLo := strconv.ParseUint(loText, 10, 64)
+	hi, errHi := strconv.ParseUint(hiText, 10, 64)
+	if errLo != nil || errHi != nil {
+		return nil, false
+	}
+	lo, hi = min(lo, hi), max(lo, hi)
+	padded := len(loText) > 1 && loText[0] == '0' || len(hiText) > 1 && hiText[0] == '0'
+	var ranges [][]charRange
+	if padded {
+		width := max(len(loText), len(hiText))
+		ranges = digitRanges(pad(lo, width), pad(hi, width))
+	} else {
+		loDigits, hiDigits := strconv.FormatUint(lo, 10), strconv.FormatUint(hi, 10)
+		for n := len(loDigits); n <= len(hiDigits); n++ {
+			a, b := "1"+strings.Repeat("0", n-1), strings.Repeat("9", n)
+			if n == len(loDigits) {
+				a = loDigits
+			}
+			if n == len(hiDigits) {
+				b = hiDigits
+			}
+			ranges = append(ranges, digitRanges(a, b)...)
+		}
+	}
+	alts := make([][]token, len(ranges))
+	for i, positions := range ranges {
+		alts[i] = digitTokens(positions)
+	}
+	return alts, true
+}
+
+func pad(n uint64, width int) string {
+	s := strconv.FormatUint(n, 10)
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// digitRanges splits the decimal strings from a to b, both of the same
+// length, into sequences of per-position digit ranges.
+func digitRanges(a, b string) [][]charRange {
+	if a == "" {
+		return [][]charRange{nil}
+	}
+	first := charRange{rune(a[0]), rune(a[0])}
+	if a[0] == b[0] {
+		out := digitRanges(a[1:], b[1:])
+		for i := range out {
+			out[i] = append([]charRange{first}, out[i]...)
+		}
+		return out
+	}
+	zeros, nines := strings.Repeat("0", len(a)-1), strings.Repeat("9", len(a)-1)
+	var out [][]charRange
+	midLo, midHi := rune(a[0]), rune(b[0])
+	if a[1:] != zeros {
+		for _, rest := range digitRanges(a[1:], nines) {
+			out = append(out, append([]charRange{first}, rest...))
+		}
+		midLo++
+	}
+	var tail [][]charRange
+	if b[1:] != nines {
+		last := charRange{rune(b[0]), rune(b[0])}
+		for _, rest := range digitRanges(zeros, b[1:]) {
+			tail = append(tail, append([]charRange{last}, rest...))
+		}
+		midHi--
+	}
+	if midLo <= midHi {
+		mid := []charRange{{midLo, midHi}}
+		for range len(a) - 1 {
+			mid = append(mid, charRange{'0', '9'})
+		}
+		out = append(out, mid)
+	}
+	return append(out, tail...)
+}
+
+// digitTokens turns per-position digit ranges into tokens, merging fixed
+// digits into literals.
+func digitTokens(positions []charRange) []token {
+	var tokens []token
+	var lit []byte
+	flush := func() {
+		switch len(lit) {
+		case 0:
+		case 1:
+			tokens = append(tokens, token{kind: tokenLiteral, char: rune(lit[0])})
+		default:
+			tokens = append(tokens, token{kind: tokenLiteralRun, lit: string(lit)})
+		}
+		lit = lit[:0]
+	}
+	for _, r := range positions {
+		if r.start == r.end {
+			lit = append(lit, byte(r.start))
+			continue
+		}
+		flush()
+		class := &compiledClass{}
+		class.addRange(newCharRange(r.start, r.end))
+		addClassRangeBits(class, r.start, r.end)
+		tokens = append(tokens, token{kind: tokenClass, class: class})
+	}
+	flush()
+	return tokens
+}
+
+type instOp uint8
+
+const (
+	instRune  instOp = iota // consume one rune matching tok
+	instSplit               // continue at both x and y
+	instJump                // continue at x
+	instMatch
+	instSave // record the offset in capture slot x, then continue
+)
+
+// inst is an instruction of the program a brace pattern is matched with.
+// Every consuming instruction takes exactly one rune, so the program can be
+// run breadth-first over the input.
+type inst struct {
+	op   instOp
+	tok  *token
+	x, y int
+}
+
+var anyToken = &token{kind: tokenAny}
+
+// compileProgram appends the instructions for tokens to prog.
+func compileProgram(prog []inst, tokens []token) []inst {
+	for i := range tokens {
+		tok := &tokens[i]
+		switch tok.kind {
+		case tokenLiteral, tokenAny, tokenClass:
+			prog = append(prog, inst{op: instRune, tok: tok})
+		case tokenLiteralRun:
+			for _, char := range tok.lit {
+				prog = append(prog, inst{op: instRune, tok: &token{kind: tokenLiteral, char: char}})
+			}
+		case tokenAnyN:
+			for range tok.count {
+				prog = append(prog, inst{op: instRune, tok: anyToken})
+			}
+		case tokenStar:
+			loop := len(prog)
+			prog = append(prog,
+				inst{op: instSplit, x: loop + 1, y: loop + 3},
+				inst{op: instRune, tok: anyToken},
+				inst{op: instJump, x: loop},
+			)
+		case tokenAlt:
+			prog = compileAlternation(prog, tok.alt.alts, compileProgram)
+		}
+	}
+	return prog
+}
+
+// compileAlternation appends the instructions for alts, each compiled by
+// compile, as a chain of splits that tries them in order.
+func compileAlternation(prog []inst, alts [][]token, compile func([]inst, []token) []inst) []inst {
+	var jumps []int
+	for k, alt := range alts {
+		split := -1
+		if k < len(alts)-1 {
+			split = len(prog)
+			prog = append(prog, inst{op: instSplit, x: split + 1})
+		}
+		prog = compile(prog, alt)
+		if split >= 0 {
+			jumps = append(jumps, len(prog))
+			prog = append(prog, inst{op: instJump})
+			prog[split].y = len(prog)
+		}
+	}
+	for _, jump := range jumps {
+		prog[jump].x = len(prog)
+	}
+	return prog
+}
+
+// machine holds the thread lists for running a program.
+type machine struct {
+	current, next sparseSet
+	stack         []int
+}
+
+// sparseSet is a set of program counters with constant-time clear, in
+// insertion order, which is thread priority. starts holds the offset
+// each thread started at, for FindIndex.
+type sparseSet struct {
+	dense  []int
+	sparse []int
+	starts []int
+}
+
+func (s *sparseSet) reset(n int) {
+	if cap(s.sparse) < n {
+		s.sparse = make([]int, n)
+		s.dense = make([]int, 0, n)
+		s.starts = make([]int, 0, n)
+	}
+	s.sparse = s.sparse[:n]
+	s.dense = s.dense[:0]
+	s.starts = s.starts[:0]
+}
+
+func (s *sparseSet) clear() {
+	s.dense = s.dense[:0]
+	s.starts = s.starts[:0]
+}
+
+func (s *sparseSet) contains(pc int) bool {
+	i := s.sparse[pc]
+	return i < len(s.dense) && s.dense[i] == pc
+}
+
+func (s *sparseSet) insert(pc, start int) {
+	s.sparse[pc] = len(s.dense)
+	s.dense = append(s.dense, pc)
+	s.starts = append(s.starts, start)
+}
+
+var machinePool = sync.Pool{
+	New: func() any { return new(machine) },
+}
+
+// add inserts pc and everything reachable from it without consuming input,
+// in priority order, for a thread that started at start.
+func (m *machine) add(prog []inst, set *sparseSet, pc, start int) {
+	m.stack = append(m.stack[:0], pc)
+	for len(m.stack) > 0 {
+		pc := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		if set.contains(pc) {
+			continue
+		}
+		set.insert(pc, start)
+		switch in := &prog[pc]; in.op {
+		case instSplit:
+			m.stack = append(m.stack, in.y, in.x)
+		case instJump:
+			m.stack = append(m.stack, in.x)
+		}
+	}
+}
+
+// matchProgram runs p.prog over str, keeping every live thread in step, so
+// the time is linear in len(str) for a given pattern.
+func (p *Pattern) matchProgram(str string, fold bool) bool {
+	m := p.startProgram()
+	defer machinePool.Put(m)
+	for i := 0; i < len(str); {
+		if len(m.current.dense) == 0 {
+			return false
+		}
+		char, size := decodeRune(str[i:])
+		p.stepProgram(m, char, fold)
+		i += size
+	}
+	return p.programMatched(m)
+}
+
+// startProgram returns a machine from the pool with one thread at the start
+// of p.prog. The caller puts it back.
+func (p *Pattern) startProgram() *machine {
+	m := machinePool.Get().(*machine)
+	m.current.reset(len(p.prog))
+	m.next.reset(len(p.prog))
+	m.add(p.prog, &m.current, 0, 0)
+	return m
+}
+
+// stepProgram advances the threads of m over char.
+func (p *Pattern) stepProgram(m *machine, char rune, fold bool) {
+	m.next.clear()
+	for _, pc := range m.current.dense {
+		if in := &p.prog[pc]; in.op == instRune && p.tokenMatches(in.tok, char, fold) {
+			m.add(p.prog, &m.next, pc+1, 0)
+		}
+	}
+	m.current, m.next = m.next, m.current
+}
+
+// programMatched reports whether a thread of m has reached the end of
+// p.prog.
+func (p *Pattern) programMatched(m *machine) bool {
+	for _, pc := range m.current.dense {
+		if p.prog[pc].op == instMatch {
+			return true
+		}
+	}
+	return false
+}
+
+// findProgram is FindIndex for a brace pattern. A thread starts at every
+// offset until the first match, at a lower priority than the threads
+// already running. When a thread matches, the threads below it are dropped,
+// so the result is the leftmost match, and among those the one of highest
+// priority, as in Go's regexp.
+func (p *Pattern) findProgram(str string, fold bool) (int, int) {
+	m := machinePool.Get().(*machine)
+	defer machinePool.Put(m)
+	m.current.reset(len(p.prog))
+	m.next.reset(len(p.prog))
+	prefix, _, _ := p.literalAffixes()
+	start, end := -1, -1
+	for i := 0; ; {
+		if start < 0 {
+			if len(m.current.dense) == 0 && prefix != "" {
+				// No thread is running, so skip to where the literal
+				// prefix occurs.
+				index, _ := indexLiteral(str[i:], prefix, fold)
+				if index < 0 {
+					return -1, -1
+				}
+				i += index
+			}
+			m.add(p.prog, &m.current, 0, i)
+		}
+		for k, pc := range m.current.dense {
+			if p.prog[pc].op == instMatch {
+				start, end = m.current.starts[k], i
+				m.current.dense = m.current.dense[:k]
+				m.current.starts = m.current.starts[:k]
+				break
+			}
+		}
+		if i == len(str) || len(m.current.dense) == 0 && start >= 0 {
+			return start, end
+		}
+		char, size := decodeRune(str[i:])
+		m.next.clear()
+		for k, pc := range m.current.dense {
+			if in := &p.prog[pc]; in.op == instRune && p.tokenMatches(in.tok, char, fold) {
+				m.add(p.prog, &m.next, pc+1, m.current.starts[k])
+			}
+		}
+		m.current, m.next = m.next, m.current
+		i += size
+	}
+}
+
+// compileCaptures is compileProgram for SubmatchIndex. Stars prefer to stop,
+// so the first match in priority order is leftmost-shortest, and instSave
+// records the span of every capture. Each group is a capture, numbered
+// before the captures inside it; a numeric range has none inside. next is
+// the number of the next capture.
+func compileCaptures(prog []inst, tokens []token, next *int) []inst {
+	for i := range tokens {
+		tok := &tokens[i]
+		if !isCapture(tok.kind) && tok.kind != tokenAlt {
+			prog = compileProgram(prog, tokens[i:i+1])
+			continue
+		}
+		slot := 2 * *next
+		*next++
+		prog = append(prog, inst{op: instSave, x: slot})
+		switch {
+		case tok.kind == tokenStar:
+			loop := len(prog)
+			prog = append(prog,
+				inst{op: instSplit, x: loop + 3, y: loop + 1},
+				inst{op: instRune, tok: anyToken},
+				inst{op: instJump, x: loop},
+			)
+		case tok.kind == tokenAlt && !tok.alt.numeric:
+			prog = compileAlternation(prog, tok.alt.alts, func(prog []inst, alt []token) []inst {
+				return compileCaptures(prog, alt, next)
+			})
+		default:
+			prog = compileProgram(prog, tokens[i:i+1])
+		}
+		prog = append(prog, inst{op: instSave, x: slot + 1})
+	}
+	return prog
+}
+
+// captureThread is a thread of programSubmatchIndex.
+type captureThread struct {
+	pc   int
+	caps []int
+}
+
+// programSubmatchIndex is SubmatchIndex for a brace pattern. It runs the
+// capture program breadth-first like matchProgram, keeping the threads in
+// priority order, so the first thread to reach the end holds the captures a
+// backtracking matcher would find first. As in regexp's Pike VM, a save
+// writes the captures being followed and undoes the write afterwards, and
+// only a thread that waits on a rune gets a copy, recycled once it steps.
+func (p *Pattern) programSubmatchIndex(str string, fold bool) ([]int, bool) {
+	prog := p.captureProg
+	// added[pc] is the step pc was last added at, plus one.
+	added := make([]int, len(prog))
+	step := 1
+	var free [][]int
+	var add func(list []captureThread, pc int, caps []int, at int) []captureThread
+	add = func(list []captureThread, pc int, caps []int, at int) []captureThread {
+		if added[pc] == step {
+			return list
+		}
+		added[pc] = step
+		switch in := &prog[pc]; in.op {
+		case instSplit:
+			list = add(list, in.x, caps, at)
+			return add(list, in.y, caps, at)
+		case instJump:
+			return add(list, in.x, caps, at)
+		case instSave:
+			old := caps[in.x]
+			caps[in.x] = at
+			list = add(list, pc+1, caps, at)
+			caps[in.x] = old
+			return list
+		default:
+			var own []int
+			if n := len(free); n > 0 {
+				own, free = free[n-1], free[:n-1]
+			} else {
+				own = make([]int, len(caps))
+			}
+			copy(own, caps)
+			return append(list, captureThread{pc, own})
+		}
+	}
+	caps := make([]int, 2*p.captures)
+	for i := range caps {
+		caps[i] = -1
+	}
+	current := add(nil, 0, caps, 0)
+	var next []captureThread
+	for i := 0; i < len(str); {
+		if len(current) == 0 {
+			return nil, false
+		}
+		char, size := decodeRune(str[i:])
+		step++
+		next = next[:0]
+		for _, t := range current {
+			if in := &prog[t.pc]; in.op == instRune && p.tokenMatches(in.tok, char, fold) {
+				next = add(next, t.pc+1, t.caps, i+size)
+			}
+			free = append(free, t.caps)
+		}
+		current, next = next, current
+		i += size
+	}
+	for _, t := range current {
+		if prog[t.pc].op == instMatch {
+			return t.caps, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,345 @@
+package redglob
+
+import (
+	"errors"
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func compileBracesOption(t testing.TB, pattern string) *Pattern {
+	t.Helper()
+	p, err := CompileOptions{Braces: true}.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Compile(%q) with braces: %v", pattern, err)
+	}
+	return p
+}
+
+func TestBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"{a,b}", "a", true},
+		{"{a,b}", "b", true},
+		{"{a,b}", "ab", false},
+		{"{a,b}", "", false},
+		{"x{a,b}y", "xby", true},
+		{"{a,}x", "x", true},
+		{"{}x", "x", true},
+		{"{a}", "a", true},
+		{"user:{1,2*}:{name,email}", "user:1:name", true},
+		{"user:{1,2*}:{name,email}", "user:25:email", true},
+		{"user:{1,2*}:{name,email}", "user:3:name", false},
+		{"{a,b{c,d}}e", "bde", true},
+		{"{a,b{c,d}}e", "be", false},
+		{"{*a,b*}", "xxa", true},
+		{"{*a,b*}", "bxx", true},
+		{"{*a,b*}", "xxb", false},
+		{"{?,??}", "xy", true},
+		{"{?,??}", "xyz", false},
+		{"{[a-c],[x-z]}", "y", true},
+		{"{[a-c],[x-z]}", "m", false},
+		{"{[,}],x}", ",", true},
+		{"{[,}],x}", "}", true},
+		{`{\,,\}}`, ",", true},
+		{`{\,,\}}`, "}", true},
+		{`\{a,b\}`, "{a,b}", true},
+		{"a}b", "a}b", true},
+		{"a,b", "a,b", true},
+		{"{é,ß}*", "ßx", true},
+		{"*{.go,.mod}", "go.mod", true},
+		{"*{.go,.mod}", "go.sum", false},
+		{"{1..12}", "1", true},
+		{"{1..12}", "7", true},
+		{"{1..12}", "12", true},
+		{"{1..12}", "0", false},
+		{"{1..12}", "13", false},
+		{"{1..12}", "07", false},
+		{"{12..1}", "9", true},
+		{"{01..12}", "07", true},
+		{"{01..12}", "7", false},
+		{"{0..0}", "0", true},
+		{"shard-{0..15}:*", "shard-15:k", true},
+		{"shard-{0..15}:*", "shard-16:k", false},
+		{"{a..b,c}", "a..b", true},
+	}
+	for _, tt := range tests {
+		p := compileBracesOption(t, tt.pattern)
+		if got := p.Match(tt.str); got != tt.want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+		if got := p.MatchBytes([]byte(tt.str)); got != tt.want {
+			t.Errorf("Compile(%q).MatchBytes(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestBracesFold(t *testing.T) {
+	p := compileBracesOption(t, "{USER,Admin}:*")
+	for str, want := range map[string]bool{"user:1": true, "ADMIN:x": true, "guest:1": false} {
+		if got := p.MatchFold(str); got != want {
+			t.Errorf("MatchFold(%q) = %v, want %v", str, got, want)
+		}
+	}
+	if p.Match("user:1") {
+		t.Error(`Match("user:1") = true`)
+	}
+}
+
+// TestBracesNumericRange checks every number up to 1200 against ranges with
+// and without zero padding.
+func TestBracesNumericRange(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 200 {
+		lo, hi := r.IntN(1100), r.IntN(1100)
+		width := 0
+		loText, hiText := strconv.Itoa(lo), strconv.Itoa(hi)
+		if r.IntN(2) == 0 && lo < 1000 {
+			width = 4
+			loText = strings.Repeat("0", width-len(loText)) + loText
+		}
+		pattern := "{" + loText + ".." + hiText + "}"
+		p := compileBracesOption(t, pattern)
+		for n := range 1200 {
+			str := strconv.Itoa(n)
+			if width > 0 {
+				str = strings.Repeat("0", width-len(str)) + str
+			}
+			want := min(lo, hi) <= n && n <= max(lo, hi)
+			if got := p.Match(str); got != want {
+				t.Fatalf("Compile(%q).Match(%q) = %v, want %v", pattern, str, got, want)
+			}
+		}
+	}
+}
+
+func TestBracesErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		code    ErrorCode
+		offset  int
+	}{
+		{"{a,b", ErrUnterminatedBrace, 0},
+		{"x{a,{b}", ErrUnterminatedBrace, 1},
+		{"x{a,[b}", ErrUnterminatedClass, 4},
+		{`{a\`, ErrDanglingEscape, 2},
+		{"ab{1..x}", ErrInvalidRange, 2},
+		{"{1..}", ErrInvalidRange, 0},
+		{"{a..b}", ErrInvalidRange, 0},
+		{"{1..99999999999999999999}", ErrInvalidRange, 0},
+	}
+	for _, tt := range tests {
+		_, err := CompileOptions{Braces: true}.Compile(tt.pattern)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Code != tt.code || syntaxErr.Offset != tt.offset {
+			t.Errorf("Compile(%q) = %v, want %s at %d", tt.pattern, err, tt.code, tt.offset)
+		}
+	}
+	// An empty class is well formed and never matches.
+	if p, err := (CompileOptions{Braces: true}).Compile("{[],a}"); err != nil || !p.Match("a") || p.Match("") {
+		t.Errorf("Compile(%q) = %v, %v", "{[],a}", p, err)
+	}
+	if _, err := (CompileOptions{Braces: true, Mode: ModeRedisBytes}).Compile("{a,b}"); err == nil {
+		t.Error("ModeRedisBytes with braces: no error")
+	}
+	// Without the option, braces are literal as before.
+	if !Compile("{a,b}").Match("{a,b}") {
+		t.Error(`Compile("{a,b}") does not match itself`)
+	}
+}
+
+func TestBracesSeparator(t *testing.T) {
+	p, err := CompileOptions{Braces: true, Separator: '/'}.Compile("{src,test}/**/*.{go,mod}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for str, want := range map[string]bool{"src/a/b.go": true, "test/x.mod": true, "doc/x.go": false, "src/a.sum": false} {
+		if got := p.Match(str); got != want {
+			t.Errorf("Match(%q) = %v, want %v", str, got, want)
+		}
+	}
+	_, err = CompileOptions{Braces: true, Separator: '/'}.Compile("a/{b,c/d}")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Code != ErrUnterminatedBrace || syntaxErr.Offset != 2 {
+		t.Errorf("group containing the separator: %v", err)
+	}
+}
+
+// TestBracesRegexp compares random brace patterns with their Regexp, and
+// checks that Generate produces matches.
+func TestBracesRegexp(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	random := func(alphabet string, n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	for range 5000 {
+		pattern := random("ab*?{,}", 10)
+		p, err := CompileOptions{Braces: true}.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		re := p.Regexp()
+		for range 20 {
+			str := random("ab,{}", 8)
+			if got, want := p.Match(str), re.MatchString(str); got != want {
+				t.Fatalf("Compile(%q).Match(%q) = %v, regexp %s = %v", pattern, str, got, re, want)
+			}
+			if _, ok := p.Submatch(str); ok != re.MatchString(str) {
+				t.Fatalf("Compile(%q).Submatch(%q) ok = %v, want %v", pattern, str, ok, !ok)
+			}
+		}
+		if str, ok := p.Generate(r, GenOptions{Alphabet: []rune("ab")}); ok && !p.Match(str) {
+			t.Fatalf("Compile(%q).Generate() = %q, which does not match", pattern, str)
+		} else if nonEmpty, _ := Overlaps(p, p); ok != nonEmpty {
+			t.Fatalf("Compile(%q).Generate() ok = %v, want %v", pattern, ok, nonEmpty)
+		}
+	}
+}
+
+func TestBracesSubmatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		want         []int
+	}{
+		{"user:{1,2*}:{name,email}", "user:25:email", []int{5, 7, 6, 7, 8, 13}},
+		{"user:{1,2*}:{name,email}", "user:1:name", []int{5, 6, -1, -1, 7, 11}},
+		{"*{.go,.mod}", "go.mod", []int{0, 2, 2, 6}},
+		{"{*a,b*}", "bab", []int{0, 3, -1, -1, 1, 3}},
+		{"{*a,b*}", "aba", []int{0, 3, 0, 2, -1, -1}},
+		{"{a,b{c,?}}?", "bcd", []int{0, 2, 1, 2, -1, -1, 2, 3}},
+		{"x{1..12}", "x12", []int{1, 3}},
+		{"{a,a*}*", "aa", []int{0, 1, -1, -1, 1, 2}},
+		{"{,a}*", "a", []int{0, 0, 0, 1}},
+	}
+	for _, tt := range cases {
+		p := compileBracesOption(t, tt.pattern)
+		got, ok := p.SubmatchIndex(tt.str)
+		if !ok || !slices.Equal(got, tt.want) {
+			t.Errorf("Compile(%q).SubmatchIndex(%q) = %v, %v; want %v, true", tt.pattern, tt.str, got, ok, tt.want)
+		}
+	}
+	p := compileBracesOption(t, "{A,b?}:*")
+	got, ok := p.SubmatchFold("Bx:y")
+	if want := []string{"Bx", "x", "y"}; !ok || !slices.Equal(got, want) {
+		t.Errorf("SubmatchFold = %q, %v; want %q, true", got, ok, want)
+	}
+	if _, ok := p.Submatch("c:y"); ok {
+		t.Error("Submatch matched a string Match rejects")
+	}
+}
+
+func TestBracesLiteralPrefix(t *testing.T) {
+	p := compileBracesOption(t, "user:{1,2}:name")
+	if prefix, complete := p.LiteralPrefix(); prefix != "user:" || complete {
+		t.Errorf("LiteralPrefix() = %q, %v", prefix, complete)
+	}
+	if lo, hi, ok := p.KeyRange(); lo != "user:" || hi != "user;" || !ok {
+		t.Errorf("KeyRange() = %q, %q, %v", lo, hi, ok)
+	}
+}
+
+func TestBracesExpand(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		alphabet Alphabet
+		want     []string
+	}{
+		{"{b,a}{1..2}", nil, []string{"a1", "a2", "b1", "b2"}},
+		{"x{,y}", nil, []string{"x", "xy"}},
+		{"{a,[ab]}", nil, []string{"a", "b"}},
+		{"{a,?}", Alphabet("ab"), []string{"a", "b"}},
+		{"{[^a],c}", Alphabet("ab"), []string{"b", "c"}},
+		{"{a,b{c,d}}", nil, []string{"a", "bc", "bd"}},
+		{"{08..10}", nil, []string{"08", "09", "10"}},
+		{"{a,[^\x00-\U0010FFFF]}", nil, []string{"a"}},
+	}
+	for _, tt := range tests {
+		p := compileBracesOption(t, tt.pattern)
+		if !p.IsFinite() {
+			t.Errorf("Compile(%q).IsFinite() = false", tt.pattern)
+		}
+		got := slices.Collect(p.Expand(tt.alphabet, 0))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Compile(%q).Expand() = %q, want %q", tt.pattern, got, tt.want)
+		}
+		if n, ok := p.Cardinality(tt.alphabet); !ok || n.Int64() != int64(len(tt.want)) {
+			t.Errorf("Compile(%q).Cardinality() = %v, %v, want %d", tt.pattern, n, ok, len(tt.want))
+		}
+	}
+	if got := slices.Collect(compileBracesOption(t, "{a,b}{c,d}").Expand(nil, 3)); !slices.Equal(got, []string{"ac", "ad", "bc"}) {
+		t.Errorf("Expand with limit 3 = %q", got)
+	}
+	p, err := CompileOptions{Separator: '/', Braces: true}.Compile("{a,b}/{c,d?}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := p.Cardinality(Alphabet("x/")); !ok || n.Int64() != 4 {
+		t.Errorf("Compile(%q).Cardinality() = %v, %v, want 4", "{a,b}/{c,d?}", n, ok)
+	}
+
+	const patternAlphabet = "ab?[]^{,}"
+	alphabet := Alphabet("abé,")
+	r := rand.New(rand.NewPCG(9, 10))
+	for range 2000 {
+		var b strings.Builder
+		for range r.IntN(8) {
+			b.WriteByte(patternAlphabet[r.IntN(len(patternAlphabet))])
+		}
+		pattern := b.String()
+		p, err = CompileOptions{Braces: true}.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		got := slices.Collect(p.Expand(alphabet, 0))
+		n, ok := p.Cardinality(alphabet)
+		if !ok || n.Cmp(big.NewInt(int64(len(got)))) != 0 {
+			t.Fatalf("Compile(%q): Cardinality = %v, %v, Expand yielded %d", pattern, n, ok, len(got))
+		}
+		if !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+			t.Fatalf("Compile(%q).Expand is not strictly sorted: %q", pattern, got)
+		}
+		for _, s := range got {
+			if !p.Match(s) {
+				t.Fatalf("Compile(%q).Expand yielded %q, which does not match", pattern, s)
+			}
+		}
+		if s, ok := p.Generate(r, GenOptions{Alphabet: alphabet}); ok {
+			if _, found := slices.BinarySearch(got, s); !found {
+				t.Fatalf("Compile(%q).Expand misses %q", pattern, s)
+			}
+		}
+	}
+}
+
+func TestBracesMatchPrefix(t *testing.T) {
+	p := compileBracesOption(t, "{a,b}*")
+	if got := p.MatchPrefix("c"); got != NoMatch {
+		t.Errorf("MatchPrefix(%q) = %v, want NoMatch", "c", got)
+	}
+	if got := p.MatchPrefix("bc"); got != MaybeMatch {
+		t.Errorf("MatchPrefix(%q) = %v, want MaybeMatch", "bc", got)
+	}
+	if p.IsFinite() {
+		t.Error("IsFinite() = true")
+	}
+}
+
+// BenchmarkBracesBacktracking matches a pattern that takes exponential time
+// with a backtracking matcher.
+func BenchmarkBracesBacktracking(b *testing.B) {
+	p := compileBracesOption(b, strings.Repeat("{a,a*}", 20)+"b")
+	str := strings.Repeat("a", 100)
+	for b.Loop() {
+		p.Match(str)
+	}
+}
@@ -0,0 +1,55 @@
+package redglob
+
+// chunkBufferSize is the total length up to which MatchChunks copies the
+// chunks into a buffer on the stack and matches them with the usual fast
+// paths.
+const chunkBufferSize = 256
+
+// MatchChunks reports whether the concatenation of chunks, such as the
+// fragments of a key from a network parser or the leaves of a rope, matches
+// the pattern. It gives the same result as Match on the joined string,
+// including for runes, literals and invalid UTF-8 that straddle chunk
+// boundaries, without building that string.
+//
+// Keys up to a few hundred bytes are copied into a buffer on the stack.
+// Longer input is fed through a Matcher a chunk at a time.
+func (p *Pattern) MatchChunks(chunks [][]byte) bool {
+	return p.matchChunks(chunks, false)
+}
+
+// MatchChunksFold is like MatchChunks for MatchFold.
+func (p *Pattern) MatchChunksFold(chunks [][]byte) bool {
+	return p.matchChunks(chunks, true)
+}
+
+func (p *Pattern) matchChunks(chunks [][]byte, fold bool) bool {
+	total, nonEmpty, last := 0, 0, -1
+	for i, chunk := range chunks {
+		if len(chunk) > 0 {
+			total += len(chunk)
+			nonEmpty++
+			last = i
+		}
+	}
+	switch {
+	case nonEmpty == 0:
+		return p.match("", fold)
+	case nonEmpty == 1:
+		return p.match(b2s(chunks[last]), fold)
+	case total <= chunkBufferSize:
+		var buf [chunkBufferSize]byte
+		n := 0
+		for _, chunk := range chunks {
+			n += copy(buf[n:], chunk)
+		}
+		return p.match(b2s(buf[:n]), fold)
+	}
+	m := p.newMatcher(fold)
+	for _, chunk := range chunks {
+		if m.dead || m.all {
+			break
+		}
+		m.feed(b2s(chunk))
+	}
+	return m.Matched()
+}
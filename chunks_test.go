@@ -0,0 +1,90 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// splitChunks cuts str at random byte offsets, with some empty chunks.
+func splitChunks(r *rand.Rand, str string) [][]byte {
+	var chunks [][]byte
+	for len(str) > 0 {
+		n := r.IntN(len(str) + 1)
+		chunks = append(chunks, []byte(str[:n]))
+		str = str[n:]
+	}
+	return chunks
+}
+
+func TestMatchChunksAgreesWithMatch(t *testing.T) {
+	r := rand.New(rand.NewPCG(11, 12))
+	for _, tt := range allMatchCases() {
+		p := Compile(tt.args.pattern)
+		// Repeat short inputs past the stack buffer to cover the Matcher.
+		for _, str := range []string{tt.args.str, strings.Repeat(tt.args.str, 1+chunkBufferSize/max(1, len(tt.args.str)))} {
+			chunks := splitChunks(r, str)
+			if got, want := p.MatchChunks(chunks), p.Match(str); got != want {
+				t.Errorf("Compile(%q).MatchChunks(%q) = %v, want %v", tt.args.pattern, chunks, got, want)
+			}
+			if got, want := p.MatchChunksFold(chunks), p.MatchFold(str); got != want {
+				t.Errorf("Compile(%q).MatchChunksFold(%q) = %v, want %v", tt.args.pattern, chunks, got, want)
+			}
+		}
+	}
+}
+
+func TestMatchChunks(t *testing.T) {
+	long := strings.Repeat("x", chunkBufferSize)
+	tests := []struct {
+		opts    CompileOptions
+		pattern string
+		chunks  []string
+		want    bool
+	}{
+		{CompileOptions{}, "user:*:name", []string{"us", "er:4", "2:na", "me"}, true},
+		{CompileOptions{}, "前?", []string{"\xe5", "\x89\x8d", "後"}, true},
+		{CompileOptions{}, "???", []string{"\xe5", "\x89", "", "a"}, true},
+		{CompileOptions{}, "*abc", []string{long, "a", "bc"}, true},
+		{CompileOptions{}, "*abc", []string{long, "a", "b"}, false},
+		{CompileOptions{}, "", nil, true},
+		{CompileOptions{}, "", []string{"", ""}, true},
+		{CompileOptions{Braces: true}, "*{ab,cd}", []string{long, "c", "d"}, true},
+		{CompileOptions{Separator: '/'}, "**/x", []string{long, "/", "x"}, true},
+		{CompileOptions{Mode: ModeRedisBytes}, "*?", []string{long, "\xe5"}, true},
+	}
+	for _, tt := range tests {
+		p, err := tt.opts.Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var chunks [][]byte
+		for _, chunk := range tt.chunks {
+			chunks = append(chunks, []byte(chunk))
+		}
+		if got := p.MatchChunks(chunks); got != tt.want {
+			t.Errorf("Compile(%q).MatchChunks(%q) = %v, want %v", tt.pattern, tt.chunks, got, tt.want)
+		}
+	}
+	if Compile("[").MatchChunks([][]byte{[]byte(long), []byte("[")}) {
+		t.Error("invalid pattern matched")
+	}
+}
+
+func TestMatchChunksAllocs(t *testing.T) {
+	chunks := [][]byte{[]byte("tenant:acme:"), []byte("user:"), []byte("42:profile")}
+	for _, pattern := range []string{"tenant:*:user:*", "tenant:[a-z]*:user:??:*", "*profile"} {
+		p := Compile(pattern)
+		if allocs := testing.AllocsPerRun(100, func() { p.MatchChunks(chunks) }); allocs != 0 {
+			t.Errorf("Compile(%q).MatchChunks allocates %v times", pattern, allocs)
+		}
+	}
+}
+
+func BenchmarkMatchChunks(b *testing.B) {
+	p := Compile("tenant:*:user:[0-9]*:profile")
+	chunks := [][]byte{[]byte("tenant:acme:"), []byte("user:"), []byte("42:profile")}
+	for b.Loop() {
+		p.MatchChunks(chunks)
+	}
+}
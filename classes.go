@@ -0,0 +1,212 @@
+package redglob
+
+import (
+	"strings"
+	"unicode"
+)
+
+// classTable is a Unicode property inside a class, such as \p{Han}, or a
+// negated POSIX class. The class matches a rune in the table, or, if
+// negated (\P{Han}, [:^alpha:]), not in it.
+type classTable struct {
+	name    string
+	table   *unicode.RangeTable
+	negated bool
+	posix   bool
+}
+
+// posixClasses are the ASCII classes of [:name:], as in Go's regexp.
+var posixClasses = map[string][]charRange{
+	"alnum":  {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}},
+	"alpha":  {{'A', 'Z'}, {'a', 'z'}},
+	"ascii":  {{0, unicode.MaxASCII}},
+	"blank":  {{'\t', '\t'}, {' ', ' '}},
+	"cntrl":  {{0, 0x1f}, {0x7f, 0x7f}},
+	"digit":  {{'0', '9'}},
+	"graph":  {{'!', '~'}},
+	"lower":  {{'a', 'z'}},
+	"print":  {{' ', '~'}},
+	"punct":  {{'!', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}},
+	"space":  {{'\t', '\r'}, {' ', ' '}},
+	"upper":  {{'A', 'Z'}},
+	"word":   {{'0', '9'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}},
+	"xdigit": {{'0', '9'}, {'A', 'F'}, {'a', 'f'}},
+}
+
+// posixTables holds the POSIX classes as tables, for negated ones, which
+// fold like \P{Name}.
+var posixTables = func() map[string]*unicode.RangeTable {
+	tables := make(map[string]*unicode.RangeTable, len(posixClasses))
+	for name, ranges := range posixClasses {
+		table := &unicode.RangeTable{}
+		for _, r := range ranges {
+			table.R16 = append(table.R16, unicode.Range16{Lo: uint16(r.start), Hi: uint16(r.end), Stride: 1})
+		}
+		tables[name] = table
+	}
+	return tables
+}()
+
+// posixClass parses a "[:name:]" or "[:^name:]" item at the start of
+// pattern. It reports false if pattern does not start with one, so the '['
+// is an ordinary member, and returns ErrInvalidClassName for an unknown
+// name.
+func (class *compiledClass) posixClass(pattern string) (string, bool, ErrorCode) {
+	if !strings.HasPrefix(pattern, "[:") {
+		return pattern, false, ""
+	}
+	name, rest, ok := strings.Cut(pattern[2:], ":]")
+	if !ok {
+		return pattern, false, ""
+	}
+	negated := strings.HasPrefix(name, "^")
+	name = strings.TrimPrefix(name, "^")
+	ranges, ok := posixClasses[name]
+	if !ok {
+		return pattern, true, ErrInvalidClassName
+	}
+	if negated {
+		class.addTable(classTable{name: name, table: posixTables[name], negated: true, posix: true})
+		return rest, true, ""
+	}
+	for _, r := range ranges {
+		class.addRange(r)
+		addClassRangeBits(class, r.start, r.end)
+	}
+	return rest, true, ""
+}
+
+// unicodeClass parses the property after "\p" or "\P" at the start of
+// pattern, either a one-letter category such as "L" or a braced category
+// or script name such as "{Han}", and adds it to the class.
+func (class *compiledClass) unicodeClass(pattern string, negated bool) (string, ErrorCode) {
+	var name string
+	switch {
+	case pattern == "":
+		return pattern, ErrInvalidClassName
+	case pattern[0] == '{':
+		var ok bool
+		if name, pattern, ok = strings.Cut(pattern[1:], "}"); !ok {
+			return pattern, ErrInvalidClassName
+		}
+	default:
+		name, pattern = pattern[:1], pattern[1:]
+	}
+	table := unicode.Categories[name]
+	if table == nil {
+		table = unicode.Scripts[name]
+	}
+	if table == nil {
+		return pattern, ErrInvalidClassName
+	}
+	class.addTable(classTable{name: name, table: table, negated: negated})
+	return pattern, ""
+}
+
+// addTable adds t to the class, with its ASCII members in the bitmap.
+func (class *compiledClass) addTable(t classTable) {
+	class.tables = append(class.tables, t)
+	for char := rune(0); char <= unicode.MaxASCII; char++ {
+		if unicode.Is(t.table, char) != t.negated {
+			setASCIIBit(&class.bits, byte(char))
+		}
+	}
+}
+
+func (class *compiledClass) matchesTables(char rune) bool {
+	for _, t := range class.tables {
+		if unicode.Is(t.table, char) != t.negated {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFoldTables is matchesFold for a class with tables. As in Go's
+// regexp, a negated table is folded before it is negated: \P{Lu} matches
+// a rune only if none of its cases is an upper-case letter.
+func (class *compiledClass) matchesFoldTables(char rune) bool {
+	negatedHit := make([]bool, 0, 4)
+	for _, t := range class.tables {
+		if t.negated {
+			negatedHit = append(negatedHit, false)
+		}
+	}
+	candidate := char
+	for {
+		if class.matchesRange(candidate) {
+			return true
+		}
+		k := 0
+		for _, t := range class.tables {
+			in := unicode.Is(t.table, candidate)
+			if !t.negated {
+				if in {
+					return true
+				}
+				continue
+			}
+			negatedHit[k] = negatedHit[k] || in
+			k++
+		}
+		candidate = unicode.SimpleFold(candidate)
+		if candidate == char {
+			break
+		}
+	}
+	for _, hit := range negatedHit {
+		if !hit {
+			return true
+		}
+	}
+	return false
+}
+
+// empty reports whether the class has no members and is not negated, as
+// for "[]", so that it never matches.
+func (class *compiledClass) empty() bool {
+	return class.rangeCount == 0 && len(class.tables) == 0 && !class.negated
+}
+
+// rangeList returns the members of the class, ignoring negation, as ranges
+// in no particular order. Unicode properties are expanded, so the list is
+// long for classes such as \p{L}; it is meant for analysis, not matching.
+func (class *compiledClass) rangeList() []charRange {
+	ranges := class.ranges
+	if class.rangeCount == 1 {
+		ranges = []charRange{class.rangeOne}
+	}
+	if len(class.tables) == 0 {
+		return ranges
+	}
+	ranges = append([]charRange(nil), ranges...)
+	for _, t := range class.tables {
+		tableRanges := tableRangeList(t.table)
+		if t.negated {
+			tableRanges = subtractRanges([]charRange{{0, unicode.MaxRune}}, tableRanges)
+		}
+		ranges = append(ranges, tableRanges...)
+	}
+	return mergeRanges(ranges)
+}
+
+// tableRangeList returns the runes of table as sorted, disjoint ranges.
+func tableRangeList(table *unicode.RangeTable) []charRange {
+	var ranges []charRange
+	add := func(lo, hi, stride rune) {
+		if stride == 1 {
+			ranges = append(ranges, charRange{lo, hi})
+			return
+		}
+		for char := lo; char <= hi; char += stride {
+			ranges = append(ranges, charRange{char, char})
+		}
+	}
+	for _, r := range table.R16 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	for _, r := range table.R32 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	return mergeRanges(ranges)
+}
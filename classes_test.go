@@ -0,0 +1,229 @@
+package redglob
+
+import (
+	"errors"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func compileNamed(t testing.TB, pattern string) *Pattern {
+	t.Helper()
+	p, err := CompileOptions{NamedClasses: true}.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Compile(%q) with named classes: %v", pattern, err)
+	}
+	return p
+}
+
+func TestNamedClasses(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"[[:alpha:]]", "a", true},
+		{"[[:alpha:]]", "Z", true},
+		{"[[:alpha:]]", "1", false},
+		{"[[:alpha:]]", "é", false},
+		{"[[:^digit:]]", "a", true},
+		{"[[:^digit:]]", "é", true},
+		{"[[:^digit:]]", "5", false},
+		{"[^[:digit:]]", "5", false},
+		{"[[:digit:]_][[:digit:]_]*", "_2", true},
+		{"[[:digit:]_][[:digit:]_]*", "-2", false},
+		{"[[:space:]]", "\v", true},
+		{"[[:punct:]]", "`", true},
+		{"[[:xdigit:]][[:xdigit:]]", "fF", true},
+		{"[[:xdigit:]][[:xdigit:]]", "fg", false},
+		{"[[:word:][:blank:]]*", "a_1 \t", true},
+		{"[[:ab]", "[", true},
+		{"[[:ab]", "b", true},
+		{`[\p{Han}][\p{Han}]`, "漢字", true},
+		{`[\p{Han}][\p{Han}]`, "漢a", false},
+		{`[\pL]`, "é", true},
+		{`[\pL]`, "1", false},
+		{`[\P{L}]`, "1", true},
+		{`[\P{L}]`, "é", false},
+		{`[^\p{Greek}]`, "a", true},
+		{`[^\p{Greek}]`, "α", false},
+		{`[\p{Greek}\p{Cyrillic}0-9]`, "ж", true},
+		{`[\p{Greek}\p{Cyrillic}0-9]`, "7", true},
+		{`[\p{Greek}\p{Cyrillic}0-9]`, "z", false},
+		{`user:[\p{L}\p{N}_]*`, "user:名前_1", true},
+		{`user:[\p{L}\p{N}_]*`, "user:-b", false},
+	}
+	for _, tt := range tests {
+		p := compileNamed(t, tt.pattern)
+		if got := p.Match(tt.str); got != tt.want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+		if got := p.MatchBytes([]byte(tt.str)); got != tt.want {
+			t.Errorf("Compile(%q).MatchBytes(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestNamedClassesFold(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"[[:upper:]]", "a", true},
+		{"[[:upper:]]", "K", true},
+		{"[[:lower:]]", "K", true},
+		{"[[:upper:]]", "1", false},
+		{`[\p{Lu}]`, "é", true},
+		{`[\p{Lu}]`, "1", false},
+		{`[^\p{Ll}]`, "a", false},
+		{`[\P{Lu}]`, "k", false},
+		{"[[:^upper:]]", "a", false},
+		{"[[:^upper:]]", "1", true},
+	}
+	for _, tt := range tests {
+		p := compileNamed(t, tt.pattern)
+		if got := p.MatchFold(tt.str); got != tt.want {
+			t.Errorf("Compile(%q).MatchFold(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+	}
+	if compileNamed(t, "[[:upper:]]").Match("a") {
+		t.Error(`Match("a") = true`)
+	}
+}
+
+// TestNamedClassesASCII checks the precomputed ASCII bitmap against the
+// tables.
+func TestNamedClassesASCII(t *testing.T) {
+	for name, table := range unicode.Categories {
+		for _, negated := range []bool{false, true} {
+			pattern := `[\p{` + name + `}]`
+			if negated {
+				pattern = `[\P{` + name + `}]`
+			}
+			p := compileNamed(t, pattern)
+			for char := rune(0); char <= unicode.MaxASCII; char++ {
+				if got, want := p.Match(string(char)), unicode.Is(table, char) != negated; got != want {
+					t.Fatalf("Compile(%q).Match(%q) = %v, want %v", pattern, char, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestNamedClassesErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		code    ErrorCode
+		offset  int
+	}{
+		{"[[:foo:]]", ErrInvalidClassName, 1},
+		{"ab[x[:^nope:]]", ErrInvalidClassName, 4},
+		{`a[\p{Nope}]`, ErrInvalidClassName, 2},
+		{`[\p{Han`, ErrInvalidClassName, 1},
+		{`[\p`, ErrInvalidClassName, 1},
+		{`[\pZ`, ErrUnterminatedClass, 0},
+		{"[[:alpha:]", ErrUnterminatedClass, 0},
+	}
+	for _, tt := range tests {
+		_, err := CompileOptions{NamedClasses: true}.Compile(tt.pattern)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Code != tt.code || syntaxErr.Offset != tt.offset {
+			t.Errorf("Compile(%q) = %v, want %s at %d", tt.pattern, err, tt.code, tt.offset)
+		}
+	}
+	if _, err := (CompileOptions{NamedClasses: true, Mode: ModeRedisBytes}).Compile("[[:alpha:]]"); err == nil {
+		t.Error("ModeRedisBytes with named classes: no error")
+	}
+	// Without the option, the syntax keeps its Redis meaning.
+	p := Compile("[[:alpha:]]")
+	if !p.Match("a]") || p.Match("a") {
+		t.Error(`Compile("[[:alpha:]]") is not the class "[[:alph" followed by "]"`)
+	}
+}
+
+func TestNamedClassesSeparator(t *testing.T) {
+	p, err := CompileOptions{NamedClasses: true, Separator: ':'}.Compile("user:[[:digit:]]*:x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for str, want := range map[string]bool{"user:42:x": true, "user:4:2:x": false, "user:y:x": false} {
+		if got := p.Match(str); got != want {
+			t.Errorf("Match(%q) = %v, want %v", str, got, want)
+		}
+	}
+}
+
+// TestNamedClassesRegexp compares random patterns with their Regexp, folded
+// and not.
+func TestNamedClassesRegexp(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	items := []string{"[:alpha:]", "[:^digit:]", "[:punct:]", `\p{Han}`, `\pL`, `\P{Lu}`, `\p{Greek}`, "a-c", "_"}
+	runes := []rune("aZ5_ -éÉ漢αΣK\xff")
+	for range 3000 {
+		var b strings.Builder
+		for range 1 + r.IntN(3) {
+			switch r.IntN(3) {
+			case 0:
+				b.WriteByte('*')
+			case 1:
+				b.WriteByte('[')
+				if r.IntN(3) == 0 {
+					b.WriteByte('^')
+				}
+				for range 1 + r.IntN(2) {
+					b.WriteString(items[r.IntN(len(items))])
+				}
+				b.WriteByte(']')
+			default:
+				b.WriteRune(runes[r.IntN(4)])
+			}
+		}
+		pattern := b.String()
+		p := compileNamed(t, pattern)
+		re, reFold := p.Regexp(), regexp.MustCompile(p.regexpString(true))
+		for range 20 {
+			var s strings.Builder
+			for range r.IntN(4) {
+				s.WriteRune(runes[r.IntN(len(runes))])
+			}
+			str := s.String()
+			if got, want := p.Match(str), re.MatchString(str); got != want {
+				t.Fatalf("Compile(%q).Match(%q) = %v, regexp %s = %v", pattern, str, got, re, want)
+			}
+			if got, want := p.MatchFold(str), reFold.MatchString(str); got != want {
+				t.Fatalf("Compile(%q).MatchFold(%q) = %v, regexp %s = %v", pattern, str, got, reFold, want)
+			}
+		}
+	}
+}
+
+func TestNamedClassesAnalysis(t *testing.T) {
+	if n, ok := compileNamed(t, "[[:digit:]][[:xdigit:]]").Cardinality(nil); !ok || n.Int64() != 10*22 {
+		t.Errorf("Cardinality() = %v, %v", n, ok)
+	}
+	if lo, hi, ok := compileNamed(t, "[[:digit:]]x").KeyRange(); lo != "0x" || hi != "9x\x00" || !ok {
+		t.Errorf("KeyRange() = %q, %q, %v", lo, hi, ok)
+	}
+	han := compileNamed(t, `[\p{Han}]`)
+	r := rand.New(rand.NewPCG(7, 8))
+	for range 100 {
+		if str, ok := han.Generate(r, GenOptions{}); !ok || !han.Match(str) {
+			t.Fatalf("Generate() = %q, which does not match", str)
+		}
+	}
+	letter := compileNamed(t, `[\p{L}]*`)
+	if !Subsumes(letter, compileNamed(t, `[\p{Lu}\p{Ll}]*`)) || Subsumes(letter, Compile("1a")) {
+		t.Error("Subsumes with Unicode properties")
+	}
+}
+
+func BenchmarkNamedClass(b *testing.B) {
+	p := compileNamed(b, `user:[\p{L}\p{N}_]*`)
+	str := "user:ユーザー名_2024"
+	for b.Loop() {
+		p.Match(str)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/maolonglong/redglob"
+)
+
+// explain describes each pattern of the set: the options it was compiled
+// with, the literal text and key range it pins down, how many strings it
+// matches, its Redis Cluster slot and the equivalent regexp.
+func (set *patternSet) explain(w io.Writer, cfg *config) {
+	for i, source := range set.sources {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if set.lines != nil {
+			fmt.Fprintf(w, "%s:%d\n", set.name, set.lines[i])
+		}
+		field(w, "pattern", strconv.Quote(source))
+		field(w, "options", describeOptions(cfg))
+		if err := set.errs[i]; err != nil {
+			first, _, _ := strings.Cut(err.Error(), "\n")
+			field(w, "error", strings.TrimPrefix(first, "redglob: "))
+			continue
+		}
+		explainPattern(w, set.patterns[i], cfg)
+	}
+}
+
+func explainPattern(w io.Writer, p *redglob.Pattern, cfg *config) {
+	fold := cfg.fold
+	switch prefix, complete := p.LiteralPrefix(); {
+	case complete && !fold:
+		field(w, "literal", "matches only "+strconv.Quote(prefix))
+	case prefix != "":
+		field(w, "prefix", strconv.Quote(prefix))
+	}
+
+	lo, hi, ok := p.KeyRange()
+	if fold {
+		lo, hi, ok = p.KeyRangeFold()
+	}
+	switch {
+	case !ok:
+		field(w, "key range", "any key")
+	case hi == "":
+		field(w, "key range", fmt.Sprintf("[%q, end)", lo))
+	default:
+		field(w, "key range", fmt.Sprintf("[%q, %q)", lo, hi))
+	}
+
+	if p.IsFinite() {
+		if n, ok := p.Cardinality(nil); ok {
+			if n.IsInt64() && n.Int64() == 1 {
+				field(w, "matches", "1 string")
+			} else {
+				field(w, "matches", n.String()+" strings")
+			}
+		}
+	} else if cfg.opts.Mode != redglob.ModeRedisBytes && !cfg.opts.Braces {
+		// IsFinite does not analyze the other patterns.
+		field(w, "matches", "infinitely many strings")
+	}
+
+	tag, hasTag := p.HashTag()
+	switch slot, ok := p.Slot(); {
+	case hasTag:
+		field(w, "slot", fmt.Sprintf("%d (hash tag %q)", slot, tag))
+	case ok:
+		field(w, "slot", fmt.Sprintf("%d (whole key)", slot))
+	default:
+		field(w, "slot", "any")
+	}
+
+	if re := p.Regexp(); re != nil {
+		expr := re.String()
+		if fold {
+			expr = "(?i)" + expr
+		}
+		field(w, "regexp", expr)
+	} else {
+		field(w, "regexp", "none, the pattern is matched byte by byte or by segment")
+	}
+}
+
+func describeOptions(cfg *config) string {
+	var opts []string
+	if cfg.opts.Mode == redglob.ModeRedisBytes {
+		opts = append(opts, "redis bytes")
+	} else {
+		opts = append(opts, "unicode")
+	}
+	if cfg.fold {
+		opts = append(opts, "case-insensitive")
+	}
+	if cfg.opts.Separator != 0 {
+		opts = append(opts, "separator "+strconv.QuoteRune(cfg.opts.Separator))
+	}
+	if cfg.opts.Braces {
+		opts = append(opts, "braces")
+	}
+	if cfg.opts.NamedClasses {
+		opts = append(opts, "named classes")
+	}
+	return strings.Join(opts, ", ")
+}
+
+func field(w io.Writer, name, value string) {
+	fmt.Fprintf(w, "%-10s %s\n", name+":", value)
+}
@@ -0,0 +1,390 @@
+// Redglob prints the lines of its input that match a Redis-style glob
+// pattern, much as grep does for regular expressions.
+//
+// Usage:
+//
+//	redglob [flags] PATTERN [FILE...]
+//	redglob [flags] -f PATTERNS [FILE...]
+//
+// With no FILE, or with "-", it reads standard input. When more than one
+// FILE is given, each output line is prefixed with the name of its file.
+//
+// The flags are:
+//
+//	-i
+//		Match case-insensitively, as MatchFold does.
+//	-v
+//		Print the lines that do not match instead.
+//	-c
+//		Print only the number of selected lines.
+//	-0
+//		Read and write records terminated by NUL instead of newline, as
+//		produced by "redis-cli --scan" piped through "tr '\n' '\0'" or by
+//		"find -print0".
+//	-o
+//		Print only the non-empty parts of each line that the pattern
+//		matches, as FindAllIndex finds them, one per output line. A line is
+//		selected if it has one. With -i, the parts are found as
+//		FindAllIndexFold finds them. With -f, each part is the leftmost
+//		match of any of the patterns, the longest if several start there,
+//		and the search resumes after it. It cannot be combined with -v.
+//	-f file
+//		Read the patterns from file, one per line, and select the lines
+//		that match any of them. Empty lines are ignored.
+//	-redis
+//		Match byte for byte with ModeRedisBytes, as Redis does.
+//	-braces
+//		Enable brace groups such as {a,b} and {1..9}.
+//	-classes
+//		Enable named classes such as [[:digit:]] and [\p{Han}].
+//	-sep rune
+//		Make '*', '?' and classes stop at the separator, and "**" match
+//		whole segments.
+//	--explain
+//		Print how each pattern compiled and exit without reading input.
+//	--validate
+//		Check the patterns and exit without reading input.
+//
+// The exit status is 0 if a line was selected, 1 if none was, and 2 if a
+// pattern is invalid or an error occurred. An invalid pattern is reported
+// with a caret under the offending construct.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maolonglong/redglob"
+)
+
+// Exit statuses, as in grep.
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// config is the parsed command line.
+type config struct {
+	fold, invert, count, null, only bool
+	explain, validate               bool
+	patternFile                     string
+	opts                            redglob.CompileOptions
+}
+
+// usageError is a command line that cannot be run.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, rest, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitMatch
+		}
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintf(stderr, "redglob: %v\n", err)
+			fmt.Fprintln(stderr, usage)
+		}
+		return exitError
+	}
+
+	var set *patternSet
+	if cfg.patternFile != "" {
+		set, err = cfg.loadPatterns(cfg.patternFile)
+	} else {
+		set, err = cfg.compilePatterns("", []string{rest[0]}, nil)
+		rest = rest[1:]
+	}
+	if cfg.explain && set != nil {
+		out := bufio.NewWriter(stdout)
+		set.explain(out, cfg)
+		out.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if cfg.explain || cfg.validate {
+		return exitMatch
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	if len(rest) == 0 {
+		rest = []string{"-"}
+	}
+	selected, failed := 0, false
+	for _, name := range rest {
+		n, err := cfg.filterFile(out, stdin, name, len(rest) > 1, set)
+		selected += n
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(stderr, "redglob: %v\n", err)
+			failed = true
+		}
+	}
+	switch {
+	case failed:
+		return exitError
+	case selected > 0:
+		return exitMatch
+	}
+	return exitNoMatch
+}
+
+const usage = `usage: redglob [-i] [-v | -o] [-c] [-0] [-redis] [-braces] [-classes] [-sep rune]
+               [--explain] [--validate] {PATTERN | -f PATTERNS} [FILE...]`
+
+func parseFlags(args []string, stderr io.Writer) (*config, []string, error) {
+	cfg := &config{}
+	fs := flag.NewFlagSet("redglob", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&cfg.fold, "i", false, "match case-insensitively")
+	fs.BoolVar(&cfg.invert, "v", false, "select non-matching lines")
+	fs.BoolVar(&cfg.count, "c", false, "print only a count of selected lines")
+	fs.BoolVar(&cfg.null, "0", false, "read and write NUL-terminated records")
+	fs.BoolVar(&cfg.only, "o", false, "print only the matched parts of lines")
+	fs.StringVar(&cfg.patternFile, "f", "", "read patterns from `file`, one per line")
+	fs.BoolVar(&cfg.explain, "explain", false, "print how the patterns compiled and exit")
+	fs.BoolVar(&cfg.validate, "validate", false, "check the patterns and exit")
+	redis := fs.Bool("redis", false, "match byte for byte as Redis does")
+	fs.BoolVar(&cfg.opts.Braces, "braces", false, "enable brace groups")
+	fs.BoolVar(&cfg.opts.NamedClasses, "classes", false, "enable named classes")
+	sep := fs.String("sep", "", "path separator `rune`")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *redis {
+		cfg.opts.Mode = redglob.ModeRedisBytes
+	}
+	if *sep != "" {
+		char, size := utf8.DecodeRuneInString(*sep)
+		if size != len(*sep) {
+			return nil, nil, usageError("-sep must be a single rune")
+		}
+		cfg.opts.Separator = char
+	}
+	rest := fs.Args()
+	switch {
+	case cfg.patternFile == "" && len(rest) == 0:
+		return nil, nil, usageError("missing pattern")
+	case cfg.only && cfg.invert:
+		return nil, nil, usageError("-o cannot be combined with -v")
+	}
+	return cfg, rest, nil
+}
+
+// filterFile filters the named file, or stdin for "-", and returns the
+// number of selected records.
+func (cfg *config) filterFile(out *bufio.Writer, stdin io.Reader, name string, prefix bool, set *patternSet) (int, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+	label := ""
+	if prefix {
+		label = name
+		if name == "-" {
+			label = "(standard input)"
+		}
+	}
+	return cfg.filter(out, r, label, set)
+}
+
+// filter writes the selected records of r to out, each prefixed with label
+// and a colon if label is not empty, and returns how many there were.
+func (cfg *config) filter(out *bufio.Writer, r io.Reader, label string, set *patternSet) (int, error) {
+	delim := byte('\n')
+	if cfg.null {
+		delim = 0
+	}
+	write := func(b []byte) {
+		if label != "" {
+			out.WriteString(label)
+			out.WriteByte(':')
+		}
+		out.Write(b)
+		out.WriteByte(delim)
+	}
+	br := bufio.NewReaderSize(r, 64<<10)
+	selected := 0
+	for {
+		record, err := br.ReadBytes(delim)
+		if err == nil {
+			record = record[:len(record)-1]
+		}
+		// The last record may lack its terminator; an empty one is none.
+		if err == nil || len(record) > 0 {
+			switch {
+			case cfg.only:
+				found := false
+				set.findAll(record, cfg, func(start, end int) {
+					found = true
+					if !cfg.count {
+						write(record[start:end])
+					}
+				})
+				if found {
+					selected++
+				}
+			case set.match(record, cfg.fold) != cfg.invert:
+				selected++
+				if !cfg.count {
+					write(record)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return selected, err
+		}
+	}
+	if cfg.count {
+		write([]byte(strconv.Itoa(selected)))
+	}
+	return selected, nil
+}
+
+// patternSet is the compiled patterns of the command line or of a -f file.
+type patternSet struct {
+	name     string // the -f file, or ""
+	sources  []string
+	lines    []int              // line numbers in the -f file, or nil
+	patterns []*redglob.Pattern // nil where errs is not
+	errs     []error
+	// index is set when the patterns can be looked up in a PatternSet,
+	// which compiles them with the default options and matches exactly.
+	index *redglob.PatternSet[int]
+}
+
+// loadPatterns compiles the non-empty lines of the named file.
+func (cfg *config) loadPatterns(name string) (*patternSet, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("redglob: %w", err)
+	}
+	var sources []string
+	var lines []int
+	for i, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			sources = append(sources, line)
+			lines = append(lines, i+1)
+		}
+	}
+	return cfg.compilePatterns(name, sources, lines)
+}
+
+// compilePatterns compiles sources, read from the given lines of the named
+// file if lines is not nil. It reports every invalid pattern, but returns
+// the set even then so that --explain can describe it.
+func (cfg *config) compilePatterns(name string, sources []string, lines []int) (*patternSet, error) {
+	set := &patternSet{name: name, sources: sources, lines: lines, errs: make([]error, len(sources))}
+	if len(sources) > 1 && !cfg.fold && cfg.opts == (redglob.CompileOptions{}) {
+		set.index = &redglob.PatternSet[int]{}
+	}
+	var errs []error
+	for i, source := range sources {
+		p, err := cfg.opts.Compile(source)
+		if err == nil && set.index != nil {
+			err = set.index.Add(i, source)
+		}
+		if err != nil {
+			set.errs[i] = err
+			if lines != nil {
+				err = fmt.Errorf("%s:%d: %w", name, lines[i], err)
+			}
+			errs = append(errs, err)
+		}
+		set.patterns = append(set.patterns, p)
+	}
+	return set, errors.Join(errs...)
+}
+
+func (set *patternSet) match(b []byte, fold bool) bool {
+	if set.index != nil {
+		return set.index.MatchAny(string(b))
+	}
+	for _, p := range set.patterns {
+		if fold && p.MatchBytesFold(b) || !fold && p.MatchBytes(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// findAll calls yield with the non-empty matches of the patterns in b, from
+// left to right. Each is the leftmost match of any pattern, the longest of
+// those starting there, and the search resumes after it. Like grep, it
+// skips empty matches.
+func (set *patternSet) findAll(b []byte, cfg *config, yield func(start, end int)) {
+	// next holds the leftmost match of each pattern at or after pos. It
+	// starts before the input, to be found, and is past the end once a
+	// pattern has no more matches.
+	next := make([][2]int, len(set.patterns))
+	for i := range next {
+		next[i] = [2]int{-1, -1}
+	}
+	for pos := 0; pos <= len(b); {
+		best := [2]int{-1, -1}
+		for i, p := range set.patterns {
+			if next[i][0] < pos {
+				var start, end int
+				if cfg.fold {
+					start, end = p.FindIndexBytesFold(b[pos:])
+				} else {
+					start, end = p.FindIndexBytes(b[pos:])
+				}
+				next[i] = [2]int{pos + start, pos + end}
+				if start < 0 {
+					next[i] = [2]int{len(b) + 1, len(b) + 1}
+				}
+			}
+			if loc := next[i]; loc[0] <= len(b) && (best[0] < 0 || loc[0] < best[0] || loc[0] == best[0] && loc[1] > best[1]) {
+				best = loc
+			}
+		}
+		switch {
+		case best[0] < 0:
+			return
+		case best[0] < best[1]:
+			yield(best[0], best[1])
+			pos = best[1]
+		case best[0] == len(b):
+			return
+		default:
+			// Step over the empty match by a rune, or by a byte in
+			// ModeRedisBytes, as FindAllIndex does.
+			size := 1
+			if cfg.opts.Mode != redglob.ModeRedisBytes {
+				_, size = utf8.DecodeRune(b[best[0]:])
+			}
+			pos = best[0] + size
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const keys = "user:1\nuser:22\nUSER:3\norder:1\nsession:{42}:a\n"
+
+// runCLI runs the command with stdin and returns its output and status.
+func runCLI(t *testing.T, stdin string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	status = run(args, strings.NewReader(stdin), &out, &errOut)
+	return out.String(), errOut.String(), status
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		want   string
+		status int
+	}{
+		{[]string{"user:*"}, keys, "user:1\nuser:22\n", 0},
+		{[]string{"-i", "user:?"}, keys, "user:1\nUSER:3\n", 0},
+		{[]string{"-v", "*:1"}, keys, "user:22\nUSER:3\nsession:{42}:a\n", 0},
+		{[]string{"-c", "*:1"}, keys, "2\n", 0},
+		{[]string{"-c", "nope"}, keys, "0\n", 1},
+		{[]string{"nope"}, keys, "", 1},
+		{[]string{"-o", "[0-9]*"}, keys, "1\n22\n3\n1\n42}:a\n", 0},
+		{[]string{"-o", "-c", "{*}"}, keys, "1\n", 0},
+		{[]string{"-o", "-i", "user:?"}, keys, "user:1\nuser:2\nUSER:3\n", 0},
+		{[]string{"-0", "a*"}, "a\x00b\x00ab", "a\x00ab\x00", 0},
+		{[]string{"-0", "a?b"}, "a\nb\x00axb\x00", "a\nb\x00axb\x00", 0},
+		{[]string{""}, "x\n\ny\n", "\n", 0},
+		{[]string{"-redis", "a?c"}, "a\xffc\n", "a\xffc\n", 0},
+		{[]string{"-braces", "user:{1,3}"}, keys, "user:1\n", 0},
+		{[]string{"-classes", "user:[[:digit:]]"}, keys, "user:1\n", 0},
+		{[]string{"-sep", ":", "*:?"}, "a:1\na:b:1\n", "a:1\n", 0},
+		{[]string{"--", "-x"}, "-x\n", "-x\n", 0},
+	}
+	for _, tt := range tests {
+		stdout, stderr, status := runCLI(t, tt.stdin, tt.args...)
+		if stdout != tt.want || status != tt.status || stderr != "" {
+			t.Errorf("redglob %q = %q, %d, stderr %q, want %q, %d", tt.args, stdout, status, stderr, tt.want, tt.status)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	a := writeFile(t, "a.txt", keys)
+	b := writeFile(t, "b.txt", "user:9")
+	stdout, _, status := runCLI(t, "", "user:?", a)
+	if stdout != "user:1\n" || status != 0 {
+		t.Errorf("one file: %q, %d", stdout, status)
+	}
+	stdout, _, status = runCLI(t, "user:5\n", "user:?", a, b, "-")
+	if want := a + ":user:1\n" + b + ":user:9\n(standard input):user:5\n"; stdout != want || status != 0 {
+		t.Errorf("several files: %q, %d, want %q", stdout, status, want)
+	}
+	stdout, _, status = runCLI(t, "", "-c", "user:*", a, b)
+	if want := a + ":2\n" + b + ":1\n"; stdout != want || status != 0 {
+		t.Errorf("-c with several files: %q, %d, want %q", stdout, status, want)
+	}
+	stdout, stderr, status := runCLI(t, "", "user:?", filepath.Join(t.TempDir(), "missing"), a)
+	if stdout != a+":user:1\n" || !strings.Contains(stderr, "missing") || status != 2 {
+		t.Errorf("missing file: %q, %q, %d", stdout, stderr, status)
+	}
+}
+
+func TestPatternFile(t *testing.T) {
+	patterns := writeFile(t, "patterns.txt", "user:?\n\norder:*\n")
+	stdout, _, status := runCLI(t, keys, "-f", patterns)
+	if stdout != "user:1\norder:1\n" || status != 0 {
+		t.Errorf("-f: %q, %d", stdout, status)
+	}
+	stdout, _, _ = runCLI(t, keys, "-i", "-f", patterns)
+	if stdout != "user:1\nUSER:3\norder:1\n" {
+		t.Errorf("-i -f: %q", stdout)
+	}
+	stdout, _, _ = runCLI(t, keys, "-v", "-f", patterns)
+	if stdout != "user:22\nUSER:3\nsession:{42}:a\n" {
+		t.Errorf("-v -f: %q", stdout)
+	}
+	stdout, _, _ = runCLI(t, keys, "-braces", "-f", patterns)
+	if stdout != "user:1\norder:1\n" {
+		t.Errorf("-braces -f: %q", stdout)
+	}
+	// -o takes the leftmost match of any pattern, the longest there.
+	only := writeFile(t, "only.txt", "us\nuser\n[0-9]*\n")
+	if stdout, _, _ = runCLI(t, keys, "-o", "-f", only); stdout != "user\n1\nuser\n22\n3\n1\n42}:a\n" {
+		t.Errorf("-o -f: %q", stdout)
+	}
+	if stdout, _, _ = runCLI(t, keys, "-o", "-i", "-f", only); stdout != "user\n1\nuser\n22\nUSER\n3\n1\n42}:a\n" {
+		t.Errorf("-o -i -f: %q", stdout)
+	}
+	// The pattern file is the only pattern; the first argument is a file.
+	input := writeFile(t, "keys.txt", keys)
+	if stdout, _, _ = runCLI(t, "", "-f", patterns, input); stdout != "user:1\norder:1\n" {
+		t.Errorf("-f with a file: %q", stdout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	stdout, stderr, status := runCLI(t, "", "--validate", "user:[0-9]*")
+	if stdout != "" || stderr != "" || status != 0 {
+		t.Errorf("--validate with a valid pattern: %q, %q, %d", stdout, stderr, status)
+	}
+	_, stderr, status = runCLI(t, "", "--validate", "ab[c")
+	if want := "redglob: unterminated character class at column 3\n\tab[c\n\t  ^\n"; stderr != want || status != 2 {
+		t.Errorf("--validate with an invalid pattern: %q, %d, want %q", stderr, status, want)
+	}
+	// Invalid patterns are rejected without --validate too.
+	if _, _, status = runCLI(t, keys, "ab[c"); status != 2 {
+		t.Errorf("invalid pattern: status %d", status)
+	}
+	_, stderr, status = runCLI(t, "", "--validate", "-braces", "{a")
+	if !strings.Contains(stderr, "unterminated brace at column 1") || status != 2 {
+		t.Errorf("--validate -braces: %q, %d", stderr, status)
+	}
+
+	patterns := writeFile(t, "patterns.txt", "ok*\nx[\n\n[]y\ny\\\n")
+	_, stderr, status = runCLI(t, "", "--validate", "-f", patterns)
+	if !strings.HasPrefix(stderr, patterns+":2: redglob: unterminated character class at column 2\n") ||
+		strings.Contains(stderr, ":4:") ||
+		!strings.Contains(stderr, patterns+":5: redglob: trailing backslash at column 2\n") || status != 2 {
+		t.Errorf("--validate -f: %q, %d", stderr, status)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	stdout, _, status := runCLI(t, "", "--explain", "user:{42}:[0-9]*")
+	want := `pattern:   "user:{42}:[0-9]*"
+options:   unicode
+prefix:    "user:{42}:"
+key range: ["user:{42}:0", "user:{42}::")
+matches:   infinitely many strings
+slot:      8000 (hash tag "42")
+regexp:    \Auser\x{3a}\x{7b}42\x{7d}\x{3a}[\x{30}-\x{39}](?s:.*)\z
+`
+	if stdout != want || status != 0 {
+		t.Errorf("--explain = %d\n%s\nwant\n%s", status, stdout, want)
+	}
+
+	stdout, _, _ = runCLI(t, "", "--explain", "somekey")
+	for _, line := range []string{`literal:   matches only "somekey"`, "matches:   1 string", "slot:      11058 (whole key)"} {
+		if !strings.Contains(stdout, line+"\n") {
+			t.Errorf("--explain somekey has no %q:\n%s", line, stdout)
+		}
+	}
+	stdout, _, _ = runCLI(t, "", "--explain", "-i", "-sep", "/", "a/?")
+	for _, line := range []string{"options:   unicode, case-insensitive, separator '/'", `key range: ["A/\x00", "a/\xff\x00")`, "regexp:    none"} {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("--explain -i -sep has no %q:\n%s", line, stdout)
+		}
+	}
+
+	patterns := writeFile(t, "patterns.txt", "a*\nb[\n")
+	stdout, stderr, status := runCLI(t, "", "--explain", "-f", patterns)
+	if !strings.HasPrefix(stdout, patterns+":1\npattern:   \"a*\"\n") ||
+		!strings.HasSuffix(stdout, "\n\n"+patterns+":2\npattern:   \"b[\"\noptions:   unicode\nerror:     unterminated character class at column 2\n") ||
+		stderr == "" || status != 2 {
+		t.Errorf("--explain -f = %d, %q\n%s", status, stderr, stdout)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-o", "-v", "a"},
+		{"-sep", "ab", "a"},
+		{"-nope", "a"},
+	} {
+		stdout, stderr, status := runCLI(t, "", args...)
+		if stdout != "" || !strings.Contains(stderr, "usage: redglob") || status != 2 {
+			t.Errorf("redglob %q = %q, %q, %d", args, stdout, stderr, status)
+		}
+	}
+	if _, _, status := runCLI(t, "", "-h"); status != 0 {
+		t.Errorf("-h: status %d", status)
+	}
+}
@@ -0,0 +1,57 @@
+package redglob
+
+import "github.com/maolonglong/redglob/internal/caret"
+
+// ErrorCode describes why a pattern failed to compile.
+type ErrorCode string
+
+// Syntax error codes reported by CompileErr, Validate, NewRewriter and
+// CompileOptions.Compile.
+const (
+	// ErrUnterminatedClass reports a '[' without a closing ']'.
+	ErrUnterminatedClass ErrorCode = "unterminated character class"
+	// ErrDanglingEscape reports a '\\' at the end of the pattern.
+	ErrDanglingEscape ErrorCode = "trailing backslash"
+	// ErrInvalidReference reports a '#' in a rewrite template that is not
+	// followed by a capture number.
+	ErrInvalidReference ErrorCode = "invalid capture reference"
+	// ErrMissingCapture reports a rewrite template reference to a capture
+	// the source pattern does not have.
+	ErrMissingCapture ErrorCode = "reference to missing capture"
+	// ErrUnterminatedBrace reports a '{' without a closing '}' when
+	// CompileOptions.Braces is set.
+	ErrUnterminatedBrace ErrorCode = "unterminated brace"
+	// ErrInvalidRange reports a brace group without commas that contains
+	// ".." but is not a numeric range such as "{1..20}".
+	ErrInvalidRange ErrorCode = "invalid numeric range"
+	// ErrInvalidClassName reports an unknown or malformed "[:name:]" or
+	// "\\p{Name}" inside a class when CompileOptions.NamedClasses is set.
+	ErrInvalidClassName ErrorCode = "invalid class name"
+)
+
+func (code ErrorCode) String() string {
+	return string(code)
+}
+
+// SyntaxError describes an invalid pattern and where the problem starts.
+type SyntaxError struct {
+	Code    ErrorCode
+	Pattern string
+	Offset  int // byte offset of the offending construct
+	Column  int // 1-based rune column of the offending construct
+}
+
+func newSyntaxError(code ErrorCode, pattern string, offset int) *SyntaxError {
+	return &SyntaxError{
+		Code:    code,
+		Pattern: pattern,
+		Offset:  offset,
+		Column:  caret.Column(pattern, offset),
+	}
+}
+
+// Error formats the error on three lines: the message, the pattern, and a
+// caret under the offending construct.
+func (e *SyntaxError) Error() string {
+	return caret.Format("redglob", string(e.Code), e.Pattern, e.Offset)
+}
@@ -0,0 +1,73 @@
+package redglob
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompileErr(t *testing.T) {
+	cases := []struct {
+		pattern string
+		code    ErrorCode
+		offset  int
+		column  int
+	}{
+		{"", "", 0, 0},
+		{"user:*", "", 0, 0},
+		{"a*b*c", "", 0, 0},
+		{"[^]", "", 0, 0},
+		{`a\*`, "", 0, 0},
+		{"[", ErrUnterminatedClass, 0, 1},
+		{"user:[0-9*", ErrUnterminatedClass, 5, 6},
+		{"前[a", ErrUnterminatedClass, 3, 2},
+		{`[a\`, ErrUnterminatedClass, 0, 1},
+		{`abc\`, ErrDanglingEscape, 3, 4},
+		{`?\`, ErrDanglingEscape, 1, 2},
+		{"a[]b", "", 0, 0},
+		{"a[]b[", ErrUnterminatedClass, 4, 5},
+	}
+	for _, tt := range cases {
+		p, err := CompileErr(tt.pattern)
+		if validateErr := Validate(tt.pattern); (validateErr == nil) != (err == nil) {
+			t.Errorf("Validate(%q) = %v, CompileErr error = %v", tt.pattern, validateErr, err)
+		}
+		if tt.code == "" {
+			if err != nil || p == nil {
+				t.Errorf("CompileErr(%q) = %v, %v; want pattern, nil", tt.pattern, p, err)
+			}
+			continue
+		}
+		if p != nil {
+			t.Errorf("CompileErr(%q) returned a pattern with error %v", tt.pattern, err)
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("CompileErr(%q) error = %v, want *SyntaxError", tt.pattern, err)
+			continue
+		}
+		if syntaxErr.Code != tt.code || syntaxErr.Offset != tt.offset || syntaxErr.Column != tt.column {
+			t.Errorf("CompileErr(%q) = {%s %d %d}, want {%s %d %d}", tt.pattern,
+				syntaxErr.Code, syntaxErr.Offset, syntaxErr.Column, tt.code, tt.offset, tt.column)
+		}
+		if syntaxErr.Pattern != tt.pattern {
+			t.Errorf("CompileErr(%q).Pattern = %q", tt.pattern, syntaxErr.Pattern)
+		}
+	}
+}
+
+func TestCompileErrAgreesWithCompile(t *testing.T) {
+	for _, tt := range allMatchCases() {
+		_, err := CompileErr(tt.args.pattern)
+		if (err == nil) != Compile(tt.args.pattern).valid {
+			t.Errorf("CompileErr(%q) error = %v, Compile valid = %v", tt.args.pattern, err, Compile(tt.args.pattern).valid)
+		}
+	}
+}
+
+func TestSyntaxErrorString(t *testing.T) {
+	_, err := CompileErr("user:\t[0-9")
+	want := "redglob: unterminated character class at column 7\n\tuser:\t[0-9\n\t     \t^"
+	if err == nil || err.Error() != want {
+		t.Errorf("CompileErr error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,449 @@
+package redglob
+
+import (
+	"iter"
+	"math/big"
+	"slices"
+	"unicode/utf8"
+)
+
+// Alphabet is the set of runes that '?' and negated classes expand to in
+// Cardinality and Expand. Literals and non-negated classes always expand
+// to their own runes. A nil Alphabet stands for every valid rune.
+//
+// In ModeRedisBytes, where '?' and classes match bytes, the runes below
+// 256 stand for the bytes of the same value, and a nil Alphabet for every
+// byte.
+type Alphabet []rune
+
+// PrintableASCII holds the runes ' ' through '~'.
+var PrintableASCII = func() Alphabet {
+	a := make(Alphabet, 0, '~'-' '+1)
+	for r := ' '; r <= '~'; r++ {
+		a = append(a, r)
+	}
+	return a
+}()
+
+// ranges returns the alphabet as sorted, disjoint ranges of valid runes.
+func (a Alphabet) ranges() []charRange {
+	if a == nil {
+		return []charRange{{0, 0xd7ff}, {0xe000, utf8.MaxRune}}
+	}
+	var ranges []charRange
+	for _, r := range a {
+		if utf8.ValidRune(r) {
+			ranges = append(ranges, charRange{r, r})
+		}
+	}
+	return mergeRanges(ranges)
+}
+
+// mergeRanges sorts ranges and merges overlapping and adjacent ones.
+func mergeRanges(ranges []charRange) []charRange {
+	slices.SortFunc(ranges, func(a, b charRange) int {
+		return int(a.start - b.start)
+	})
+	out := ranges[:0]
+	for _, r := range ranges {
+		if n := len(out); n > 0 && r.start <= out[n-1].end+1 {
+			out[n-1].end = max(out[n-1].end, r.end)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// subtractRanges returns the runes of a that are not in b. Both must be
+// sorted and disjoint.
+func subtractRanges(a, b []charRange) []charRange {
+	var out []charRange
+	for _, r := range a {
+		for _, cut := range b {
+			if cut.end < r.start || cut.start > r.end {
+				continue
+			}
+			if cut.start > r.start {
+				out = append(out, charRange{r.start, cut.start - 1})
+			}
+			r.start = cut.end + 1
+			if r.start > r.end {
+				break
+			}
+		}
+		if r.start <= r.end {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func rangesSize(ranges []charRange) int64 {
+	var n int64
+	for _, r := range ranges {
+		n += int64(r.end-r.start) + 1
+	}
+	return n
+}
+
+// IsFinite reports whether p matches finitely many strings, that is, whether
+// it has no '*', including inside the alternatives of a brace group. A nil
+// or invalid Pattern matches nothing and is finite.
+func (p *Pattern) IsFinite() bool {
+	switch {
+	case p == nil || !p.valid:
+		return true
+	case p.mode == ModeRedisBytes:
+		return !slices.ContainsFunc(p.redis, func(t redisToken) bool {
+			return t.kind == tokenStar
+		})
+	case p.segments != nil:
+		return !slices.ContainsFunc(p.segments, func(segment *Pattern) bool {
+			return segment == nil || !segment.IsFinite()
+		})
+	case p.simple:
+		return !p.hasStar
+	}
+	return !p.literalStars && finiteTokens(p.tokens)
+}
+
+// finiteTokens reports whether tokens hold no '*', in groups or not.
+func finiteTokens(tokens []token) bool {
+	return !slices.ContainsFunc(tokens, func(t token) bool {
+		return t.kind == tokenStar || t.kind == tokenAlt && slices.ContainsFunc(t.alt.alts, func(alt []token) bool {
+			return !finiteTokens(alt)
+		})
+	})
+}
+
+// hasGroups reports whether p, or one of its segments, has brace groups.
+func (p *Pattern) hasGroups() bool {
+	return p.prog != nil || slices.ContainsFunc(p.segments, func(segment *Pattern) bool {
+		return segment != nil && segment.prog != nil
+	})
+}
+
+// expansion is one position of a finite pattern: either a fixed literal or
+// a set of runes.
+type expansion struct {
+	lit    string
+	ranges []charRange
+	choice bool
+}
+
+// expansions splits a finite pattern into positions, expanding '?' and
+// negated classes over alphabet.
+func (p *Pattern) expansions(alphabet Alphabet) []expansion {
+	if !p.valid {
+		return []expansion{{choice: true}}
+	}
+	all := alphabet.ranges()
+	if p.mode == ModeRedisBytes {
+		return p.redisExpansions(all)
+	}
+	if p.segments == nil {
+		return p.tokenExpansions(all, 0)
+	}
+	var out []expansion
+	for i, segment := range p.segments {
+		if i > 0 {
+			out = append(out, expansion{lit: string(p.separator)})
+		}
+		out = append(out, segment.tokenExpansions(all, p.separator)...)
+	}
+	return out
+}
+
+// tokenExpansions returns the positions of a tokenized pattern. No choice
+// includes sep, the separator of an enclosing pattern, unless it is zero.
+func (p *Pattern) tokenExpansions(all []charRange, sep rune) []expansion {
+	var exclude []charRange
+	if sep != 0 {
+		exclude = append(exclude, charRange{sep, sep})
+		all = subtractRanges(all, exclude)
+	}
+	var out []expansion
+	for _, t := range p.tokenStream() {
+		switch t.kind {
+		case tokenLiteral:
+			out = append(out, expansion{lit: string(t.char)})
+		case tokenLiteralRun:
+			out = append(out, expansion{lit: t.lit})
+		case tokenAny, tokenAnyN:
+			for range max(t.count, 1) {
+				out = append(out, expansion{ranges: all, choice: true})
+			}
+		case tokenClass:
+			ranges := slices.Clone(t.class.rangeList())
+			// Ranges may span the surrogates, which are not valid runes.
+			ranges = subtractRanges(mergeRanges(ranges), []charRange{{0xd800, 0xdfff}})
+			if t.class.negated {
+				ranges = subtractRanges(all, ranges)
+			} else {
+				ranges = subtractRanges(ranges, exclude)
+			}
+			out = append(out, expansion{ranges: ranges, choice: true})
+		}
+	}
+	return out
+}
+
+// redisExpansions returns the positions of a ModeRedisBytes pattern, whose
+// choices are ranges of bytes. A finite pattern has no '*', so neither of
+// Redis's quirks applies: the empty string matches only a pattern without
+// tokens.
+func (p *Pattern) redisExpansions(all []charRange) []expansion {
+	notBytes := []charRange{{0x100, utf8.MaxRune}}
+	bytes := subtractRanges(all, notBytes)
+	var out []expansion
+	for _, t := range p.redis {
+		switch t.kind {
+		case tokenLiteralRun:
+			out = append(out, expansion{lit: t.lit})
+		case tokenAnyN:
+			for range t.count {
+				out = append(out, expansion{ranges: bytes, choice: true})
+			}
+		case tokenClass:
+			var ranges []charRange
+			for c := range 256 {
+				if t.class[0].contains(byte(c)) {
+					ranges = append(ranges, charRange{rune(c), rune(c)})
+				}
+			}
+			ranges = mergeRanges(ranges)
+			if t.negated {
+				ranges = subtractRanges(ranges, subtractRanges([]charRange{{0, 0xff}}, bytes))
+			}
+			out = append(out, expansion{ranges: ranges, choice: true})
+		}
+	}
+	return out
+}
+
+// Cardinality returns the number of strings p matches when '?' and negated
+// classes range over alphabet. It reports false if p is not finite.
+//
+// Only valid UTF-8 strings are counted: '?' and a literal U+FFFD also
+// match invalid bytes, which are not enumerated.
+func (p *Pattern) Cardinality(alphabet Alphabet) (*big.Int, bool) {
+	if !p.IsFinite() {
+		return nil, false
+	}
+	if p.valid && p.hasGroups() {
+		return new(big.Int).Set(newGroupExpander(p, alphabet).start.count), true
+	}
+	n := big.NewInt(1)
+	var size big.Int
+	for _, e := range p.expansions(alphabet) {
+		if e.choice {
+			n.Mul(n, size.SetInt64(rangesSize(e.ranges)))
+		}
+	}
+	return n, true
+}
+
+// Expand returns an iterator over the strings p matches, in lexicographic
+// order, with '?' and negated classes ranging over alphabet. It yields at
+// most limit strings, or all of them if limit is zero or negative. Strings
+// are produced lazily, so a pattern with a huge cardinality can be expanded
+// partially. Expand yields nothing if p is not finite.
+func (p *Pattern) Expand(alphabet Alphabet, limit int) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if !p.IsFinite() {
+			return
+		}
+		if p.valid && p.hasGroups() {
+			newGroupExpander(p, alphabet).expand(yield, limit)
+			return
+		}
+		positions := p.expansions(alphabet)
+		// Each choice position holds the index of its current range and the
+		// current rune. UTF-8 preserves code point order, so counting up
+		// from the last position yields strings in byte order.
+		rangeIndex := make([]int, len(positions))
+		current := make([]rune, len(positions))
+		for i, e := range positions {
+			if e.choice {
+				if len(e.ranges) == 0 {
+					return
+				}
+				current[i] = e.ranges[0].start
+			}
+		}
+		appendChar := utf8.AppendRune
+		if p.mode == ModeRedisBytes {
+			appendChar = func(buf []byte, c rune) []byte {
+				return append(buf, byte(c))
+			}
+		}
+		var buf []byte
+		for n := 0; limit <= 0 || n < limit; n++ {
+			buf = buf[:0]
+			for i, e := range positions {
+				if e.choice {
+					buf = appendChar(buf, current[i])
+				} else {
+					buf = append(buf, e.lit...)
+				}
+			}
+			if !yield(string(buf)) {
+				return
+			}
+			i := len(positions) - 1
+			for ; i >= 0; i-- {
+				e := positions[i]
+				if !e.choice {
+					continue
+				}
+				if current[i] < e.ranges[rangeIndex[i]].end {
+					current[i]++
+					break
+				}
+				if rangeIndex[i]+1 < len(e.ranges) {
+					rangeIndex[i]++
+					current[i] = e.ranges[rangeIndex[i]].start
+					break
+				}
+				rangeIndex[i] = 0
+				current[i] = e.ranges[0].start
+			}
+			if i < 0 {
+				return
+			}
+		}
+	}
+}
+
+// groupExpander is Cardinality and Expand for a finite pattern with brace
+// groups. Alternatives may match the same string, as in "{a,[ab]}", so it
+// determinizes the pattern's automaton over intervals of runes to count and
+// list each string once.
+type groupExpander struct {
+	a          *automaton
+	intervals  []charRange
+	inAlphabet []bool // the interval is in the Alphabet
+	states     map[string]*expandState
+	start      *expandState
+}
+
+// expandState is a set of automaton instructions. next holds the state
+// after a rune of each interval, or nil where no match can follow.
+type expandState struct {
+	accept bool
+	next   []*expandState
+	count  *big.Int // strings matched from here
+}
+
+func newGroupExpander(p *Pattern, alphabet Alphabet) *groupExpander {
+	a := p.automaton()
+	all := alphabet.ranges()
+	var bounds []rune
+	for _, r := range all {
+		bounds = append(bounds, r.start, r.end+1)
+	}
+	e := &groupExpander{a: a, intervals: partition(bounds, a), states: make(map[string]*expandState)}
+	for _, r := range e.intervals {
+		_, in := slices.BinarySearchFunc(all, r.start, func(cr charRange, char rune) int {
+			switch {
+			case cr.end < char:
+				return -1
+			case cr.start > char:
+				return 1
+			default:
+				return 0
+			}
+		})
+		e.inAlphabet = append(e.inAlphabet, in)
+	}
+	e.start = e.state(a.start())
+	return e
+}
+
+// state returns the state for set, building it and the states after it.
+// The pattern is finite, so the automaton has no loops and this ends.
+func (e *groupExpander) state(set []bool) *expandState {
+	key := make([]byte, len(set))
+	for pc, in := range set {
+		if in {
+			key[pc] = 1
+		}
+	}
+	if s, ok := e.states[string(key)]; ok {
+		return s
+	}
+	s := &expandState{
+		accept: e.a.accepts(set, true),
+		next:   make([]*expandState, len(e.intervals)),
+		count:  new(big.Int),
+	}
+	e.states[string(key)] = s
+	if s.accept {
+		s.count.SetInt64(1)
+	}
+	var size big.Int
+	for i, r := range e.intervals {
+		next := e.step(set, i)
+		if !slices.Contains(next, true) {
+			continue
+		}
+		if t := e.state(next); t.count.Sign() > 0 {
+			s.next[i] = t
+			s.count.Add(s.count, size.Mul(size.SetInt64(int64(r.end-r.start)+1), t.count))
+		}
+	}
+	return s
+}
+
+// step is automaton.step for the runes of interval i, where '?' and negated
+// classes only match runes of the Alphabet.
+func (e *groupExpander) step(set []bool, i int) []bool {
+	next := make([]bool, len(e.a.prog))
+	seen := make([]bool, len(e.a.prog))
+	for pc, in := range set {
+		if !in || e.a.prog[pc].op != instRune {
+			continue
+		}
+		t := e.a.prog[pc].tok
+		if !e.inAlphabet[i] && (t.kind == tokenAny || t.kind == tokenClass && t.class.negated) {
+			continue
+		}
+		if e.a.matches(&e.a.prog[pc], e.intervals[i].start) {
+			e.a.closure(pc+1, next, seen)
+		}
+	}
+	return next
+}
+
+// expand yields up to limit strings, or all if limit is not positive, in
+// lexicographic order: a string before its extensions, and those by their
+// next rune.
+func (e *groupExpander) expand(yield func(string) bool, limit int) {
+	var buf []byte
+	n := 0
+	var walk func(s *expandState) bool
+	walk = func(s *expandState) bool {
+		if s.accept {
+			if limit > 0 && n == limit || !yield(string(buf)) {
+				return false
+			}
+			n++
+		}
+		for i, next := range s.next {
+			if next == nil {
+				continue
+			}
+			for char := e.intervals[i].start; char <= e.intervals[i].end; char++ {
+				size := len(buf)
+				buf = utf8.AppendRune(buf, char)
+				if !walk(next) {
+					return false
+				}
+				buf = buf[:size]
+			}
+		}
+		return true
+	}
+	walk(e.start)
+}
@@ -0,0 +1,183 @@
+package redglob
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIsFinite(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"", true},
+		{"abc", true},
+		{"shard:[0-3]:??", true},
+		{`a\*`, true},
+		{"a*", false},
+		{"a*b*c", false},
+		{"[a-z]*", false},
+		{"a[b", true},
+	}
+	var p *Pattern
+	if !p.IsFinite() {
+		t.Error("a nil Pattern reported infinite")
+	}
+	for _, tt := range tests {
+		if got := Compile(tt.pattern).IsFinite(); got != tt.want {
+			t.Errorf("Compile(%q).IsFinite() = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		pattern string
+		want    bool
+	}{
+		{"", true},
+		{"shard:[0-3]:??", true},
+		{`a\*`, true},
+		{"a[*", true},
+		{"a*", false},
+		{"[a-z]**", false},
+	} {
+		if got := compileRedisMode(t, tt.pattern).IsFinite(); got != tt.want {
+			t.Errorf("ModeRedisBytes %q IsFinite() = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		alphabet Alphabet
+		want     string
+		ok       bool
+	}{
+		{"shard:[0-3]:??", PrintableASCII, "36100", true},
+		{"abc", nil, "1", true},
+		{"?", nil, "1112064", true},
+		{"??", nil, "1236686340096", true},
+		{"[^a]", PrintableASCII, "94", true},
+		{"[^a]", Alphabet("ab"), "1", true},
+		{"[a-c][c-a]", Alphabet(""), "9", true},
+		{"[\ud7ff-\ue000]", nil, "2", true},
+		{"?", Alphabet(""), "0", true},
+		{"a[]", nil, "0", true},
+		{"a[b", nil, "0", true},
+		{"a*", nil, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Compile(tt.pattern).Cardinality(tt.alphabet)
+		if ok != tt.ok || ok && got.String() != tt.want {
+			t.Errorf("Compile(%q).Cardinality() = %v, %v, want %s, %v", tt.pattern, got, ok, tt.want, tt.ok)
+		}
+	}
+	for _, tt := range []struct {
+		pattern  string
+		alphabet Alphabet
+		want     string
+		ok       bool
+	}{
+		{"shard:[0-3]:??", nil, "262144", true},
+		{"shard:[0-3]:??", PrintableASCII, "36100", true},
+		{"?", Alphabet("é€"), "1", true},
+		{"[^a]", PrintableASCII, "94", true},
+		{"[^a]", nil, "255", true},
+		{"[\xff]", PrintableASCII, "1", true},
+		{"a[]", nil, "0", true},
+		{"a*", nil, "", false},
+	} {
+		got, ok := compileRedisMode(t, tt.pattern).Cardinality(tt.alphabet)
+		if ok != tt.ok || ok && got.String() != tt.want {
+			t.Errorf("ModeRedisBytes %q Cardinality() = %v, %v, want %s, %v", tt.pattern, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		alphabet Alphabet
+		limit    int
+		want     []string
+	}{
+		{"shard:[0-1]:[ab]", nil, 0, []string{"shard:0:a", "shard:0:b", "shard:1:a", "shard:1:b"}},
+		{"shard:[0-1]:[ab]", nil, 3, []string{"shard:0:a", "shard:0:b", "shard:1:a"}},
+		{"[^b]?", Alphabet("abc"), 0, []string{"aa", "ab", "ac", "ca", "cb", "cc"}},
+		{"x[é\\-a]", nil, 0, []string{"x-", "xa", "xé"}},
+		{"abc", nil, 0, []string{"abc"}},
+		{"", nil, 0, []string{""}},
+		{"a[]", nil, 0, nil},
+		{"a*", nil, 0, nil},
+	}
+	for _, tt := range tests {
+		got := slices.Collect(Compile(tt.pattern).Expand(tt.alphabet, tt.limit))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Compile(%q).Expand(%q, %d) = %q, want %q", tt.pattern, string(tt.alphabet), tt.limit, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		pattern  string
+		alphabet Alphabet
+		want     []string
+	}{
+		{"shard:[0-1]:[ab]", nil, []string{"shard:0:a", "shard:0:b", "shard:1:a", "shard:1:b"}},
+		{"x?", Alphabet("\x7f\u00ff\u0100"), []string{"x\x7f", "x\xff"}},
+		{"[^b]", Alphabet("abc"), []string{"a", "c"}},
+		{"", nil, []string{""}},
+		{"a*", nil, nil},
+	} {
+		got := slices.Collect(compileRedisMode(t, tt.pattern).Expand(tt.alphabet, 0))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ModeRedisBytes %q Expand(%q, 0) = %q, want %q", tt.pattern, string(tt.alphabet), got, tt.want)
+		}
+	}
+	// Huge expansions are lazy.
+	var first []string
+	for s := range Compile("????").Expand(nil, 0) {
+		if first = append(first, s); len(first) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(first, []string{"\x00\x00\x00\x00", "\x00\x00\x00\x01"}) {
+		t.Errorf("Compile(%q).Expand(nil, 0) starts with %q", "????", first)
+	}
+}
+
+func TestExpandRandom(t *testing.T) {
+	const patternAlphabet = "ab?[]^-\\é"
+	alphabet := Alphabet("abcé-")
+	r := rand.New(rand.NewPCG(5, 6))
+	runes := []rune(patternAlphabet)
+	for range 2000 {
+		var b strings.Builder
+		for range r.IntN(7) {
+			b.WriteRune(runes[r.IntN(len(runes))])
+		}
+		pattern := b.String()
+		p := Compile(pattern)
+		got := slices.Collect(p.Expand(alphabet, 0))
+		n, ok := p.Cardinality(alphabet)
+		if !ok || n.Cmp(big.NewInt(int64(len(got)))) != 0 {
+			t.Fatalf("Compile(%q): Cardinality = %v, %v, Expand yielded %d", pattern, n, ok, len(got))
+		}
+		if !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+			t.Fatalf("Compile(%q).Expand is not strictly sorted: %q", pattern, got)
+		}
+		for _, s := range got {
+			if !p.Match(s) {
+				t.Fatalf("Compile(%q).Expand yielded %q, which does not match", pattern, s)
+			}
+		}
+		// Strings built from the alphabet and the pattern's literals match
+		// only if they were expanded.
+		if len(got) > 0 {
+			s, _ := p.Generate(r, GenOptions{Alphabet: alphabet})
+			if _, found := slices.BinarySearch(got, s); !found {
+				t.Fatalf("Compile(%q).Expand misses %q", pattern, s)
+			}
+		}
+	}
+}
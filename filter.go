@@ -0,0 +1,65 @@
+package redglob
+
+import (
+	"iter"
+	"slices"
+)
+
+// Filter returns an iterator over the strings of seq that p matches.
+func Filter(seq iter.Seq[string], p *Pattern) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for str := range seq {
+			if p.Match(str) && !yield(str) {
+				return
+			}
+		}
+	}
+}
+
+// FilterKeys returns an iterator over the entries of m whose keys p
+// matches, in the unspecified order of ranging over m.
+func FilterKeys[V any](m map[string]V, p *Pattern) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for key, value := range m {
+			if p.Match(key) && !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// FilterFunc returns an iterator over the elements of seq whose key, as
+// returned by the key function, p matches.
+func FilterFunc[T any](seq iter.Seq[T], key func(T) string, p *Pattern) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if p.Match(key(v)) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// FilterSorted returns an iterator over the strings of sorted that p
+// matches, in order. sorted must be sorted in increasing byte-wise order,
+// as by slices.Sort. Only the part of the slice within p.KeyRange is
+// tested: the start is found by binary search, and iteration stops at the
+// upper bound, so "user:*" visits just the keys starting with "user:".
+func FilterSorted(sorted []string, p *Pattern) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		keys := sorted
+		lo, hi, ok := p.KeyRange()
+		if ok {
+			start, _ := slices.BinarySearch(keys, lo)
+			keys = keys[start:]
+		}
+		for _, str := range keys {
+			if hi != "" && str >= hi {
+				return
+			}
+			if p.Match(str) && !yield(str) {
+				return
+			}
+		}
+	}
+}
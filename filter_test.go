@@ -0,0 +1,83 @@
+package redglob
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	keys := []string{"user:1", "admin:1", "user:2", "session:1"}
+	p := Compile("user:*")
+	if got := slices.Collect(Filter(slices.Values(keys), p)); !slices.Equal(got, []string{"user:1", "user:2"}) {
+		t.Errorf("Filter() = %q", got)
+	}
+	for range Filter(slices.Values(keys), p) {
+		break
+	}
+
+	m := map[string]int{"user:1": 1, "admin:1": 2, "user:2": 3}
+	if got := maps.Collect(FilterKeys(m, p)); !maps.Equal(got, map[string]int{"user:1": 1, "user:2": 3}) {
+		t.Errorf("FilterKeys() = %v", got)
+	}
+
+	type entry struct {
+		key   string
+		value int
+	}
+	entries := []entry{{"user:1", 1}, {"admin:1", 2}, {"user:2", 3}}
+	got := slices.Collect(FilterFunc(slices.Values(entries), func(e entry) string { return e.key }, p))
+	if !slices.Equal(got, []entry{{"user:1", 1}, {"user:2", 3}}) {
+		t.Errorf("FilterFunc() = %v", got)
+	}
+}
+
+// TestFilterSorted compares FilterSorted with a full scan over a random
+// sorted keyspace.
+func TestFilterSorted(t *testing.T) {
+	r := rand.New(rand.NewPCG(15, 16))
+	prefixes := []string{"user:", "admin:", "session:", "user", "us", ""}
+	keys := make([]string, 5000)
+	for i := range keys {
+		keys[i] = prefixes[r.IntN(len(prefixes))] + strconv.Itoa(r.IntN(1000))
+	}
+	slices.Sort(keys)
+	patterns := []string{"user:*", "user:1?", "user:[1-3]*", "*:1", "admin:42", "[su]*9", "*", "", "zzz*", "user:\xff*"}
+	for _, pattern := range patterns {
+		p := Compile(pattern)
+		var want []string
+		for _, key := range keys {
+			if p.Match(key) {
+				want = append(want, key)
+			}
+		}
+		if got := slices.Collect(FilterSorted(keys, p)); !slices.Equal(got, want) {
+			t.Errorf("FilterSorted(%q) = %d keys, want %d", pattern, len(got), len(want))
+		}
+	}
+}
+
+// TestFilterSortedVisits checks that FilterSorted tests only the keys in
+// the pattern's key range, by planting matching keys out of order beyond
+// both ends of it.
+func TestFilterSortedVisits(t *testing.T) {
+	keys := []string{"user:planted", "a:1", "user:1", "user:2", "z:1", "user:planted"}
+	if got := slices.Collect(FilterSorted(keys, Compile("user:*"))); !slices.Equal(got, []string{"user:1", "user:2"}) {
+		t.Errorf("FilterSorted() = %q", got)
+	}
+}
+
+func BenchmarkFilterSorted(b *testing.B) {
+	var keys []string
+	for i := range 10000 {
+		keys = append(keys, "cache:"+strconv.Itoa(i), "user:"+strconv.Itoa(i))
+	}
+	slices.Sort(keys)
+	p := Compile("user:12*")
+	for b.Loop() {
+		for range FilterSorted(keys, p) {
+		}
+	}
+}
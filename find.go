@@ -0,0 +1,530 @@
+package redglob
+
+import "strings"
+
+// FindIndex returns the leftmost substring of str that p matches as
+// str[start:end], or -1, -1 if there is none. Among matches with the same
+// start it picks the one a backtracking matcher would try first, with '*'
+// greedy and the alternatives of a brace group in order, as Go's regexp
+// does. Without brace groups that is the longest match, so "user=*" finds
+// the rest of the line.
+//
+// The search jumps between occurrences of the pattern's literal pieces and
+// does not allocate; in ModeRedisBytes it steps a byte at a time. A pattern
+// compiled with a Separator is run as one program over str, in time linear
+// in len(str), and finds the longest match at the leftmost start.
+func (p *Pattern) FindIndex(str string) (start, end int) {
+	return p.find(str, false)
+}
+
+// FindIndexFold is like FindIndex but compares as MatchFold does.
+func (p *Pattern) FindIndexFold(str string) (start, end int) {
+	return p.find(str, true)
+}
+
+// FindString returns the text of the leftmost match of p in str, as found by
+// FindIndex. It returns "" if there is none, which is also the text of an
+// empty match; use FindIndex to tell them apart.
+func (p *Pattern) FindString(str string) string {
+	return p.findString(str, false)
+}
+
+// FindStringFold is like FindString but compares as MatchFold does.
+func (p *Pattern) FindStringFold(str string) string {
+	return p.findString(str, true)
+}
+
+func (p *Pattern) findString(str string, fold bool) string {
+	start, end := p.find(str, fold)
+	if start < 0 {
+		return ""
+	}
+	return str[start:end]
+}
+
+// FindAllIndex returns the successive non-overlapping matches of p in str,
+// each as a two-element slice holding start and end, as Go's regexp does.
+// An empty match right after a previous match is skipped. It returns at
+// most n matches, or all of them if n is negative, and nil if there are
+// none.
+func (p *Pattern) FindAllIndex(str string, n int) [][]int {
+	return p.findAll(str, n, false)
+}
+
+// FindAllIndexFold is like FindAllIndex but compares as MatchFold does.
+func (p *Pattern) FindAllIndexFold(str string, n int) [][]int {
+	return p.findAll(str, n, true)
+}
+
+func (p *Pattern) findAll(str string, n int, fold bool) [][]int {
+	if n == 0 {
+		return nil
+	}
+	var flat []int
+	prevEnd := -1
+	for pos := 0; pos <= len(str) && (n < 0 || len(flat) < 2*n); {
+		start, end := p.find(str[pos:], fold)
+		if start < 0 {
+			break
+		}
+		start, end = pos+start, pos+end
+		accept := true
+		if end == start {
+			// An empty match does not advance; step over a rune, or a
+			// byte in ModeRedisBytes, and skip it if it abuts the previous
+			// match.
+			accept = start != prevEnd
+			if start < len(str) {
+				size := 1
+				if p.mode != ModeRedisBytes {
+					_, size = decodeRune(str[start:])
+				}
+				pos = start + size
+			} else {
+				pos = len(str) + 1
+			}
+		} else {
+			pos = end
+		}
+		prevEnd = end
+		if accept {
+			flat = append(flat, start, end)
+		}
+	}
+	if len(flat) == 0 {
+		return nil
+	}
+	out := make([][]int, len(flat)/2)
+	for i := range out {
+		out[i] = flat[2*i : 2*i+2 : 2*i+2]
+	}
+	return out
+}
+
+// FindIndexBytes is like FindIndex but searches b.
+func (p *Pattern) FindIndexBytes(b []byte) (start, end int) {
+	return p.find(b2s(b), false)
+}
+
+// FindIndexBytesFold is like FindIndexFold but searches b.
+func (p *Pattern) FindIndexBytesFold(b []byte) (start, end int) {
+	return p.find(b2s(b), true)
+}
+
+// FindBytes returns the leftmost match of p in b as a subslice of b, or nil
+// if there is none.
+func (p *Pattern) FindBytes(b []byte) []byte {
+	return p.findBytes(b, false)
+}
+
+// FindBytesFold is like FindBytes but compares as MatchFold does.
+func (p *Pattern) FindBytesFold(b []byte) []byte {
+	return p.findBytes(b, true)
+}
+
+func (p *Pattern) findBytes(b []byte, fold bool) []byte {
+	start, end := p.find(b2s(b), fold)
+	if start < 0 {
+		return nil
+	}
+	return b[start:end:end]
+}
+
+// FindAllIndexBytes is like FindAllIndex but searches b.
+func (p *Pattern) FindAllIndexBytes(b []byte, n int) [][]int {
+	return p.findAll(b2s(b), n, false)
+}
+
+// FindAllIndexBytesFold is like FindAllIndexFold but searches b.
+func (p *Pattern) FindAllIndexBytesFold(b []byte, n int) [][]int {
+	return p.findAll(b2s(b), n, true)
+}
+
+func (p *Pattern) find(str string, fold bool) (int, int) {
+	switch {
+	case p == nil || !p.valid:
+		return -1, -1
+	case p.mode == ModeRedisBytes:
+		return p.findRedis(str, fold)
+	case p.segments != nil:
+		return p.findSegments(str, fold)
+	case p.prog != nil:
+		return p.findProgram(str, fold)
+	case p.simple:
+		prefix := piece{lit: p.prefix}
+		start, end, ok := prefix.index(p, str, 0, fold)
+		switch {
+		case !ok:
+			return -1, -1
+		case !p.hasStar:
+			return start, end
+		case p.suffix == "":
+			return start, len(str)
+		}
+		if end, ok = (piece{lit: p.suffix}).lastEnd(p, str, end, fold); !ok {
+			return -1, -1
+		}
+		return start, end
+	}
+	c := pieceCursor{tokens: p.tokens}
+	if p.literalStars {
+		c = pieceCursor{text: p.prefix, literal: true}
+	}
+	if c.leadingStar() {
+		// The leading star can start at 0 whenever there is a match.
+		return p.findRest(str, 0, 0, c, fold)
+	}
+	rest := c
+	first, ok := rest.next()
+	if !ok {
+		// The empty pattern matches the empty string at 0.
+		return 0, 0
+	}
+	for from := 0; from <= len(str); {
+		start, ok := first.candidate(str, from, fold)
+		if !ok {
+			return -1, -1
+		}
+		if end, ok := first.matchAt(p, str, start, fold); ok {
+			// Later starts only leave less room for the rest, so a failure
+			// here is final.
+			return p.findRest(str, start, end, rest, fold)
+		}
+		if start == len(str) {
+			break
+		}
+		_, size := decodeRune(str[start:])
+		from = start + size
+	}
+	return -1, -1
+}
+
+// findRest places the pieces left in c after from, each following a star:
+// the middle ones as early as possible and the last one as late as
+// possible, which is what greedy stars settle on.
+func (p *Pattern) findRest(str string, start, from int, c pieceCursor, fold bool) (int, int) {
+	trailing := c.trailingStar()
+	for {
+		next, ok := c.next()
+		if !ok {
+			if trailing {
+				return start, len(str)
+			}
+			return start, from
+		}
+		var end int
+		if c.done() && !trailing {
+			end, ok = next.lastEnd(p, str, from, fold)
+		} else {
+			_, end, ok = next.index(p, str, from, fold)
+		}
+		if !ok {
+			return -1, -1
+		}
+		from = end
+	}
+}
+
+// piece is a star-free part of a pattern: a literal, or tokens that each
+// consume a fixed number of runes.
+type piece struct {
+	lit    string
+	tokens []token
+}
+
+// candidate returns the first offset at or after from where the piece may
+// match, skipping ahead with indexLiteral when it starts with a literal.
+func (pc piece) candidate(str string, from int, fold bool) (int, bool) {
+	lit := pc.lit
+	if len(pc.tokens) > 0 && pc.tokens[0].kind == tokenLiteralRun {
+		lit = pc.tokens[0].lit
+	}
+	if lit == "" {
+		return from, true
+	}
+	index, _ := indexLiteral(str[from:], lit, fold)
+	if index < 0 {
+		return 0, false
+	}
+	return from + index, true
+}
+
+// matchAt reports whether the piece matches at offset and where it ends.
+func (pc piece) matchAt(p *Pattern, str string, offset int, fold bool) (int, bool) {
+	if pc.tokens == nil {
+		return consumeLiteralRun(str, offset, pc.lit, fold)
+	}
+	for i := range pc.tokens {
+		tok := &pc.tokens[i]
+		var ok bool
+		switch tok.kind {
+		case tokenAnyN:
+			offset, ok = consumeAnyN(str, offset, tok.count)
+		case tokenLiteralRun:
+			offset, ok = consumeLiteralRun(str, offset, tok.lit, fold)
+		default:
+			if offset < len(str) {
+				char, size := decodeRune(str[offset:])
+				ok = p.tokenMatches(tok, char, fold)
+				offset += size
+			}
+		}
+		if !ok {
+			return 0, false
+		}
+	}
+	return offset, true
+}
+
+// index returns the earliest match of the piece at or after from.
+func (pc piece) index(p *Pattern, str string, from int, fold bool) (int, int, bool) {
+	for from <= len(str) {
+		start, ok := pc.candidate(str, from, fold)
+		if !ok {
+			return 0, 0, false
+		}
+		if end, ok := pc.matchAt(p, str, start, fold); ok {
+			return start, end, true
+		}
+		if start == len(str) {
+			break
+		}
+		_, size := decodeRune(str[start:])
+		from = start + size
+	}
+	return 0, 0, false
+}
+
+// lastEnd returns the end of the latest match of the piece at or after
+// from. Pieces consume a fixed number of runes, so the latest start also
+// has the latest end.
+func (pc piece) lastEnd(p *Pattern, str string, from int, fold bool) (int, bool) {
+	if pc.tokens == nil && !fold {
+		index := strings.LastIndex(str[from:], pc.lit)
+		if index < 0 {
+			return 0, false
+		}
+		return from + index + len(pc.lit), true
+	}
+	last, found := 0, false
+	for {
+		start, end, ok := pc.index(p, str, from, fold)
+		if !ok {
+			return last, found
+		}
+		last, found = end, true
+		if start == len(str) {
+			return last, found
+		}
+		_, size := decodeRune(str[start:])
+		from = start + size
+	}
+}
+
+// pieceCursor walks the pieces of a tokenized pattern, or of the text of a
+// literal-stars pattern, skipping the stars between them.
+type pieceCursor struct {
+	tokens  []token
+	text    string
+	literal bool
+}
+
+func (c *pieceCursor) next() (piece, bool) {
+	if c.literal {
+		c.text = strings.TrimLeft(c.text, "*")
+		if c.text == "" {
+			return piece{}, false
+		}
+		end := strings.IndexByte(c.text, '*')
+		if end < 0 {
+			end = len(c.text)
+		}
+		pc := piece{lit: c.text[:end]}
+		c.text = c.text[end:]
+		return pc, true
+	}
+	for len(c.tokens) > 0 && c.tokens[0].kind == tokenStar {
+		c.tokens = c.tokens[1:]
+	}
+	if len(c.tokens) == 0 {
+		return piece{}, false
+	}
+	end := 0
+	for end < len(c.tokens) && c.tokens[end].kind != tokenStar {
+		end++
+	}
+	pc := piece{tokens: c.tokens[:end]}
+	c.tokens = c.tokens[end:]
+	return pc, true
+}
+
+// done reports whether no pieces are left.
+func (c *pieceCursor) done() bool {
+	if c.literal {
+		return strings.Trim(c.text, "*") == ""
+	}
+	for i := range c.tokens {
+		if c.tokens[i].kind != tokenStar {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *pieceCursor) leadingStar() bool {
+	if c.literal {
+		return strings.HasPrefix(c.text, "*")
+	}
+	return len(c.tokens) > 0 && c.tokens[0].kind == tokenStar
+}
+
+func (c *pieceCursor) trailingStar() bool {
+	if c.literal {
+		return strings.HasSuffix(c.text, "*")
+	}
+	return len(c.tokens) > 0 && c.tokens[len(c.tokens)-1].kind == tokenStar
+}
+
+// findRedis is find for ModeRedisBytes: the piece walk of find over
+// p.redis, a byte at a time, comparing with nocase as Redis does. Redis
+// matches the empty string only against the empty pattern, so a lone '*'
+// does not match an empty rest of str; every other pattern consumes a byte.
+func (p *Pattern) findRedis(str string, fold bool) (int, int) {
+	tokens := p.redis
+	switch {
+	case p.redisDeep:
+		return -1, -1
+	case len(tokens) == 0:
+		return 0, 0
+	case len(tokens) == 1 && tokens[0].kind == tokenStar:
+		if str == "" {
+			return -1, -1
+		}
+		return 0, len(str)
+	}
+	trailing := tokens[len(tokens)-1].kind == tokenStar
+	if tokens[0].kind == tokenStar {
+		return findRedisRest(str, 0, 0, tokens, trailing, fold)
+	}
+	first, rest := nextRedisPiece(tokens)
+	for from := 0; from <= len(str); {
+		start, ok := first.candidate(str, from, fold)
+		if !ok {
+			return -1, -1
+		}
+		if end, ok := first.matchAt(str, start, fold); ok {
+			return findRedisRest(str, start, end, rest, trailing, fold)
+		}
+		from = start + 1
+	}
+	return -1, -1
+}
+
+// findRedisRest is findRest for the Redis pieces left in tokens.
+func findRedisRest(str string, start, from int, tokens []redisToken, trailing, fold bool) (int, int) {
+	for {
+		var next redisPiece
+		if next, tokens = nextRedisPiece(tokens); next == nil {
+			if trailing {
+				return start, len(str)
+			}
+			return start, from
+		}
+		var end int
+		var ok bool
+		if last, _ := nextRedisPiece(tokens); last == nil && !trailing {
+			end, ok = next.lastEnd(str, from, fold)
+		} else {
+			_, end, ok = next.index(str, from, fold)
+		}
+		if !ok {
+			return -1, -1
+		}
+		from = end
+	}
+}
+
+// redisPiece is a star-free run of ModeRedisBytes tokens, which each
+// consume a fixed number of bytes.
+type redisPiece []redisToken
+
+// nextRedisPiece splits the first piece off tokens, skipping stars, and
+// returns nil if there is none.
+func nextRedisPiece(tokens []redisToken) (redisPiece, []redisToken) {
+	for len(tokens) > 0 && tokens[0].kind == tokenStar {
+		tokens = tokens[1:]
+	}
+	end := 0
+	for end < len(tokens) && tokens[end].kind != tokenStar {
+		end++
+	}
+	if end == 0 {
+		return nil, tokens
+	}
+	return redisPiece(tokens[:end]), tokens[end:]
+}
+
+func (pc redisPiece) candidate(str string, from int, fold bool) (int, bool) {
+	if pc[0].kind != tokenLiteralRun {
+		return from, from <= len(str)
+	}
+	var index int
+	if fold {
+		index = indexASCIIFoldShort(str[from:], pc[0].lit)
+	} else {
+		index = strings.Index(str[from:], pc[0].lit)
+	}
+	return from + index, index >= 0
+}
+
+func (pc redisPiece) matchAt(str string, offset int, fold bool) (int, bool) {
+	for i := range pc {
+		t := &pc[i]
+		switch t.kind {
+		case tokenLiteralRun:
+			if !hasRedisPrefix(str[offset:], t.lit, fold) {
+				return 0, false
+			}
+			offset += len(t.lit)
+		case tokenAnyN:
+			if len(str)-offset < t.count {
+				return 0, false
+			}
+			offset += t.count
+		default:
+			if offset == len(str) || !t.matchesByte(str[offset], fold) {
+				return 0, false
+			}
+			offset++
+		}
+	}
+	return offset, true
+}
+
+func (pc redisPiece) index(str string, from int, fold bool) (int, int, bool) {
+	for from <= len(str) {
+		start, ok := pc.candidate(str, from, fold)
+		if !ok {
+			return 0, 0, false
+		}
+		if end, ok := pc.matchAt(str, start, fold); ok {
+			return start, end, true
+		}
+		from = start + 1
+	}
+	return 0, 0, false
+}
+
+// lastEnd returns the end of the latest match of the piece at or after
+// from.
+func (pc redisPiece) lastEnd(str string, from int, fold bool) (int, bool) {
+	last, found := 0, false
+	for {
+		start, end, ok := pc.index(str, from, fold)
+		if !ok {
+			return last, found
+		}
+		last, found = end, true
+		from = start + 1
+	}
+}
@@ -0,0 +1,270 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindIndex(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		str        string
+		start, end int
+	}{
+		{"abc", "xxabcxx", 2, 5},
+		{"abc", "xxabxx", -1, -1},
+		{"", "abc", 0, 0},
+		{"*", "abc", 0, 3},
+		{"user=*", "ts=1 user=bob op=get", 5, 20},
+		{"*op=", "ts=1 op=get op=set", 0, 15},
+		{"a*b", "xaxbxbx", 1, 6},
+		{"a*b", "xaxx", -1, -1},
+		{"a*b*c", "ab_c_b_c_b", 0, 8},
+		{"a?c", "abd abc", 4, 7},
+		{"[0-9][0-9]:*:", "at 12:30:45 ok", 3, 9},
+		{"id=[0-9]*", "id=x id=7 end", 5, 13},
+		{"é?", "aébé", 1, 4},
+		{"*.go*.go", "a.go b.go c.go", 0, 14},
+		{"x*", "", -1, -1},
+		{"?*", "", -1, -1},
+		{"*?", "ab", 0, 2},
+	}
+	for _, tt := range tests {
+		p := Compile(tt.pattern)
+		start, end := p.FindIndex(tt.str)
+		if start != tt.start || end != tt.end {
+			t.Errorf("Compile(%q).FindIndex(%q) = %d, %d, want %d, %d", tt.pattern, tt.str, start, end, tt.start, tt.end)
+		}
+		start, end = p.FindIndexBytes([]byte(tt.str))
+		if start != tt.start || end != tt.end {
+			t.Errorf("Compile(%q).FindIndexBytes(%q) = %d, %d, want %d, %d", tt.pattern, tt.str, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestFindStringAndBytes(t *testing.T) {
+	p := Compile("user=[a-z]*")
+	if got := p.FindString("op=get user=bob"); got != "user=bob" {
+		t.Errorf("FindString() = %q", got)
+	}
+	if got := p.FindString("op=get"); got != "" {
+		t.Errorf("FindString() = %q", got)
+	}
+	if got := p.FindBytes([]byte("x user=a")); string(got) != "user=a" {
+		t.Errorf("FindBytes() = %q", got)
+	}
+	if got := p.FindBytes([]byte("x")); got != nil {
+		t.Errorf("FindBytes() = %q, want nil", got)
+	}
+}
+
+func TestFindAllIndex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		n       int
+		want    [][]int
+	}{
+		{"a?", "a1 a2 a3", -1, [][]int{{0, 2}, {3, 5}, {6, 8}}},
+		{"a?", "a1 a2 a3", 2, [][]int{{0, 2}, {3, 5}}},
+		{"a?", "a1 a2 a3", 0, nil},
+		{"a?", "xyz", -1, nil},
+		{"", "ab", -1, [][]int{{0, 0}, {1, 1}, {2, 2}}},
+		{"*", "ab", -1, [][]int{{0, 2}}},
+	}
+	for _, tt := range tests {
+		got := Compile(tt.pattern).FindAllIndex(tt.str, tt.n)
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("Compile(%q).FindAllIndex(%q, %d) = %v, want %v", tt.pattern, tt.str, tt.n, got, tt.want)
+		}
+		got = Compile(tt.pattern).FindAllIndexBytes([]byte(tt.str), tt.n)
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("Compile(%q).FindAllIndexBytes(%q, %d) = %v, want %v", tt.pattern, tt.str, tt.n, got, tt.want)
+		}
+	}
+}
+
+// unanchoredRegexp returns the regexp of p without \A and \z.
+func unanchoredRegexp(p *Pattern, fold bool) *regexp.Regexp {
+	expr := strings.Replace(strings.TrimSuffix(p.regexpString(fold), `\z`), `\A`, "", 1)
+	return regexp.MustCompile(expr)
+}
+
+// TestFindRegexp compares FindIndex and FindAllIndex with Go's regexp on
+// random patterns, with and without brace groups.
+func TestFindRegexp(t *testing.T) {
+	r := rand.New(rand.NewPCG(9, 10))
+	random := func(alphabet string, n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	for i := range 20000 {
+		var p *Pattern
+		var pattern string
+		if i%2 == 0 {
+			pattern = random("ab*?[]^-", 8)
+			p = Compile(pattern)
+		} else {
+			pattern = random("ab*?{,}", 9)
+			var err error
+			if p, err = (CompileOptions{Braces: true}).Compile(pattern); err != nil {
+				continue
+			}
+		}
+		if !p.valid {
+			continue
+		}
+		re := unanchoredRegexp(p, false)
+		str := random("abcA\xff", 10)
+		start, end := p.FindIndex(str)
+		want := re.FindStringIndex(str)
+		if want == nil {
+			want = []int{-1, -1}
+		}
+		if start != want[0] || end != want[1] {
+			t.Fatalf("Compile(%q).FindIndex(%q) = %d, %d, regexp %s = %v", pattern, str, start, end, re, want)
+		}
+		if got, want := p.FindAllIndex(str, -1), re.FindAllStringIndex(str, -1); !slices.EqualFunc(got, want, slices.Equal) {
+			t.Fatalf("Compile(%q).FindAllIndex(%q) = %v, regexp %s = %v", pattern, str, got, re, want)
+		}
+		re = unanchoredRegexp(p, true)
+		if got, want := p.FindAllIndexFold(str, -1), re.FindAllStringIndex(str, -1); !slices.EqualFunc(got, want, slices.Equal) {
+			t.Fatalf("Compile(%q).FindAllIndexFold(%q) = %v, regexp %s = %v", pattern, str, got, re, want)
+		}
+	}
+}
+
+func TestFindBraces(t *testing.T) {
+	p := compileBracesOption(t, "{a,ab}")
+	if start, end := p.FindIndex("xab"); start != 1 || end != 2 {
+		t.Errorf("FindIndex() = %d, %d, want the first alternative", start, end)
+	}
+	p = compileBracesOption(t, "level={warn,error} *")
+	if got := p.FindString("ts=1 level=error disk full"); got != "level=error disk full" {
+		t.Errorf("FindString() = %q", got)
+	}
+}
+
+func TestFindSeparatorAndRedis(t *testing.T) {
+	p := compileSeparator(t, "a/*", '/')
+	if start, end := p.FindIndex("x/a/bc/d"); start != 2 || end != 6 {
+		t.Errorf("Separator FindIndex() = %d, %d", start, end)
+	}
+	p = compileSeparator(t, "b/**", '/')
+	if start, end := p.FindIndexFold("aB/c/d"); start != 1 || end != 6 {
+		t.Errorf("Separator FindIndexFold() = %d, %d", start, end)
+	}
+	p = compileRedisMode(t, "a?c")
+	if start, end := p.FindIndex("xa\xffc"); start != 1 || end != 4 {
+		t.Errorf("ModeRedisBytes FindIndex() = %d, %d", start, end)
+	}
+	if start, end := p.FindIndexFold("xA\xffC"); start != 1 || end != 4 {
+		t.Errorf("ModeRedisBytes FindIndexFold() = %d, %d", start, end)
+	}
+	// Redis matches the empty string only against the empty pattern.
+	p = compileRedisMode(t, "*")
+	if got := p.FindAllIndex("ab", -1); !slices.EqualFunc(got, [][]int{{0, 2}}, slices.Equal) {
+		t.Errorf("ModeRedisBytes FindAllIndex() = %v", got)
+	}
+	if start, _ := p.FindIndex(""); start != -1 {
+		t.Errorf(`ModeRedisBytes FindIndex("") = %d, want -1`, start)
+	}
+}
+
+// findLongest is the leftmost-longest search that FindIndex does for
+// Separator and ModeRedisBytes patterns, by trying every substring.
+func findLongest(p *Pattern, str string, fold bool) (int, int) {
+	step := func(i int) int {
+		if p.mode == ModeRedisBytes {
+			return i + 1
+		}
+		_, size := decodeRune(str[i:])
+		return i + size
+	}
+	for start := 0; start <= len(str); start = step(start) {
+		end := -1
+		for i := start; ; i = step(i) {
+			if p.match(str[start:i], fold) {
+				end = i
+			}
+			if i == len(str) {
+				break
+			}
+		}
+		if end >= 0 {
+			return start, end
+		}
+		if start == len(str) {
+			break
+		}
+	}
+	return -1, -1
+}
+
+func TestFindSeparatorAndRedisRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(11, 12))
+	random := func(alphabet string, n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	options := []struct {
+		opts              CompileOptions
+		alphabet, strings string
+	}{
+		{CompileOptions{Separator: '/'}, "ab/*?[]^", "abA/\xff"},
+		{CompileOptions{Separator: '/', Braces: true}, "ab/*?{,}", "abA/,"},
+		{CompileOptions{Mode: ModeRedisBytes}, "ab*?[]^\\\xff", "abA\xff\\"},
+	}
+	for _, o := range options {
+		for range 5000 {
+			pattern := random(o.alphabet, 8)
+			p, err := o.opts.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			str := random(o.strings, 10)
+			for _, fold := range []bool{false, true} {
+				start, end := p.find(str, fold)
+				wantStart, wantEnd := findLongest(p, str, fold)
+				if start != wantStart || end != wantEnd {
+					t.Fatalf("%+v: Compile(%q).find(%q, %v) = %d, %d, want %d, %d",
+						o.opts, pattern, str, fold, start, end, wantStart, wantEnd)
+				}
+			}
+		}
+	}
+}
+
+func TestFindAllocs(t *testing.T) {
+	str := "ts=2024-01-01 level=error user=bob msg=disk full"
+	for _, pattern := range []string{"user=*", "level=* user=*", "msg=[a-z]?*", "*full"} {
+		p := Compile(pattern)
+		if allocs := testing.AllocsPerRun(100, func() { p.FindIndex(str) }); allocs != 0 {
+			t.Errorf("Compile(%q).FindIndex allocates %v times", pattern, allocs)
+		}
+		if allocs := testing.AllocsPerRun(100, func() { p.FindIndexFold(str) }); allocs != 0 {
+			t.Errorf("Compile(%q).FindIndexFold allocates %v times", pattern, allocs)
+		}
+		redis := compileRedisMode(t, pattern)
+		if allocs := testing.AllocsPerRun(100, func() { redis.FindIndexFold(str) }); allocs != 0 {
+			t.Errorf("Compile(%q, ModeRedisBytes).FindIndexFold allocates %v times", pattern, allocs)
+		}
+	}
+}
+
+func BenchmarkFindIndex(b *testing.B) {
+	p := Compile("user=[a-z]* ")
+	str := strings.Repeat("ts=2024-01-01 level=info ", 8) + "user=bob op=get"
+	for b.Loop() {
+		p.FindIndex(str)
+	}
+}
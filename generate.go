@@ -0,0 +1,296 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"unicode/utf8"
+)
+
+// DefaultMaxStar is the largest number of runes a '*' expands to when
+// GenOptions.MaxStar is zero.
+const DefaultMaxStar = 8
+
+// GenOptions configures Generate and GenerateNonMatch.
+type GenOptions struct {
+	// MaxStar is the largest number of runes a '*' expands to, and of
+	// segments a "**" expands to with a Separator. Zero means
+	// DefaultMaxStar and a negative value makes every '*' and "**" empty.
+	MaxStar int
+	// Alphabet holds the runes drawn for '*', '?' and negated classes. When
+	// it is empty, runes are drawn from printable ASCII, or from a mix of
+	// ASCII, Latin-1, Greek, CJK and emoji if Unicode is set. Non-negated
+	// classes always draw from their own ranges.
+	Alphabet []rune
+	// Unicode selects the non-ASCII default alphabet.
+	Unicode bool
+}
+
+// unicodeAlphabet is the default alphabet with Unicode set.
+var unicodeAlphabet = []charRange{
+	{' ', '~'},
+	{0xa1, 0xff},
+	{0x391, 0x3c9},
+	{0x4e00, 0x4fff},
+	{0x1f600, 0x1f64f},
+}
+
+func (opts GenOptions) maxStar() int {
+	switch {
+	case opts.MaxStar == 0:
+		return DefaultMaxStar
+	case opts.MaxStar < 0:
+		return 0
+	default:
+		return opts.MaxStar
+	}
+}
+
+func (opts GenOptions) randomRune(r *rand.Rand) rune {
+	switch {
+	case len(opts.Alphabet) > 0:
+		return opts.Alphabet[r.IntN(len(opts.Alphabet))]
+	case opts.Unicode:
+		cr := unicodeAlphabet[r.IntN(len(unicodeAlphabet))]
+		return cr.start + r.Int32N(cr.end-cr.start+1)
+	default:
+		return ' ' + r.Int32N('~'-' '+1)
+	}
+}
+
+// Generate returns a random string that p matches, and reports false if p
+// matches no string, as for a nil or invalid Pattern or one with an empty
+// class such as "[]". Stars expand to between zero and opts.MaxStar runes.
+// It is meant for property tests of code that routes keys by pattern.
+//
+// With a Separator, wildcards and classes never produce the separator, and
+// a "**" segment expands to between zero and opts.MaxStar segments. In
+// ModeRedisBytes, '?' produces one byte of the encoding of a rune from the
+// alphabet and a class any byte it matches; since Redis matches no empty
+// string against a non-empty pattern, a pattern of only '*' produces at
+// least one rune.
+func (p *Pattern) Generate(r *rand.Rand, opts GenOptions) (string, bool) {
+	g := &generator{r: r, opts: opts}
+	switch {
+	case p == nil || !p.valid || p.redisDeep:
+		return "", false
+	case p.mode == ModeRedisBytes:
+		return g.redis(p.redis)
+	case p.segments != nil:
+		g.sep = p.separator
+		return g.segments(p.segments)
+	}
+	buf, ok := g.append(nil, p.tokenStream())
+	return string(buf), ok
+}
+
+// generator holds the state of a Generate call. sep, if not zero, is a
+// separator that wildcards and classes must not produce.
+type generator struct {
+	r    *rand.Rand
+	opts GenOptions
+	sep  rune
+}
+
+// append appends a random string that tokens match to buf, or reports false
+// if they match none.
+func (g *generator) append(buf []byte, tokens []token) ([]byte, bool) {
+	for i := range tokens {
+		t := &tokens[i]
+		switch t.kind {
+		case tokenLiteral:
+			buf = utf8.AppendRune(buf, t.char)
+		case tokenLiteralRun:
+			buf = append(buf, t.lit...)
+		case tokenAny, tokenAnyN:
+			for range max(t.count, 1) {
+				char, ok := g.pick(g.notSep)
+				if !ok {
+					return buf, false
+				}
+				buf = utf8.AppendRune(buf, char)
+			}
+		case tokenStar:
+			buf = g.appendStar(buf)
+		case tokenClass:
+			char, ok := g.class(t.class)
+			if !ok {
+				return buf, false
+			}
+			buf = utf8.AppendRune(buf, char)
+		case tokenAlt:
+			// Try the alternatives in random order, since some may match
+			// nothing.
+			n := len(buf)
+			ok := false
+			for _, k := range g.r.Perm(len(t.alt.alts)) {
+				if buf, ok = g.append(buf[:n], t.alt.alts[k]); ok {
+					break
+				}
+			}
+			if !ok {
+				return buf, false
+			}
+		}
+	}
+	return buf, true
+}
+
+// appendStar appends up to opts.MaxStar runes from the alphabet, skipping
+// the separator.
+func (g *generator) appendStar(buf []byte) []byte {
+	for range g.r.IntN(g.opts.maxStar() + 1) {
+		if char := g.opts.randomRune(g.r); g.notSep(char) {
+			buf = utf8.AppendRune(buf, char)
+		}
+	}
+	return buf
+}
+
+func (g *generator) notSep(char rune) bool {
+	return g.sep == 0 || char != g.sep
+}
+
+// pick returns a random rune from the alphabet that accept allows, or, if
+// the alphabet seems to hold none, the first valid rune that it allows.
+func (g *generator) pick(accept func(rune) bool) (rune, bool) {
+	for range 64 {
+		if char := g.opts.randomRune(g.r); accept(char) {
+			return char, true
+		}
+	}
+	for char := rune(0); char <= utf8.MaxRune; char++ {
+		if utf8.ValidRune(char) && accept(char) {
+			return char, true
+		}
+	}
+	return 0, false
+}
+
+// class returns a random rune that class matches. A negated class draws
+// from the alphabet, and any other from its own ranges.
+func (g *generator) class(class *compiledClass) (rune, bool) {
+	if class.negated {
+		return g.pick(func(char rune) bool {
+			return !class.contains(char) && g.notSep(char)
+		})
+	}
+	ranges := class.rangeList()
+	if len(ranges) == 0 {
+		return 0, false
+	}
+	// Ranges may span the surrogates, which are not valid runes. Their
+	// endpoints are decoded runes and always valid.
+	for range 64 {
+		cr := ranges[g.r.IntN(len(ranges))]
+		if char := cr.start + g.r.Int32N(cr.end-cr.start+1); utf8.ValidRune(char) && g.notSep(char) {
+			return char, true
+		}
+	}
+	for _, cr := range ranges {
+		for _, char := range [2]rune{cr.start, cr.end} {
+			if g.notSep(char) {
+				return char, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// segments generates each segment of a Separator pattern, expanding a "**"
+// to up to opts.MaxStar segments, and joins them with the separator.
+func (g *generator) segments(segments []*Pattern) (string, bool) {
+	var parts [][]byte
+	for _, segment := range segments {
+		if segment == nil {
+			for range g.r.IntN(g.opts.maxStar() + 1) {
+				parts = append(parts, g.appendStar(nil))
+			}
+			continue
+		}
+		part, ok := g.append(nil, segment.tokenStream())
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	var buf []byte
+	for i, part := range parts {
+		if i > 0 {
+			buf = utf8.AppendRune(buf, g.sep)
+		}
+		buf = append(buf, part...)
+	}
+	return string(buf), true
+}
+
+// redis generates a string of bytes that ModeRedisBytes tokens match.
+func (g *generator) redis(tokens []redisToken) (string, bool) {
+	var buf []byte
+	for i := range tokens {
+		t := &tokens[i]
+		switch t.kind {
+		case tokenLiteralRun:
+			buf = append(buf, t.lit...)
+		case tokenAnyN:
+			for range t.count {
+				var b [utf8.UTFMax]byte
+				n := utf8.EncodeRune(b[:], g.opts.randomRune(g.r))
+				buf = append(buf, b[g.r.IntN(n)])
+			}
+		case tokenStar:
+			buf = g.appendStar(buf)
+		case tokenClass:
+			var members []byte
+			for c := range 256 {
+				if t.class[0].contains(byte(c)) {
+					members = append(members, byte(c))
+				}
+			}
+			if len(members) == 0 {
+				return "", false
+			}
+			buf = append(buf, members[g.r.IntN(len(members))])
+		}
+	}
+	if len(buf) == 0 && len(tokens) > 0 {
+		// Only a lone '*' consumes nothing.
+		buf = utf8.AppendRune(buf, g.opts.randomRune(g.r))
+	}
+	return string(buf), true
+}
+
+// GenerateNonMatch returns a random string that p does not match but that
+// is one edit (a rune inserted, deleted or replaced) away from a string
+// Generate could return. It reports false if no such string was found, as
+// for "*", which matches everything.
+//
+// It also reports false if p matches no string.
+func (p *Pattern) GenerateNonMatch(r *rand.Rand, opts GenOptions) (string, bool) {
+	for range 64 {
+		match, ok := p.Generate(r, opts)
+		if !ok {
+			return "", false
+		}
+		var offsets []int
+		for i := 0; i < len(match); {
+			offsets = append(offsets, i)
+			_, size := decodeRune(match[i:])
+			i += size
+		}
+		offsets = append(offsets, len(match))
+		k := r.IntN(len(offsets))
+		at := offsets[k]
+		var edited string
+		switch op := r.IntN(3); {
+		case op == 0 || at == len(match):
+			edited = match[:at] + string(opts.randomRune(r)) + match[at:]
+		case op == 1:
+			edited = match[:at] + match[offsets[k+1]:]
+		default:
+			edited = match[:at] + string(opts.randomRune(r)) + match[offsets[k+1]:]
+		}
+		if !p.Match(edited) {
+			return edited, true
+		}
+	}
+	return "", false
+}
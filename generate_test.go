@@ -0,0 +1,138 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGenerate(t *testing.T) {
+	patterns := []string{
+		"", "*", "?", "user:*", "*:session", "a?c", "???*",
+		"[a-c]x[^0-9]", "[^]", "[^a-zA-Z0-9 -~]", `\*\?\[`, "前*後",
+		"[z-a]*[\u00e9-\u00eb]", "[\U0001F600-\U0001F64F]", "[\ud7ff-\ue000]",
+		"*a*b*c*", string([]byte{'a', 0xff, '*'}), string([]byte{'?', 0xff, '[', 'a', ']'}),
+	}
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, pattern := range patterns {
+		p := Compile(pattern)
+		for _, opts := range []GenOptions{{}, {Unicode: true}, {MaxStar: 40}, {Alphabet: []rune("ab")}} {
+			for range 100 {
+				if s, ok := p.Generate(r, opts); !ok || !p.Match(s) {
+					t.Fatalf("Compile(%q).Generate(%+v) = %q, which does not match", pattern, opts, s)
+				}
+				if s, ok := p.GenerateNonMatch(r, opts); ok && p.Match(s) {
+					t.Fatalf("Compile(%q).GenerateNonMatch(%+v) = %q, which matches", pattern, opts, s)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	generate := func(pattern string, opts GenOptions) string {
+		s, ok := Compile(pattern).Generate(r, opts)
+		if !ok {
+			t.Fatalf("Compile(%q).Generate() reported false", pattern)
+		}
+		return s
+	}
+	for range 100 {
+		if s := generate("a*b", GenOptions{MaxStar: -1}); s != "ab" {
+			t.Fatalf(`Generate with MaxStar -1 = %q, want "ab"`, s)
+		}
+		if s := generate("*", GenOptions{MaxStar: 5, Alphabet: []rune("xy")}); len(s) > 5 || strings.Trim(s, "xy") != "" {
+			t.Fatalf("Generate with alphabet xy = %q", s)
+		}
+		if s := generate("*?*", GenOptions{}); !isPrintableASCII(s) {
+			t.Fatalf("Generate with default alphabet = %q, want printable ASCII", s)
+		}
+		if s := generate("[^a]", GenOptions{Alphabet: []rune("ab")}); s != "b" {
+			t.Fatalf(`Generate("[^a]") with alphabet ab = %q, want "b"`, s)
+		}
+		if s := generate("[^ -~]", GenOptions{}); utf8.RuneCountInString(s) != 1 || isPrintableASCII(s) {
+			t.Fatalf(`Generate("[^ -~]") = %q, want one rune outside printable ASCII`, s)
+		}
+	}
+}
+
+func TestGenerateNonMatch(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	if s, ok := Compile("*").GenerateNonMatch(r, GenOptions{}); ok {
+		t.Errorf(`Compile("*").GenerateNonMatch() = %q, true, want false`, s)
+	}
+	if _, ok := Compile("user:[0-9]").GenerateNonMatch(r, GenOptions{}); !ok {
+		t.Errorf(`Compile("user:[0-9]").GenerateNonMatch() reported false`)
+	}
+}
+
+func TestGenerateNoMatch(t *testing.T) {
+	redis := compileRedisMode(t, "a[]")
+	deep := compileRedisMode(t, strings.Repeat("a*", 1002))
+	braces, err := CompileOptions{Braces: true}.Compile("{[^\x00-\U0010FFFF],a[^\x00-\U0010FFFF]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment, err := CompileOptions{Separator: '/'}.Compile("a/[/]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewPCG(0, 0))
+	for _, p := range []*Pattern{nil, Compile("a[]"), Compile("a[b"), redis, deep, braces, segment} {
+		if s, ok := p.Generate(r, GenOptions{}); ok {
+			t.Errorf("Generate() = %q, true for a pattern that matches nothing", s)
+		}
+		if s, ok := p.GenerateNonMatch(r, GenOptions{}); ok {
+			t.Errorf("GenerateNonMatch() = %q, true for a pattern that matches nothing", s)
+		}
+	}
+}
+
+func TestGenerateModes(t *testing.T) {
+	patterns := []struct {
+		opts    CompileOptions
+		pattern string
+	}{
+		{CompileOptions{Separator: '/'}, "a/*/?"},
+		{CompileOptions{Separator: '/'}, "**"},
+		{CompileOptions{Separator: '/'}, "a/**/[^x]/**"},
+		{CompileOptions{Separator: '/', Braces: true}, "{src,test}/**/*.{go,mod}"},
+		{CompileOptions{Separator: ':'}, "user:[:a]:**"},
+		{CompileOptions{Braces: true}, "{x,y}{1..3}"},
+		{CompileOptions{Separator: '/', Braces: true}, "{a[/],b}/c"},
+		{CompileOptions{Mode: ModeRedisBytes}, ""},
+		{CompileOptions{Mode: ModeRedisBytes}, "*"},
+		{CompileOptions{Mode: ModeRedisBytes}, "?"},
+		{CompileOptions{Mode: ModeRedisBytes}, "a?[^a-y]*\\\xff"},
+		{CompileOptions{Mode: ModeRedisBytes}, "[\xe9-\xff]??"},
+		{CompileOptions{Mode: ModeRedisBytes}, "[a"},
+	}
+	r := rand.New(rand.NewPCG(7, 8))
+	for _, tt := range patterns {
+		p, err := tt.opts.Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range []GenOptions{{}, {Unicode: true}, {MaxStar: -1}, {Alphabet: []rune("/:é")}} {
+			for range 100 {
+				if s, ok := p.Generate(r, opts); !ok || !p.Match(s) {
+					t.Fatalf("%+v: Compile(%q).Generate(%+v) = %q, %v, which does not match", tt.opts, tt.pattern, opts, s, ok)
+				}
+				if s, ok := p.GenerateNonMatch(r, opts); ok && p.Match(s) {
+					t.Fatalf("%+v: Compile(%q).GenerateNonMatch(%+v) = %q, which matches", tt.opts, tt.pattern, opts, s)
+				}
+			}
+		}
+	}
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package redglob
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// slotCount is the number of hash slots in a Redis Cluster.
+const slotCount = 16384
+
+// KeySlot returns the Redis Cluster hash slot of key, as CLUSTER KEYSLOT
+// does: the CRC16 of the hash tag, the text between the first '{' and the
+// next '}', if it is not empty, or else of the whole key.
+func KeySlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % slotCount
+}
+
+// HashTag returns the Redis Cluster hash tag that every key the pattern
+// matches has in common, so that all of them live in one slot, as Redis 7
+// uses to run SCAN MATCH on a single node. For example "user:{42}:*" has
+// the tag "42".
+//
+// The text up to the closing '}' must be fixed, apart from single
+// characters before the '{' that can never be a '{', such as "[a-z]".
+// Escaped braces count as literal braces. A '*' or '?' before the '}', a
+// wildcard inside the tag, and an empty tag as in "{}" all give ok ==
+// false, since keys matching the pattern may then hash differently.
+// Comparisons are those of Match, not MatchFold.
+func (p *Pattern) HashTag() (tag string, ok bool) {
+	if p == nil || !p.valid {
+		return "", false
+	}
+	var s hashTagScanner
+	var done bool
+	switch {
+	case p.mode == ModeRedisBytes:
+		done, ok = s.redis(p.source)
+	case p.segments != nil:
+		for i, segment := range p.segments {
+			if i > 0 && s.literal(string(p.separator)) {
+				done, ok = true, true
+				break
+			}
+			if segment == nil {
+				// "**" may hold any text, including braces.
+				return "", false
+			}
+			if done, ok = s.tokens(segment, segment.tokenStream()); done || !ok {
+				break
+			}
+		}
+	default:
+		done, ok = s.tokens(p, p.tokenStream())
+	}
+	if !done || !ok || len(s.tag) == 0 {
+		return "", false
+	}
+	return string(s.tag), true
+}
+
+// Slot returns the hash slot every key the pattern matches lives in: the
+// slot of its HashTag, or of the key itself for a pattern without
+// wildcards. ok is false when matches may be spread over several slots.
+func (p *Pattern) Slot() (slot uint16, ok bool) {
+	if tag, ok := p.HashTag(); ok {
+		return crc16(tag) % slotCount, true
+	}
+	if key, complete := p.LiteralPrefix(); complete {
+		return KeySlot(key), true
+	}
+	return 0, false
+}
+
+// hashTagScanner follows the hash tag rule of KeySlot over the fixed text
+// at the start of a pattern.
+type hashTagScanner struct {
+	open bool
+	tag  []byte
+}
+
+// literal feeds fixed text and reports whether it closes the tag.
+func (s *hashTagScanner) literal(text string) bool {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case !s.open:
+			s.open = c == '{'
+		case c == '}':
+			return true
+		default:
+			s.tag = append(s.tag, c)
+		}
+	}
+	return false
+}
+
+// tokens feeds the tokens of p. It reports done once the tag is closed, and
+// ok == false at the first token that lets the tag vary.
+func (s *hashTagScanner) tokens(p *Pattern, tokens []token) (done, ok bool) {
+	for i := range tokens {
+		tok := &tokens[i]
+		switch {
+		case tok.kind == tokenLiteralRun:
+			if s.literal(tok.lit) {
+				return true, true
+			}
+		case tok.kind == tokenLiteral && tok.char != utf8.RuneError:
+			if s.literal(string(tok.char)) {
+				return true, true
+			}
+		case !s.open && (tok.kind == tokenLiteral || tok.kind == tokenClass) && !p.tokenMatches(tok, '{', false):
+			// A single rune that is never '{' cannot move the tag.
+		default:
+			return false, false
+		}
+	}
+	return false, true
+}
+
+// redis is tokens for a ModeRedisBytes pattern. As in Redis, any wildcard
+// or class before the tag is closed lets it vary.
+func (s *hashTagScanner) redis(pattern string) (done, ok bool) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[':
+			return false, false
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		if s.literal(pattern[i : i+1]) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// crc16Table is the table of CRC16/XMODEM (polynomial 0x1021, initial value
+// 0), the checksum Redis Cluster uses for key slots.
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
@@ -0,0 +1,125 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestKeySlot(t *testing.T) {
+	if got := crc16("123456789"); got != 0x31c3 {
+		t.Errorf("crc16(%q) = %#x, want 0x31c3", "123456789", got)
+	}
+	// Values from CLUSTER KEYSLOT.
+	tests := []struct {
+		key  string
+		slot uint16
+	}{
+		{"somekey", 11058},
+		{"foo", 12182},
+		{"foo{hash_tag}", 2515},
+		{"{hash_tag}bar", 2515},
+		{"{}hash_tag", KeySlot("{}hash_tag")},
+		{"{user1000}.following", KeySlot("user1000")},
+		{"foo{}{bar}", crc16("foo{}{bar}") % slotCount},
+		{"foo{{bar}}zap", crc16("{bar") % slotCount},
+		{"foo{bar}{zap}", crc16("bar") % slotCount},
+	}
+	for _, tt := range tests {
+		if got := KeySlot(tt.key); got != tt.slot {
+			t.Errorf("KeySlot(%q) = %d, want %d", tt.key, got, tt.slot)
+		}
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	tests := []struct {
+		opts    CompileOptions
+		pattern string
+		tag     string
+		ok      bool
+	}{
+		{CompileOptions{}, "user:{42}:*", "42", true},
+		{CompileOptions{}, "{42}*", "42", true},
+		{CompileOptions{}, "foo{bar}{zap}*", "bar", true},
+		{CompileOptions{}, "[a-z]:{42}:*", "42", true},
+		{CompileOptions{}, "[^{]{42}*", "42", true},
+		{CompileOptions{}, `\{42}*`, "42", true},
+		{CompileOptions{}, "{42}[0-9]?", "42", true},
+		{CompileOptions{}, "{é}*", "é", true},
+		{CompileOptions{}, "*{42}", "", false},
+		{CompileOptions{}, "?{42}", "", false},
+		{CompileOptions{}, "[^a]{42}", "", false},
+		{CompileOptions{}, "{4?}", "", false},
+		{CompileOptions{}, "{4*}", "", false},
+		{CompileOptions{}, "{[0-9]}", "", false},
+		{CompileOptions{}, "{}*", "", false},
+		{CompileOptions{}, "user:42", "", false},
+		{CompileOptions{}, "{42", "", false},
+		{CompileOptions{Mode: ModeRedisBytes}, "user:{42}:*", "42", true},
+		{CompileOptions{Mode: ModeRedisBytes}, `\{{42}*`, "{42", true},
+		{CompileOptions{Mode: ModeRedisBytes}, "[a]{42}*", "", false},
+		{CompileOptions{Separator: ':'}, "user:{42}:*", "42", true},
+		{CompileOptions{Separator: '{'}, "a{b}*", "b", true},
+		{CompileOptions{Separator: ':'}, "**:{42}:*", "", false},
+		{CompileOptions{Braces: true}, `user:\{42\}:{a,b}`, "42", true},
+		{CompileOptions{Braces: true}, "user:{a,b}:x", "", false},
+	}
+	for _, tt := range tests {
+		p, err := tt.opts.Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag, ok := p.HashTag(); tag != tt.tag || ok != tt.ok {
+			t.Errorf("Compile(%q).HashTag() = %q, %v, want %q, %v", tt.pattern, tag, ok, tt.tag, tt.ok)
+		}
+	}
+	if _, ok := Compile("{42}[").HashTag(); ok {
+		t.Error("invalid pattern has a hash tag")
+	}
+}
+
+func TestSlot(t *testing.T) {
+	if slot, ok := Compile("user:{42}:*").Slot(); slot != KeySlot("42") || !ok {
+		t.Errorf("Slot() = %d, %v", slot, ok)
+	}
+	if slot, ok := Compile("somekey").Slot(); slot != 11058 || !ok {
+		t.Errorf("Slot() = %d, %v", slot, ok)
+	}
+	if slot, ok := Compile("somekey{}").Slot(); slot != KeySlot("somekey{}") || !ok {
+		t.Errorf("Slot() = %d, %v", slot, ok)
+	}
+	if _, ok := Compile("user:*").Slot(); ok {
+		t.Error("Slot() of user:* = ok")
+	}
+}
+
+// TestSlotGenerate checks that every generated match of a pattern with a
+// slot hashes to it.
+func TestSlotGenerate(t *testing.T) {
+	r := rand.New(rand.NewPCG(17, 18))
+	parts := []string{"a", "{", "}", "*", "?", "[a{]", "[^{]", "[ab]", `\{`, `\}`}
+	opts := GenOptions{Alphabet: []rune("a{}")}
+	checked := 0
+	for range 20000 {
+		var b strings.Builder
+		for range r.IntN(6) {
+			b.WriteString(parts[r.IntN(len(parts))])
+		}
+		pattern := b.String()
+		p := Compile(pattern)
+		slot, ok := p.Slot()
+		if !ok {
+			continue
+		}
+		checked++
+		for range 10 {
+			if key, ok := p.Generate(r, opts); ok && KeySlot(key) != slot {
+				t.Fatalf("Compile(%q).Slot() = %d, but match %q is in slot %d", pattern, slot, key, KeySlot(key))
+			}
+		}
+	}
+	if checked < 500 {
+		t.Errorf("only %d patterns had a slot", checked)
+	}
+}
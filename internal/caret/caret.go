@@ -0,0 +1,37 @@
+// Package caret formats the syntax errors of redglob and its subpackages.
+package caret
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column returns the 1-based rune column of the byte at offset in input.
+func Column(input string, offset int) int {
+	return utf8.RuneCountInString(input[:offset]) + 1
+}
+
+// Format formats an error on three lines: "prefix: message at column N",
+// the input, and a caret under the byte at offset.
+func Format(prefix, message, input string, offset int) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(": ")
+	b.WriteString(message)
+	b.WriteString(" at column ")
+	b.WriteString(strconv.Itoa(Column(input, offset)))
+	b.WriteString("\n\t")
+	b.WriteString(input)
+	b.WriteString("\n\t")
+	// Keep tabs so the caret lines up with the input above it.
+	for _, char := range input[:offset] {
+		if char == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteByte('^')
+	return b.String()
+}
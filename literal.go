@@ -0,0 +1,275 @@
+package redglob
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// literalAffixes returns the literal text every match must start and end
+// with. exact reports that the pattern has no wildcards, in which case prefix
+// is the only string it matches and suffix is empty.
+func (p *Pattern) literalAffixes() (prefix, suffix string, exact bool) {
+	tokens := p.tokenStream()
+	head := 0
+	var b strings.Builder
+	for ; head < len(tokens) && appendLiteralToken(&b, &tokens[head]); head++ {
+	}
+	prefix = b.String()
+	if head == len(tokens) {
+		return prefix, "", true
+	}
+	tail := len(tokens)
+	for tail > head && isLiteralToken(&tokens[tail-1]) {
+		tail--
+	}
+	b.Reset()
+	for i := tail; i < len(tokens); i++ {
+		appendLiteralToken(&b, &tokens[i])
+	}
+	return prefix, b.String(), false
+}
+
+// literalFactors returns the maximal literal runs that every match must
+// contain, in pattern order.
+func (p *Pattern) literalFactors() []string {
+	var factors []string
+	var b strings.Builder
+	tokens := p.tokenStream()
+	for i := range tokens {
+		if appendLiteralToken(&b, &tokens[i]) {
+			continue
+		}
+		if b.Len() > 0 {
+			factors = append(factors, b.String())
+			b.Reset()
+		}
+	}
+	if b.Len() > 0 {
+		factors = append(factors, b.String())
+	}
+	return factors
+}
+
+// isLiteralToken reports whether tok matches exactly one byte sequence. A
+// RuneError literal stands for any invalid byte, so it is not literal.
+func isLiteralToken(tok *token) bool {
+	switch tok.kind {
+	case tokenLiteralRun:
+		return true
+	case tokenLiteral:
+		return tok.char != utf8.RuneError
+	default:
+		return false
+	}
+}
+
+func appendLiteralToken(b *strings.Builder, tok *token) bool {
+	if !isLiteralToken(tok) {
+		return false
+	}
+	if tok.kind == tokenLiteralRun {
+		b.WriteString(tok.lit)
+	} else {
+		b.WriteRune(tok.char)
+	}
+	return true
+}
+
+// LiteralPrefix returns a literal string that must begin any string Match
+// accepts. It returns complete == true if the literal string comprises the
+// entire pattern. MatchFold may also accept other casings of the prefix.
+func (p *Pattern) LiteralPrefix() (prefix string, complete bool) {
+	if p == nil || !p.valid {
+		return "", false
+	}
+	if p.mode == ModeRedisBytes {
+		end := 0
+		for end < len(p.source) && p.source[end] != '?' {
+			if p.source[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		lo, _, stop := redisLiteralBounds(p.source[:min(end, len(p.source))], false)
+		return lo, stop == len(p.source)
+	}
+	if p.segments != nil {
+		return p.segmentsLiteralPrefix()
+	}
+	prefix, _, complete = p.literalAffixes()
+	return prefix, complete
+}
+
+// KeyRange returns the tightest byte-wise range [lo, hi) that contains every
+// string Match accepts, for turning a pattern into a range scan over sorted
+// keys. An empty hi means the range is unbounded above. ok is false when the
+// pattern gives no bound at all, as for "*" or a pattern that never matches.
+//
+// Leading literals, '?' and non-negated classes all narrow the range: the
+// bounds follow the smallest and largest choice at each position up to the
+// first '*', so "[a-c]x*" yields ["ax", "cy").
+func (p *Pattern) KeyRange() (lo, hi string, ok bool) {
+	return p.keyRange(false)
+}
+
+// KeyRangeFold is like KeyRange for MatchFold. The range is wider because each
+// literal letter may appear in any of its case-folded forms.
+func (p *Pattern) KeyRangeFold() (lo, hi string, ok bool) {
+	return p.keyRange(true)
+}
+
+func (p *Pattern) keyRange(fold bool) (lo, hi string, ok bool) {
+	if p == nil || !p.valid {
+		return "", "", false
+	}
+	var upper string
+	var complete bool
+	if p.mode == ModeRedisBytes {
+		var end int
+		lo, upper, end = redisLiteralBounds(p.source, fold)
+		complete = end == len(p.source)
+	} else if p.segments != nil {
+		lo, upper, complete = p.segmentsBounds(fold)
+	} else {
+		lo, upper, complete = tokenBounds(p.tokenStream(), fold)
+	}
+	if complete {
+		// Matches are no longer than upper, so the next string after it is
+		// a tight exclusive bound.
+		return lo, upper + "\x00", true
+	}
+	hi = successor(upper)
+	return lo, hi, lo != "" || hi != ""
+}
+
+// tokenBounds returns the smallest and largest byte strings a match can
+// start with, taking the extreme choice for each token up to the first one
+// it cannot bound. UTF-8 encodings are prefix-free and ordered like code
+// points, so concatenating per-token extremes yields extreme prefixes.
+func tokenBounds(tokens []token, fold bool) (lo, hi string, complete bool) {
+	var loBuf, hiBuf []byte
+	for i := range tokens {
+		tok := &tokens[i]
+		var ok bool
+		switch tok.kind {
+		case tokenLiteral:
+			ok = tok.char != utf8.RuneError
+			if ok {
+				loBuf, hiBuf = appendRuneBounds(loBuf, hiBuf, tok.char, fold)
+			}
+		case tokenLiteralRun:
+			ok = true
+			for _, char := range tok.lit {
+				loBuf, hiBuf = appendRuneBounds(loBuf, hiBuf, char, fold)
+			}
+		case tokenAny, tokenAnyN:
+			ok = true
+			for range max(tok.count, 1) {
+				loBuf, hiBuf = append(loBuf, 0), append(hiBuf, 0xff)
+			}
+		case tokenClass:
+			var minChar, maxChar rune
+			if minChar, maxChar, ok = tok.class.bounds(fold); ok {
+				loBuf = utf8.AppendRune(loBuf, minChar)
+				hiBuf = utf8.AppendRune(hiBuf, maxChar)
+			}
+		}
+		if !ok {
+			return string(loBuf), string(hiBuf), false
+		}
+	}
+	return string(loBuf), string(hiBuf), true
+}
+
+func appendRuneBounds(lo, hi []byte, char rune, fold bool) ([]byte, []byte) {
+	minChar, maxChar := char, char
+	if fold {
+		for folded := unicode.SimpleFold(char); folded != char; folded = unicode.SimpleFold(folded) {
+			minChar, maxChar = min(minChar, folded), max(maxChar, folded)
+		}
+	}
+	return utf8.AppendRune(lo, minChar), utf8.AppendRune(hi, maxChar)
+}
+
+// bounds returns the smallest and largest rune the class matches. It gives
+// up on negated and empty classes, and on classes reaching the surrogate
+// range, where RuneError also stands in for invalid input bytes.
+func (class *compiledClass) bounds(fold bool) (rune, rune, bool) {
+	ranges := class.rangeList()
+	if class.negated || len(ranges) == 0 {
+		return 0, 0, false
+	}
+	minChar, maxChar := ranges[0].start, ranges[0].end
+	for _, r := range ranges[1:] {
+		minChar, maxChar = min(minChar, r.start), max(maxChar, r.end)
+	}
+	if maxChar >= 0xd800 {
+		return 0, 0, false
+	}
+	if !fold {
+		return minChar, maxChar, true
+	}
+	if maxChar > unicode.MaxASCII {
+		return 0, 0, false
+	}
+	minChar, maxChar = unicode.MaxRune, 0
+	for char := rune(0); char <= unicode.MaxASCII; char++ {
+		if class.contains(char) {
+			for folded := unicode.SimpleFold(char); ; folded = unicode.SimpleFold(folded) {
+				minChar, maxChar = min(minChar, folded), max(maxChar, folded)
+				if folded == char {
+					break
+				}
+			}
+		}
+	}
+	return minChar, maxChar, true
+}
+
+// redisLiteralBounds walks a ModeRedisBytes pattern up to its first '*' or
+// '[' and returns the bounds of the prefix and the bytes consumed.
+func redisLiteralBounds(pattern string, nocase bool) (lo, hi string, end int) {
+	var loBuf, hiBuf []byte
+	for end < len(pattern) {
+		c := pattern[end]
+		switch c {
+		case '*', '[':
+			return string(loBuf), string(hiBuf), end
+		case '?':
+			loBuf, hiBuf = append(loBuf, 0), append(hiBuf, 0xff)
+			end++
+			continue
+		case '\\':
+			if end+1 < len(pattern) {
+				end++
+				c = pattern[end]
+			}
+		}
+		end++
+		lower := c
+		if nocase {
+			lower = byte(cToLower(int(c)))
+		}
+		minByte, maxByte := min(c, lower), max(c, lower)
+		if nocase && c >= 'a' && c <= 'z' {
+			minByte = c - ('a' - 'A')
+		}
+		loBuf, hiBuf = append(loBuf, minByte), append(hiBuf, maxByte)
+	}
+	return string(loBuf), string(hiBuf), end
+}
+
+// successor returns the smallest string greater than every string with
+// prefix s, or "" if there is none.
+func successor(s string) string {
+	b := []byte(s)
+	for len(b) > 0 && b[len(b)-1] == 0xff {
+		b = b[:len(b)-1]
+	}
+	if len(b) == 0 {
+		return ""
+	}
+	b[len(b)-1]++
+	return string(b)
+}
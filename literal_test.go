@@ -0,0 +1,141 @@
+package redglob
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLiteralPrefix(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		prefix   string
+		complete bool
+	}{
+		{"", "", true},
+		{"user:42", "user:42", true},
+		{"user:42:*", "user:42:", false},
+		{"user:*:x", "user:", false},
+		{"a*b*c", "a", false},
+		{`user\*:?`, "user*:", false},
+		{"前後[a]", "前後", false},
+		{"*x", "", false},
+		{"ab" + string([]byte{0xff}) + "c", "ab", false},
+		{"[", "", false},
+	}
+	for _, tt := range cases {
+		prefix, complete := Compile(tt.pattern).LiteralPrefix()
+		if prefix != tt.prefix || complete != tt.complete {
+			t.Errorf("Compile(%q).LiteralPrefix() = %q, %v; want %q, %v", tt.pattern, prefix, complete, tt.prefix, tt.complete)
+		}
+	}
+
+	redis := CompileOptions{Mode: ModeRedisBytes}
+	for _, tt := range []struct {
+		pattern  string
+		prefix   string
+		complete bool
+	}{
+		{"user:*", "user:", false},
+		{`a\?b?`, "a?b", false},
+		{"ab[", "ab", false},
+		{`ab\`, `ab\`, true},
+	} {
+		p, _ := redis.Compile(tt.pattern)
+		prefix, complete := p.LiteralPrefix()
+		if prefix != tt.prefix || complete != tt.complete {
+			t.Errorf("Compile(%q, ModeRedisBytes).LiteralPrefix() = %q, %v; want %q, %v", tt.pattern, prefix, complete, tt.prefix, tt.complete)
+		}
+	}
+}
+
+func TestKeyRange(t *testing.T) {
+	cases := []struct {
+		pattern string
+		fold    bool
+		lo, hi  string
+		ok      bool
+	}{
+		{"*", false, "", "", false},
+		{"[", false, "", "", false},
+		{"user:42:*", false, "user:42:", "user:42;", true},
+		{"[a-c]x*", false, "ax", "cy", true},
+		{"abc", false, "abc", "abc\x00", true},
+		{"a?", false, "a\x00", "a\xff\x00", true},
+		{"a?*", false, "a\x00", "b", true},
+		{"a[^b]*", false, "a", "b", true},
+		{"\xff*", false, "", "", false},
+		{"a\xff*", false, "a", "b", true},
+		{"ab*", true, "AB", "ac", true},
+		{"k*", true, "K", "Å", true},
+		{"[a-c]x*", true, "AX", "cy", true},
+		{"x[k]*", true, "XK", "xÅ", true},
+	}
+	for _, tt := range cases {
+		p := Compile(tt.pattern)
+		var lo, hi string
+		var ok bool
+		if tt.fold {
+			lo, hi, ok = p.KeyRangeFold()
+		} else {
+			lo, hi, ok = p.KeyRange()
+		}
+		if lo != tt.lo || hi != tt.hi || ok != tt.ok {
+			t.Errorf("Compile(%q).KeyRange(fold=%v) = %q, %q, %v; want %q, %q, %v",
+				tt.pattern, tt.fold, lo, hi, ok, tt.lo, tt.hi, tt.ok)
+		}
+	}
+}
+
+func TestKeyRangeRedis(t *testing.T) {
+	redis := CompileOptions{Mode: ModeRedisBytes}
+	cases := []struct {
+		pattern string
+		fold    bool
+		lo, hi  string
+	}{
+		{"user:?*", false, "user:\x00", "user;"},
+		{"ab[c]", false, "ab", "ac"},
+		{"ab", true, "AB", "ab\x00"},
+		{"é*", true, "é", "ê"},
+	}
+	for _, tt := range cases {
+		p, _ := redis.Compile(tt.pattern)
+		lo, hi, ok := p.KeyRange()
+		if tt.fold {
+			lo, hi, ok = p.KeyRangeFold()
+		}
+		if lo != tt.lo || hi != tt.hi || !ok {
+			t.Errorf("Compile(%q, ModeRedisBytes).KeyRange(fold=%v) = %q, %q, %v; want %q, %q, true",
+				tt.pattern, tt.fold, lo, hi, ok, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestKeyRangeContainsMatches(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pieces := []string{"a", "B", "k", "é", "*", "?", "[a-c]", "[^a]", "[kx]", "[é-ê]", `\*`}
+	runes := []string{"a", "A", "b", "B", "c", "k", "K", "K", "x", "é", "É", "ê", "*", "\xff", "\x00"}
+	for i := 0; i < 2000; i++ {
+		var pattern strings.Builder
+		for n := rng.Intn(5); n >= 0; n-- {
+			pattern.WriteString(pieces[rng.Intn(len(pieces))])
+		}
+		p := Compile(pattern.String())
+		lo, hi, _ := p.KeyRange()
+		foldLo, foldHi, _ := p.KeyRangeFold()
+		for j := 0; j < 50; j++ {
+			var str strings.Builder
+			for n := rng.Intn(5); n >= 0; n-- {
+				str.WriteString(runes[rng.Intn(len(runes))])
+			}
+			s := str.String()
+			if p.Match(s) && (s < lo || hi != "" && s >= hi) {
+				t.Fatalf("Compile(%q).Match(%q) but KeyRange = [%q, %q)", pattern.String(), s, lo, hi)
+			}
+			if p.MatchFold(s) && (s < foldLo || foldHi != "" && s >= foldHi) {
+				t.Fatalf("Compile(%q).MatchFold(%q) but KeyRangeFold = [%q, %q)", pattern.String(), s, foldLo, foldHi)
+			}
+		}
+	}
+}
@@ -24,3 +24,43 @@ func ExampleCompile() {
 	// true
 	// false
 }
+
+func ExampleCompileErr() {
+	_, err := redglob.CompileErr("user:[0-9*")
+	fmt.Println(err)
+	// Output:
+	// redglob: unterminated character class at column 6
+	// 	user:[0-9*
+	// 	     ^
+}
+
+func ExampleRewriter() {
+	r, err := redglob.NewRewriter("session:*:data", "sess:v2:*")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(r.Rewrite("session:42:data"))
+	fmt.Println(r.Rewrite("cache:42"))
+	// Output:
+	// sess:v2:42 true
+	//  false
+}
+
+func ExampleOverlaps() {
+	deny := redglob.Compile("cache:*")
+	fmt.Println(redglob.Subsumes(deny, redglob.Compile("cache:user:*")))
+	fmt.Println(redglob.Overlaps(deny, redglob.Compile("*:session")))
+	// Output:
+	// true
+	// true cache:session
+}
+
+func ExampleFilterSorted() {
+	keys := []string{"admin:1", "user:1", "user:2", "user:20", "zone:1"}
+	for key := range redglob.FilterSorted(keys, redglob.Compile("user:2*")) {
+		fmt.Println(key)
+	}
+	// Output:
+	// user:2
+	// user:20
+}
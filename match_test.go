@@ -2,6 +2,7 @@ package redglob
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"strings"
 	"sync"
 	"testing"
@@ -692,6 +693,14 @@ func FuzzCompile(f *testing.F) {
 	f.Add("a", "[a-")
 	f.Fuzz(func(t *testing.T, str, pattern string) {
 		compiled := Compile(pattern)
+		r := rand.New(rand.NewPCG(uint64(len(str)), uint64(len(pattern))))
+		if generated, ok := compiled.Generate(r, GenOptions{Unicode: true}); !ok {
+			if nonEmpty, _ := Overlaps(compiled, Compile("*")); nonEmpty {
+				t.Errorf("Compile(%q).Generate() reported false", pattern)
+			}
+		} else if !compiled.Match(generated) || !stringmatch(generated, pattern, false) {
+			t.Errorf("Compile(%q).Generate() = %q, which does not match", pattern, generated)
+		}
 		want := stringmatch(str, pattern, false)
 		wantFold := stringmatch(str, pattern, true)
 		if got := compiled.Match(str); got != want {
@@ -706,6 +715,12 @@ func FuzzCompile(f *testing.F) {
 		if got := compiled.MatchBytesFold([]byte(str)); got != wantFold {
 			t.Errorf("Compile(%q).MatchBytesFold(%q) = %v, want %v", pattern, str, got, wantFold)
 		}
+		if _, got := compiled.SubmatchIndex(str); got != want {
+			t.Errorf("Compile(%q).SubmatchIndex(%q) ok = %v, want %v", pattern, str, got, want)
+		}
+		if _, got := compiled.SubmatchIndexFold(str); got != wantFold {
+			t.Errorf("Compile(%q).SubmatchIndexFold(%q) ok = %v, want %v", pattern, str, got, wantFold)
+		}
 		if got := Match(str, pattern); got != want {
 			t.Errorf("Match(%q, %q) = %v, want %v", str, pattern, got, want)
 		}
@@ -0,0 +1,668 @@
+package redglob
+
+import (
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Feasibility classifies a prefix by whether extending it can yield a match.
+type Feasibility uint8
+
+const (
+	// NoMatch means no string starting with the prefix matches.
+	NoMatch Feasibility = iota
+	// MaybeMatch means some, but not necessarily all, extensions match.
+	MaybeMatch
+	// MatchesAllExtensions means every string starting with the prefix,
+	// including the prefix itself, matches.
+	MatchesAllExtensions
+)
+
+func (f Feasibility) String() string {
+	switch f {
+	case NoMatch:
+		return "NoMatch"
+	case MaybeMatch:
+		return "MaybeMatch"
+	case MatchesAllExtensions:
+		return "MatchesAllExtensions"
+	default:
+		return "Feasibility(" + strconv.Itoa(int(f)) + ")"
+	}
+}
+
+// MatchPrefix reports whether strings starting with prefix can match the
+// pattern, for pruning a walk over a trie or radix tree of keys. For example,
+// "ab*" against prefix "abc" reports MatchesAllExtensions, and against "ax"
+// reports NoMatch.
+//
+// The answer is exact for NoMatch. MatchesAllExtensions is reported once the
+// prefix reaches a trailing '*', or a trailing "**" with a Separator; a few
+// patterns such as "?*?" accept every extension without one and report
+// MaybeMatch instead, as do brace patterns. A prefix ending in an
+// incomplete UTF-8 sequence is judged on the complete runes before it,
+// except in ModeRedisBytes, where it is judged byte by byte.
+func (p *Pattern) MatchPrefix(prefix string) Feasibility {
+	m := p.newMatcher(false)
+	m.feed(prefix)
+	return m.Feasibility()
+}
+
+// MatchPrefixFold is like MatchPrefix for MatchFold.
+func (p *Pattern) MatchPrefixFold(prefix string) Feasibility {
+	m := p.newMatcher(true)
+	m.feed(prefix)
+	return m.Feasibility()
+}
+
+// MatchReader reports whether the input read from r matches the pattern,
+// without holding the whole input: it reads r once through a Matcher, so
+// memory is bounded by the pattern. It stops reading as soon as the answer
+// is known, at the first rune no match can follow or at a trailing '*'.
+//
+// If r is also an io.ByteReader, as *strings.Reader and *bufio.Reader are,
+// it is read a byte at a time and invalid UTF-8 is matched as Match does.
+// Otherwise invalid UTF-8 reaches the pattern as U+FFFD, since r does not
+// report the bytes. An error from r other than io.EOF is returned with
+// false.
+func (p *Pattern) MatchReader(r io.RuneReader) (bool, error) {
+	return p.matchReader(r, false)
+}
+
+// MatchReaderFold is like MatchReader for MatchFold.
+func (p *Pattern) MatchReaderFold(r io.RuneReader) (bool, error) {
+	return p.matchReader(r, true)
+}
+
+func (p *Pattern) matchReader(r io.RuneReader, fold bool) (bool, error) {
+	m := p.newMatcher(fold)
+	br, bytewise := r.(io.ByteReader)
+	for m.Feasibility() == MaybeMatch {
+		var err error
+		if bytewise {
+			var c byte
+			if c, err = br.ReadByte(); err == nil {
+				_ = m.WriteByte(c)
+			}
+		} else {
+			var char rune
+			if char, _, err = r.ReadRune(); err == nil {
+				_, _ = m.WriteRune(char)
+			}
+		}
+		if err == io.EOF {
+			return m.Matched(), nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return m.all, nil
+}
+
+// Matcher matches input that arrives a piece at a time, such as the labels
+// on a path through a radix tree. It keeps the single star checkpoint of
+// the matcher behind Match, plus the input consumed since that checkpoint,
+// so memory is bounded by the pattern rather than the input. Use Clone to
+// branch at a tree node instead of rescanning from the root.
+//
+// Brace patterns run their program a rune at a time, and ModeRedisBytes
+// patterns a byte at a time. A Separator pattern feeds each segment of the
+// input to a Matcher for every segment of the pattern it may meet, so
+// nothing is kept from earlier segments. A Matcher is not safe for
+// concurrent use.
+type Matcher struct {
+	p      *Pattern
+	tokens []token
+	fold   bool
+
+	tokenIndex int
+	within     int // bytes of a literal run or runes of '?' already matched
+	starToken  int
+	all        bool // a trailing star was reached
+	dead       bool
+
+	buf     []byte // input consumed since the star checkpoint
+	queue   []byte // input being replayed after a backtrack
+	spare   []byte
+	pending []byte // incomplete UTF-8 sequence at the end of the input
+
+	// Patterns that are not a flat token stream keep their own state.
+	threads  *machine       // brace groups: the threads of p.prog
+	redis    *redisState    // ModeRedisBytes
+	segments *segmentsState // Separator
+}
+
+// Matcher returns a Matcher for p positioned at the empty input.
+func (p *Pattern) Matcher() *Matcher {
+	return p.newMatcher(false)
+}
+
+// MatcherFold returns a Matcher that compares like MatchFold.
+func (p *Pattern) MatcherFold() *Matcher {
+	return p.newMatcher(true)
+}
+
+func (p *Pattern) newMatcher(fold bool) *Matcher {
+	m := &Matcher{p: p, fold: fold}
+	m.Reset()
+	return m
+}
+
+// Reset returns m to the empty input.
+func (m *Matcher) Reset() {
+	*m = Matcher{
+		p:         m.p,
+		fold:      m.fold,
+		starToken: -1,
+		buf:       m.buf[:0],
+		queue:     m.queue[:0],
+		spare:     m.spare[:0],
+		pending:   m.pending[:0],
+		threads:   m.threads,
+		redis:     m.redis,
+		segments:  m.segments,
+	}
+	p := m.p
+	switch {
+	case p == nil || !p.valid:
+		m.dead = true
+		return
+	case p.mode == ModeRedisBytes:
+		if m.redis == nil {
+			m.redis = newRedisState(p)
+		}
+		m.redis.reset()
+		m.dead = p.redisDeep
+		m.settleRedis()
+		return
+	case p.segments != nil:
+		if m.segments == nil {
+			m.segments = newSegmentsState(p, m.fold)
+		}
+		m.segments.reset()
+		m.all = m.segments.all()
+		return
+	case p.prog != nil:
+		if m.threads == nil {
+			m.threads = new(machine)
+		}
+		m.threads.current.reset(len(p.prog))
+		m.threads.next.reset(len(p.prog))
+		m.threads.add(p.prog, &m.threads.current, 0, 0)
+		return
+	}
+	m.tokens = p.tokenStream()
+	for i := range m.tokens {
+		if class := m.tokens[i].class; class != nil && class.empty() {
+			m.dead = true
+			return
+		}
+	}
+	m.settle()
+}
+
+// Clone returns an independent copy of m.
+func (m *Matcher) Clone() *Matcher {
+	clone := *m
+	clone.buf = append([]byte(nil), m.buf...)
+	clone.queue, clone.spare = nil, nil
+	clone.pending = append([]byte(nil), m.pending...)
+	if m.threads != nil {
+		clone.threads = &machine{current: m.threads.current.clone(), next: m.threads.next.clone()}
+	}
+	if m.redis != nil {
+		clone.redis = m.redis.clone()
+	}
+	if m.segments != nil {
+		clone.segments = m.segments.clone()
+	}
+	return &clone
+}
+
+// Write appends b to the input. It never returns an error.
+func (m *Matcher) Write(b []byte) (int, error) {
+	m.feed(b2s(b))
+	return len(b), nil
+}
+
+// WriteString appends s to the input. It never returns an error.
+func (m *Matcher) WriteString(s string) (int, error) {
+	m.feed(s)
+	return len(s), nil
+}
+
+// WriteByte appends c to the input. It never returns an error.
+func (m *Matcher) WriteByte(c byte) error {
+	b := [1]byte{c}
+	m.feed(string(b[:]))
+	return nil
+}
+
+// WriteRune appends the UTF-8 encoding of r to the input. It never returns
+// an error.
+func (m *Matcher) WriteRune(r rune) (int, error) {
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+	m.feed(string(b[:n]))
+	return n, nil
+}
+
+// Feasibility reports whether the input so far can be extended to a match.
+func (m *Matcher) Feasibility() Feasibility {
+	switch {
+	case m.dead:
+		return NoMatch
+	case m.all:
+		return MatchesAllExtensions
+	default:
+		return MaybeMatch
+	}
+}
+
+// Matched reports whether the input so far matches the pattern, treating a
+// trailing incomplete UTF-8 sequence as invalid bytes, as Match does.
+func (m *Matcher) Matched() bool {
+	if len(m.pending) > 0 {
+		end := m.Clone()
+		pending := end.pending
+		end.pending = nil
+		for i := range pending {
+			end.process(b2s(pending[i : i+1]))
+		}
+		return end.matched()
+	}
+	return m.matched()
+}
+
+func (m *Matcher) matched() bool {
+	switch {
+	case m.dead:
+		return false
+	case m.redis != nil:
+		return m.redis.matched()
+	case m.segments != nil:
+		return m.segments.matched()
+	case m.threads != nil:
+		return m.p.programMatched(m.threads)
+	}
+	return m.all || m.tokenIndex == len(m.tokens)
+}
+
+// feed decodes complete runes from the pending bytes followed by s and
+// keeps any incomplete trailing sequence for the next call. In
+// ModeRedisBytes, s is matched a byte at a time instead.
+func (m *Matcher) feed(s string) {
+	if m.dead || m.all {
+		return
+	}
+	if m.redis != nil {
+		m.processRedis(s)
+		return
+	}
+	if len(m.pending) > 0 {
+		m.pending = append(m.pending, s...)
+		s = string(m.pending)
+		m.pending = m.pending[:0]
+	}
+	end := len(s)
+	for start := end - 1; start >= 0 && start > end-utf8.UTFMax; start-- {
+		if utf8.RuneStart(s[start]) {
+			if !utf8.FullRuneInString(s[start:]) {
+				m.pending = append(m.pending, s[start:]...)
+				end = start
+			}
+			break
+		}
+	}
+	m.process(s[:end])
+}
+
+// process runs the star-checkpoint walk of stringmatchIter over complete
+// runes. On a mismatch the star absorbs one more rune and the input since
+// the checkpoint is replayed against the tokens after the star.
+func (m *Matcher) process(input string) {
+	switch {
+	case m.segments != nil:
+		m.processSegments(input)
+		return
+	case m.threads != nil:
+		m.processProgram(input)
+		return
+	}
+	for len(input) > 0 {
+		if m.dead || m.all {
+			return
+		}
+		char, size := decodeRune(input)
+		if m.step(char, input[:size]) {
+			input = input[size:]
+			continue
+		}
+		if m.starToken < 0 {
+			m.dead = true
+			return
+		}
+		m.spare = append(append(m.spare[:0], m.buf...), input...)
+		m.queue, m.spare = m.spare, m.queue
+		_, drop := decodeRune(b2s(m.queue))
+		input = b2s(m.queue[drop:])
+		m.tokenIndex, m.within = m.starToken+1, 0
+		m.buf = m.buf[:0]
+	}
+}
+
+func (m *Matcher) step(char rune, raw string) bool {
+	if m.tokenIndex == len(m.tokens) {
+		return false
+	}
+	tok := &m.tokens[m.tokenIndex]
+	switch tok.kind {
+	case tokenLiteralRun:
+		lit := tok.lit[m.within:]
+		if m.fold {
+			litChar, litSize := decodeRune(lit)
+			if !runesEqualFold(litChar, char) {
+				return false
+			}
+			m.within += litSize
+		} else {
+			// Byte comparison, as consumeLiteralRun does.
+			if !strings.HasPrefix(lit, raw) {
+				return false
+			}
+			m.within += len(raw)
+		}
+		if m.within < len(tok.lit) {
+			m.consumed(raw)
+			return true
+		}
+	case tokenAnyN:
+		m.within++
+		if m.within < tok.count {
+			m.consumed(raw)
+			return true
+		}
+	default:
+		if !m.p.tokenMatches(tok, char, m.fold) {
+			return false
+		}
+	}
+	m.tokenIndex++
+	m.within = 0
+	m.consumed(raw)
+	m.settle()
+	return true
+}
+
+func (m *Matcher) consumed(raw string) {
+	if m.starToken >= 0 {
+		m.buf = append(m.buf, raw...)
+	}
+}
+
+// settle moves past stars at the current position, making the last one the
+// new checkpoint.
+func (m *Matcher) settle() {
+	for m.tokenIndex < len(m.tokens) && m.tokens[m.tokenIndex].kind == tokenStar {
+		if m.tokenIndex == len(m.tokens)-1 {
+			m.all = true
+		}
+		m.starToken = m.tokenIndex
+		m.tokenIndex++
+		m.buf = m.buf[:0]
+	}
+}
+
+// processProgram steps the threads of a brace pattern over complete runes.
+func (m *Matcher) processProgram(input string) {
+	for len(input) > 0 && !m.dead {
+		char, size := decodeRune(input)
+		m.p.stepProgram(m.threads, char, m.fold)
+		m.dead = len(m.threads.current.dense) == 0
+		input = input[size:]
+	}
+}
+
+func (s *sparseSet) clone() sparseSet {
+	return sparseSet{
+		dense:  append([]int(nil), s.dense...),
+		sparse: append([]int(nil), s.sparse...),
+		starts: append([]int(nil), s.starts...),
+	}
+}
+
+// redisState runs a ModeRedisBytes pattern as a set of positions in its
+// tokens, expanded to one step per byte: a one-byte literal, '?', a class
+// or '*'. Redis matches the empty string only against the empty pattern,
+// so consumed records whether a byte has been seen.
+type redisState struct {
+	steps    []redisToken
+	current  []bool // positions 0 to len(steps), the last one the end
+	next     []bool
+	consumed bool
+}
+
+func newRedisState(p *Pattern) *redisState {
+	var steps []redisToken
+	for _, t := range p.redis {
+		switch t.kind {
+		case tokenLiteralRun:
+			for i := range len(t.lit) {
+				steps = append(steps, redisToken{kind: tokenLiteralRun, lit: t.lit[i : i+1]})
+			}
+		case tokenAnyN:
+			for range t.count {
+				steps = append(steps, redisToken{kind: tokenAnyN, count: 1})
+			}
+		default:
+			steps = append(steps, t)
+		}
+	}
+	return &redisState{
+		steps:   steps,
+		current: make([]bool, len(steps)+1),
+		next:    make([]bool, len(steps)+1),
+	}
+}
+
+func (s *redisState) reset() {
+	clear(s.current)
+	s.current[0] = true
+	s.consumed = false
+}
+
+func (s *redisState) clone() *redisState {
+	return &redisState{
+		steps:    s.steps,
+		current:  append([]bool(nil), s.current...),
+		next:     make([]bool, len(s.next)),
+		consumed: s.consumed,
+	}
+}
+
+func (s *redisState) matched() bool {
+	return s.current[len(s.steps)] && (s.consumed || len(s.steps) == 0)
+}
+
+// closure lets every '*' in the set match nothing.
+func (s *redisState) closure(set []bool) {
+	for i, t := range s.steps {
+		if set[i] && t.kind == tokenStar {
+			set[i+1] = true
+		}
+	}
+}
+
+// processRedis steps the positions of a ModeRedisBytes pattern over the
+// bytes of input.
+func (m *Matcher) processRedis(input string) {
+	s := m.redis
+	for i := 0; i < len(input) && !m.dead && !m.all; i++ {
+		c := input[i]
+		clear(s.next)
+		live := false
+		for pos, t := range s.steps {
+			if !s.current[pos] {
+				continue
+			}
+			var ok bool
+			switch t.kind {
+			case tokenStar:
+				s.next[pos], live = true, true
+				continue
+			case tokenLiteralRun:
+				ok = t.lit[0] == c || m.fold && lowerASCIIByte(t.lit[0]) == lowerASCIIByte(c)
+			default:
+				ok = t.matchesByte(c, m.fold)
+			}
+			if ok {
+				s.next[pos+1], live = true, true
+			}
+		}
+		s.current, s.next = s.next, s.current
+		s.consumed = true
+		m.dead = !live
+		m.settleRedis()
+	}
+}
+
+// settleRedis applies the closure and notes a trailing '*' that has been
+// reached after some input, which every extension matches.
+func (m *Matcher) settleRedis() {
+	s := m.redis
+	s.closure(s.current)
+	n := len(s.steps)
+	m.all = !m.dead && s.consumed && n > 0 && s.steps[n-1].kind == tokenStar && s.current[n-1]
+}
+
+// segmentsState runs a Separator pattern a segment at a time. live marks
+// the segments of the pattern that the current segment of the input may
+// be matched against; parts holds a Matcher for each of them that is not
+// "**". The pattern matches at a position i when every segment from tail on
+// is "**".
+type segmentsState struct {
+	p     *Pattern
+	live  []bool
+	next  []bool
+	parts []*Matcher
+	tail  int
+}
+
+func newSegmentsState(p *Pattern, fold bool) *segmentsState {
+	n := len(p.segments)
+	s := &segmentsState{p: p, live: make([]bool, n), next: make([]bool, n), parts: make([]*Matcher, n), tail: n}
+	for s.tail > 0 && p.segments[s.tail-1] == nil {
+		s.tail--
+	}
+	for i, segment := range p.segments {
+		if segment != nil {
+			s.parts[i] = segment.newMatcher(fold)
+		}
+	}
+	return s
+}
+
+func (s *segmentsState) reset() {
+	clear(s.live)
+	s.enter(s.live, 0)
+	s.resetParts()
+}
+
+// enter adds position i to set, and the positions after each "**" from
+// there, which may match no segment.
+func (s *segmentsState) enter(set []bool, i int) {
+	for ; i < len(set); i++ {
+		set[i] = true
+		if s.p.segments[i] != nil {
+			return
+		}
+	}
+}
+
+func (s *segmentsState) resetParts() {
+	for i, part := range s.parts {
+		if part != nil && s.live[i] {
+			part.Reset()
+		}
+	}
+}
+
+func (s *segmentsState) clone() *segmentsState {
+	clone := &segmentsState{
+		p:     s.p,
+		live:  append([]bool(nil), s.live...),
+		next:  make([]bool, len(s.next)),
+		parts: make([]*Matcher, len(s.parts)),
+		tail:  s.tail,
+	}
+	for i, part := range s.parts {
+		if part != nil {
+			clone.parts[i] = part.Clone()
+		}
+	}
+	return clone
+}
+
+// matched reports whether the input matches with the current segment as its
+// last.
+func (s *segmentsState) matched() bool {
+	for i, live := range s.live {
+		if !live {
+			continue
+		}
+		if s.parts[i] == nil && i >= s.tail || s.parts[i] != nil && i+1 >= s.tail && s.parts[i].Matched() {
+			return true
+		}
+	}
+	return false
+}
+
+// all reports whether a trailing "**" has been reached, which absorbs
+// whatever follows.
+func (s *segmentsState) all() bool {
+	return s.tail < len(s.live) && s.live[s.tail]
+}
+
+// processSegments feeds complete runes to the live segment matchers and
+// moves on to the next segments at each separator.
+func (m *Matcher) processSegments(input string) {
+	s := m.segments
+	sep := m.p.separator
+	for len(input) > 0 && !m.dead && !m.all {
+		char, size := decodeRune(input)
+		live := false
+		if char == sep && size == utf8.RuneLen(sep) {
+			clear(s.next)
+			for i, in := range s.live {
+				switch {
+				case !in:
+				case s.parts[i] == nil:
+					s.enter(s.next, i)
+				case s.parts[i].Matched() && i+1 < len(s.next):
+					s.enter(s.next, i+1)
+				}
+			}
+			s.live, s.next = s.next, s.live
+			s.resetParts()
+			live = slices.Contains(s.live, true)
+		} else {
+			for i, in := range s.live {
+				if !in {
+					continue
+				}
+				if part := s.parts[i]; part != nil {
+					part.feed(input[:size])
+					s.live[i] = part.Feasibility() != NoMatch
+				}
+				live = live || s.live[i]
+			}
+		}
+		m.dead = !live
+		m.all = s.all()
+		input = input[size:]
+	}
+}
@@ -0,0 +1,313 @@
+package redglob
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+func TestMatchPrefix(t *testing.T) {
+	cases := []struct {
+		pattern, prefix string
+		want, fold      Feasibility
+	}{
+		{"ab*", "abc", MatchesAllExtensions, MatchesAllExtensions},
+		{"ab*", "a", MaybeMatch, MaybeMatch},
+		{"ab*", "ax", NoMatch, NoMatch},
+		{"ab*", "AB", NoMatch, MatchesAllExtensions},
+		{"ab", "ab", MaybeMatch, MaybeMatch},
+		{"ab", "abc", NoMatch, NoMatch},
+		{"*", "", MatchesAllExtensions, MatchesAllExtensions},
+		{"a*b*", "axxb", MatchesAllExtensions, MatchesAllExtensions},
+		{"a*b*", "axx", MaybeMatch, MaybeMatch},
+		{"user:[0-9]*:x", "user:4", MaybeMatch, MaybeMatch},
+		{"user:[0-9]*:x", "user:a", NoMatch, NoMatch},
+		{"*abc*d", "xxabababc", MaybeMatch, MaybeMatch},
+		{"??", "前後x", NoMatch, NoMatch},
+		{"[]*", "", NoMatch, NoMatch},
+		{"[", "", NoMatch, NoMatch},
+		{"前*", "前\xe5", MatchesAllExtensions, MatchesAllExtensions},
+		{"a前", "a\xe5", MaybeMatch, MaybeMatch},
+	}
+	for _, tt := range cases {
+		p := Compile(tt.pattern)
+		if got := p.MatchPrefix(tt.prefix); got != tt.want {
+			t.Errorf("Compile(%q).MatchPrefix(%q) = %v, want %v", tt.pattern, tt.prefix, got, tt.want)
+		}
+		if got := p.MatchPrefixFold(tt.prefix); got != tt.fold {
+			t.Errorf("Compile(%q).MatchPrefixFold(%q) = %v, want %v", tt.pattern, tt.prefix, got, tt.fold)
+		}
+	}
+}
+
+func TestMatcherAgreesWithMatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	write := func(m *Matcher, s string) {
+		for len(s) > 0 {
+			n := 1 + rng.Intn(len(s))
+			switch rng.Intn(3) {
+			case 0:
+				_, _ = m.WriteString(s[:n])
+			case 1:
+				_, _ = m.Write([]byte(s[:n]))
+			default:
+				for i := 0; i < n; i++ {
+					_ = m.WriteByte(s[i])
+				}
+			}
+			s = s[n:]
+		}
+	}
+	for _, tt := range allMatchCases() {
+		for _, fold := range []bool{false, true} {
+			p := Compile(tt.args.pattern)
+			m := p.Matcher()
+			want := p.Match(tt.args.str)
+			if fold {
+				m = p.MatcherFold()
+				want = p.MatchFold(tt.args.str)
+			}
+			write(m, tt.args.str)
+			if got := m.Matched(); got != want {
+				t.Errorf("Compile(%q).Matcher(fold=%v) after %q Matched() = %v, want %v",
+					tt.args.pattern, fold, tt.args.str, got, want)
+			}
+		}
+	}
+}
+
+func TestMatchPrefixExhaustive(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	pieces := []string{"a", "b", "ab", "*", "?", "[ab]", "[^a]", "é"}
+	alphabet := []string{"a", "b", "c", "é", "\xc3"}
+	var extensions []string
+	var grow func(prefix string, depth int)
+	grow = func(prefix string, depth int) {
+		extensions = append(extensions, prefix)
+		if depth == 0 {
+			return
+		}
+		for _, r := range alphabet {
+			grow(prefix+r, depth-1)
+		}
+	}
+	grow("", 4)
+	for i := 0; i < 300; i++ {
+		var pattern strings.Builder
+		count := 1 + rng.Intn(5)
+		for n := 0; n < count; n++ {
+			pattern.WriteString(pieces[rng.Intn(len(pieces))])
+		}
+		p := Compile(pattern.String())
+		for j := 0; j < 20; j++ {
+			prefix := extensions[rng.Intn(len(extensions))]
+			if len(prefix) > 0 && prefix[len(prefix)-1] == 0xc3 {
+				continue
+			}
+			got := p.MatchPrefix(prefix)
+			some, all := false, true
+			for _, ext := range extensions {
+				if p.Match(prefix + ext) {
+					some = true
+				} else {
+					all = false
+				}
+			}
+			if got == NoMatch && some {
+				t.Fatalf("Compile(%q).MatchPrefix(%q) = NoMatch, but an extension matches", pattern.String(), prefix)
+			}
+			if got == MatchesAllExtensions && !all {
+				t.Fatalf("Compile(%q).MatchPrefix(%q) = MatchesAllExtensions, but an extension fails", pattern.String(), prefix)
+			}
+			// Two pieces need at most four more runes, so the extensions
+			// are enough to witness a match.
+			if got == MaybeMatch && !some && count <= 2 {
+				t.Fatalf("Compile(%q).MatchPrefix(%q) = MaybeMatch, but no extension matches", pattern.String(), prefix)
+			}
+		}
+	}
+}
+
+func TestMatcherClone(t *testing.T) {
+	m := Compile("user:*:profile").Matcher()
+	_, _ = m.WriteString("user:4")
+	branch := m.Clone()
+	_, _ = branch.WriteString("2:profile")
+	_, _ = m.WriteString("2:prof")
+	if !branch.Matched() || m.Matched() {
+		t.Errorf("Matched() = %v, %v; want true, false", branch.Matched(), m.Matched())
+	}
+	m.Reset()
+	_, _ = m.WriteRune('u')
+	if m.Feasibility() != MaybeMatch {
+		t.Errorf("Feasibility() after Reset = %v, want MaybeMatch", m.Feasibility())
+	}
+}
+
+func TestMatchReaderAgreesWithMatch(t *testing.T) {
+	for _, tt := range allMatchCases() {
+		p := Compile(tt.args.pattern)
+		got, err := p.MatchReader(strings.NewReader(tt.args.str))
+		if want := p.Match(tt.args.str); got != want || err != nil {
+			t.Errorf("Compile(%q).MatchReader(%q) = %v, %v, want %v", tt.args.pattern, tt.args.str, got, err, want)
+		}
+		got, err = p.MatchReaderFold(strings.NewReader(tt.args.str))
+		if want := p.MatchFold(tt.args.str); got != want || err != nil {
+			t.Errorf("Compile(%q).MatchReaderFold(%q) = %v, %v, want %v", tt.args.pattern, tt.args.str, got, err, want)
+		}
+	}
+}
+
+func TestMatchReaderOptions(t *testing.T) {
+	tests := []struct {
+		opts    CompileOptions
+		pattern string
+		str     string
+		want    bool
+	}{
+		{CompileOptions{Braces: true}, "{a,b*}c", "bxxc", true},
+		{CompileOptions{Braces: true}, "{a,b*}c", "axxc", false},
+		{CompileOptions{Separator: '/'}, "src/**/*.go", "src/a/b.go", true},
+		{CompileOptions{Separator: '/'}, "src/*.go", "src/a/b.go", false},
+		{CompileOptions{Mode: ModeRedisBytes}, "a?c", "abc", true},
+	}
+	for _, tt := range tests {
+		p, err := tt.opts.Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := p.MatchReader(strings.NewReader(tt.str)); got != tt.want || err != nil {
+			t.Errorf("Compile(%q).MatchReader(%q) = %v, %v, want %v", tt.pattern, tt.str, got, err, tt.want)
+		}
+	}
+}
+
+// TestMatchReaderOptionsRandom checks MatchReader and a Matcher fed in
+// random pieces against Match for brace, Separator and ModeRedisBytes
+// patterns, on input that is often not valid UTF-8.
+func TestMatchReaderOptionsRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	random := func(alphabet []string, n int) string {
+		var b strings.Builder
+		for i := rng.Intn(n); i > 0; i-- {
+			b.WriteString(alphabet[rng.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+	options := []struct {
+		opts              CompileOptions
+		pattern, alphabet []string
+	}{
+		{CompileOptions{Mode: ModeRedisBytes}, []string{"a", "b", "*", "?", "[^a]", "[\xc3-\xff]", "\\", "é", "\xc3"}, []string{"a", "b", "é", "\xc3", "\xa9", "\\"}},
+		{CompileOptions{Separator: '/'}, []string{"a", "b", "/", "*", "**", "?", "[^a]", "é", "\xc3"}, []string{"a", "b", "/", "é", "\xc3", "\xa9"}},
+		{CompileOptions{Separator: '/', Braces: true}, []string{"a", "/", "*", "**", "?", "{a,b*}", "{,é}", "\xc3"}, []string{"a", "b", "/", "é", "\xc3"}},
+	}
+	for _, o := range options {
+		for i := 0; i < 2000; i++ {
+			pattern := random(o.pattern, 6)
+			p, err := o.opts.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			str := random(o.alphabet, 8)
+			for _, fold := range []bool{false, true} {
+				want, m := p.Match(str), p.Matcher()
+				got, err := p.MatchReader(strings.NewReader(str))
+				if fold {
+					want, m = p.MatchFold(str), p.MatcherFold()
+					got, err = p.MatchReaderFold(strings.NewReader(str))
+				}
+				if got != want || err != nil {
+					t.Fatalf("%+v: Compile(%q).MatchReader(%q) fold=%v = %v, %v, want %v", o.opts, pattern, str, fold, got, err, want)
+				}
+				for rest := str; len(rest) > 0; {
+					n := 1 + rng.Intn(len(rest))
+					_, _ = m.WriteString(rest[:n])
+					m = m.Clone()
+					rest = rest[n:]
+				}
+				if got := m.Matched(); got != want {
+					t.Fatalf("%+v: Compile(%q).Matcher fold=%v after %q Matched() = %v, want %v", o.opts, pattern, fold, str, got, want)
+				}
+				if f := m.Feasibility(); f == NoMatch && want || f == MatchesAllExtensions && !want {
+					t.Fatalf("%+v: Compile(%q).Matcher fold=%v after %q Feasibility() = %v", o.opts, pattern, fold, str, f)
+				}
+			}
+		}
+	}
+}
+
+func TestMatchReaderError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := bufio.NewReader(io.MultiReader(strings.NewReader("ab"), iotest.ErrReader(errRead)))
+	if got, err := Compile("a*c").MatchReader(r); got || !errors.Is(err, errRead) {
+		t.Errorf("MatchReader() = %v, %v, want false, %v", got, err, errRead)
+	}
+}
+
+// repeatReader yields n copies of a rune followed by tail, without holding
+// them in memory.
+type repeatReader struct {
+	char rune
+	n    int
+	tail *strings.Reader
+	read int
+}
+
+func (r *repeatReader) ReadRune() (rune, int, error) {
+	r.read++
+	if r.n > 0 {
+		r.n--
+		return r.char, utf8.RuneLen(r.char), nil
+	}
+	return r.tail.ReadRune()
+}
+
+func TestMatchReaderBounded(t *testing.T) {
+	for _, pattern := range []string{"*abc", "a*b*c", "[a-z]*b?", "*"} {
+		p := Compile(pattern)
+		allocs := testing.AllocsPerRun(5, func() {
+			if ok, _ := p.MatchReader(&repeatReader{char: 'a', n: 1 << 16, tail: strings.NewReader("abc")}); !ok {
+				t.Errorf("Compile(%q).MatchReader() = false", pattern)
+			}
+		})
+		if allocs > 20 {
+			t.Errorf("Compile(%q).MatchReader allocates %v times", pattern, allocs)
+		}
+	}
+	for _, tt := range []struct {
+		opts    CompileOptions
+		pattern string
+	}{
+		{CompileOptions{Mode: ModeRedisBytes}, "*a?c"},
+		{CompileOptions{Separator: '/'}, "**/a*c"},
+		{CompileOptions{Braces: true}, "{x,a}*{b,c}"},
+	} {
+		p, err := tt.opts.Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocs := testing.AllocsPerRun(5, func() {
+			if ok, _ := p.MatchReader(&repeatReader{char: 'a', n: 1 << 16, tail: strings.NewReader("abc")}); !ok {
+				t.Errorf("Compile(%q).MatchReader() = false", tt.pattern)
+			}
+		})
+		if allocs > 40 {
+			t.Errorf("Compile(%q).MatchReader allocates %v times", tt.pattern, allocs)
+		}
+	}
+	// Reading stops once the answer is known.
+	r := &repeatReader{char: 'a', n: 1 << 16, tail: strings.NewReader("")}
+	if ok, _ := Compile("aa*").MatchReader(r); !ok || r.read != 2 {
+		t.Errorf("MatchReader() = %v after %d runes, want true after 2", ok, r.read)
+	}
+	r = &repeatReader{char: 'a', n: 1 << 16, tail: strings.NewReader("")}
+	if ok, _ := Compile("ab*").MatchReader(r); ok || r.read != 2 {
+		t.Errorf("MatchReader() = %v after %d runes, want false after 2", ok, r.read)
+	}
+}
@@ -0,0 +1,102 @@
+package redglob
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mode selects the matching semantics of a compiled pattern.
+type Mode uint8
+
+const (
+	// ModeUnicode is the default: the pattern and input are decoded as UTF-8,
+	// '?' and classes consume one rune, and invalid patterns never match.
+	ModeUnicode Mode = iota
+	// ModeRedisBytes reproduces Redis's stringmatchlen byte for byte. See
+	// MatchRedis for the differences from ModeUnicode.
+	ModeRedisBytes
+)
+
+// CompileOptions configures CompileOptions.Compile. The zero value compiles
+// exactly like CompileErr.
+type CompileOptions struct {
+	Mode Mode
+
+	// Separator, if nonzero, makes the pattern path-aware, for keys such as
+	// "a/b/c" or "a:b:c". The pattern and the input are split at the
+	// separator and matched segment by segment, so '*', '?' and classes do
+	// not cross it. A segment that is exactly "**" matches zero or more
+	// whole segments: with '/', "a/**/b" matches "a/b" and "a/x/y/b", and
+	// "a/**" matches "a" and everything below it. Elsewhere "**" is the
+	// same as '*'. The separator is compared exactly, even by MatchFold, and
+	// may not be one of the metacharacters *?[]\.
+	Separator rune
+
+	// Braces enables brace groups. A group {a,b,c} matches any one of its
+	// comma-separated alternatives, which may contain any pattern syntax
+	// including further groups, so "user:{1,2*}:{name,email}" matches
+	// "user:1:name" and "user:25:email". A group {lo..hi} of two decimal
+	// numbers matches the numbers in that range, zero-padded to the longer
+	// endpoint if either has a leading zero: "{1..12}" matches "7" and
+	// "12", "{01..12}" matches "07". Groups are compiled, not expanded, so
+	// matching stays linear in the input however many alternatives there
+	// are. A '}' or ',' outside a group is literal, and "\{" escapes a
+	// brace. With a Separator, a group may not contain the separator.
+	// Braces is not supported with ModeRedisBytes.
+	Braces bool
+
+	// NamedClasses enables named sets inside a class: the ASCII POSIX
+	// classes [:alnum:], [:alpha:], [:ascii:], [:blank:], [:cntrl:],
+	// [:digit:], [:graph:], [:lower:], [:print:], [:punct:], [:space:],
+	// [:upper:], [:word:] and [:xdigit:], negated as [:^alpha:], and the
+	// Unicode categories and scripts of package unicode as \pL, \p{Han} or,
+	// negated, \P{L}. They combine with other members, so "[[:digit:]_]"
+	// and "[^\p{Han}\p{Latin}]" are single classes. As in Go's regexp,
+	// MatchFold also accepts the other cases of a member, so "[[:upper:]]"
+	// folds to any letter. Without the option "[[:alpha:]]" is the class
+	// "[[:alph" followed by "]", as in Redis. NamedClasses is not supported
+	// with ModeRedisBytes.
+	NamedClasses bool
+}
+
+var (
+	// errSeparator is returned for an unusable CompileOptions.Separator.
+	errSeparator = errors.New("redglob: separator must be a valid rune other than *?[]\\ and is not supported with ModeRedisBytes")
+	// errExtensions is returned for CompileOptions.Braces or NamedClasses
+	// with ModeRedisBytes.
+	errExtensions = errors.New("redglob: braces and named classes are not supported with ModeRedisBytes")
+)
+
+// Compile parses pattern according to opts. It returns a *SyntaxError if the
+// pattern is invalid under the selected syntax.
+func (opts CompileOptions) Compile(pattern string) (*Pattern, error) {
+	ext := extensions{braces: opts.Braces, namedClasses: opts.NamedClasses}
+	if ext != (extensions{}) && opts.Mode == ModeRedisBytes {
+		return nil, errExtensions
+	}
+	if opts.Separator != 0 {
+		if opts.Mode == ModeRedisBytes || !utf8.ValidRune(opts.Separator) ||
+			strings.ContainsRune("*?[]\\", opts.Separator) {
+			return nil, errSeparator
+		}
+		p, err := compileSegments(pattern, opts.Separator, ext)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	if opts.Mode == ModeRedisBytes {
+		p := &Pattern{valid: true, mode: ModeRedisBytes, source: pattern}
+		p.redis, p.redisDeep = compileRedis(pattern)
+		return p, nil
+	}
+	if ext != (extensions{}) {
+		p, err := ext.compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return CompileErr(pattern)
+}
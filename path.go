@@ -0,0 +1,361 @@
+package redglob
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// compileSegments compiles a pattern for CompileOptions.Separator. Each
+// segment between separators is an ordinary pattern, so '*', '?' and
+// classes cannot cross a separator, and a segment that is exactly "**"
+// becomes a nil entry matching any number of whole segments. Groups are
+// split like everything else, so a group containing the separator is
+// reported as unterminated.
+func compileSegments(pattern string, sep rune, ext extensions) (*Pattern, *SyntaxError) {
+	p := &Pattern{valid: true, source: pattern, separator: sep}
+	texts, offsets := splitSegments(pattern, sep, ext.namedClasses)
+	for i, text := range texts {
+		if text == "**" {
+			// Adjacent "**" segments match the same strings as one.
+			if n := len(p.segments); n == 0 || p.segments[n-1] != nil {
+				p.segments = append(p.segments, nil)
+			}
+			continue
+		}
+		segment, err := ext.compile(text)
+		if err != nil {
+			return nil, newSyntaxError(err.Code, pattern, offsets[i]+err.Offset)
+		}
+		p.segments = append(p.segments, segment)
+	}
+	p.segmentsProg = p.segmentsProgram(func(tokens []token) []token { return tokens })
+	return p, nil
+}
+
+// splitSegments splits pattern at every separator outside a class, escaped
+// or not, and returns the segments with their byte offsets in pattern.
+func splitSegments(pattern string, sep rune, named bool) (texts []string, offsets []int) {
+	sepLen := utf8.RuneLen(sep)
+	start := 0
+	for i := 0; i < len(pattern); {
+		char, size := decodeRune(pattern[i:])
+		switch {
+		case char == '\\' && i+size < len(pattern):
+			next, nextSize := decodeRune(pattern[i+size:])
+			if next == sep {
+				texts, offsets = append(texts, pattern[start:i]), append(offsets, start)
+				start = i + size + sepLen
+			}
+			i += size + nextSize
+		case char == '[':
+			i = skipClass(pattern, i+size, named)
+		case char == sep:
+			texts, offsets = append(texts, pattern[start:i]), append(offsets, start)
+			start = i + sepLen
+			i = start
+		default:
+			i += size
+		}
+	}
+	return append(texts, pattern[start:]), append(offsets, start)
+}
+
+// skipClass returns the offset just past the class whose body starts at i,
+// following the rules of compileClass, or len(pattern) if it is
+// unterminated.
+func skipClass(pattern string, i int, named bool) int {
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	for i < len(pattern) {
+		char, size := decodeRune(pattern[i:])
+		if named && strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				i += 2 + end + 2
+				continue
+			}
+		}
+		switch {
+		case char == '\\':
+			i += size
+			if i < len(pattern) {
+				_, size = decodeRune(pattern[i:])
+				i += size
+			}
+		case char == ']':
+			return i + size
+		case len(pattern)-i > size+1 && pattern[i+size] == '-':
+			_, endSize := decodeRune(pattern[i+size+1:])
+			i += size + 1 + endSize
+		default:
+			i += size
+		}
+	}
+	return len(pattern)
+}
+
+// matchSegments matches str segment by segment. Every segment pattern
+// consumes exactly one segment of str, so a single "**" checkpoint is
+// enough, as for '*' in match.
+func (p *Pattern) matchSegments(str string, fold bool) bool {
+	segments := p.segments
+	// pos is the start of the next segment of str; len(str)+1 means str is
+	// used up.
+	index, pos := 0, 0
+	starIndex, starPos := -1, 0
+	for {
+		if index < len(segments) {
+			if segments[index] == nil {
+				starIndex, starPos = index, pos
+				index++
+				continue
+			}
+			if pos <= len(str) {
+				end, next := p.nextSegment(str, pos)
+				if segments[index].match(str[pos:end], fold) {
+					index, pos = index+1, next
+					continue
+				}
+			}
+		} else if pos > len(str) {
+			return true
+		}
+		if starIndex < 0 || starPos > len(str) {
+			return false
+		}
+		_, starPos = p.nextSegment(str, starPos)
+		index, pos = starIndex+1, starPos
+	}
+}
+
+// segmentsProgram compiles a Separator pattern into one program, passing
+// the tokens of each segment through convert, and joins the segments with
+// separator edges. A "**" between segments becomes (sep X)*, where X is any
+// run without the separator; a leading one becomes (X sep)*, and a "**" on
+// its own X (sep X)*. Only a literal separator may consume the separator;
+// see segmentMatches.
+func (p *Pattern) segmentsProgram(convert func([]token) []token) []inst {
+	sep := []token{{kind: tokenLiteral, char: p.separator}}
+	star := []token{{kind: tokenStar}}
+	var prog []inst
+	emitted := false
+	for i, segment := range p.segments {
+		switch {
+		case segment != nil:
+			if emitted {
+				prog = compileProgram(prog, sep)
+			}
+			prog = compileProgram(prog, convert(segment.tokenStream()))
+			emitted = true
+		case emitted:
+			prog = compileRepeat(prog, append(sep, star...))
+		case i+1 < len(p.segments):
+			prog = compileRepeat(prog, append(star, sep...))
+		default:
+			prog = compileProgram(prog, star)
+			prog = compileRepeat(prog, append(sep, star...))
+		}
+	}
+	return append(prog, inst{op: instMatch})
+}
+
+// compileRepeat appends the instructions for zero or more repetitions of
+// body, which must consume at least one rune.
+func compileRepeat(prog []inst, body []token) []inst {
+	loop := len(prog)
+	prog = append(prog, inst{op: instSplit, x: loop + 1})
+	prog = compileProgram(prog, body)
+	prog = append(prog, inst{op: instJump, x: loop})
+	prog[loop].y = len(prog)
+	return prog
+}
+
+// segmentMatches reports whether the instruction tok of segmentsProg
+// consumes char. Only the literal separator consumes the separator.
+func (p *Pattern) segmentMatches(tok *token, char rune, fold bool) bool {
+	if isSep := tok.kind == tokenLiteral && tok.char == p.separator; isSep || char == p.separator {
+		return isSep && char == p.separator
+	}
+	return p.tokenMatches(tok, char, fold)
+}
+
+// findSegments is find for a Separator pattern. It runs segmentsProg with a
+// thread for every start, as findProgram does, but keeps the leftmost start
+// and runs on to the longest match from it. Threads are kept in order of
+// their start, so a program counter is held by the earliest start that
+// reaches it.
+func (p *Pattern) findSegments(str string, fold bool) (int, int) {
+	prog := p.segmentsProg
+	m := machinePool.Get().(*machine)
+	defer machinePool.Put(m)
+	m.current.reset(len(prog))
+	m.next.reset(len(prog))
+	prefix, _ := p.segmentsLiteralPrefix()
+	start, end := -1, -1
+	for i := 0; ; {
+		if start < 0 {
+			if len(m.current.dense) == 0 && prefix != "" {
+				index, _ := indexLiteral(str[i:], prefix, fold)
+				if index < 0 {
+					return -1, -1
+				}
+				i += index
+			}
+			m.add(prog, &m.current, 0, i)
+		}
+		for k, pc := range m.current.dense {
+			if prog[pc].op == instMatch {
+				if s := m.current.starts[k]; start < 0 || s <= start {
+					start, end = s, i
+				}
+				break
+			}
+		}
+		if start >= 0 {
+			// Threads that started later cannot beat the match.
+			k := 0
+			for k < len(m.current.dense) && m.current.starts[k] <= start {
+				k++
+			}
+			m.current.dense, m.current.starts = m.current.dense[:k], m.current.starts[:k]
+		}
+		if i == len(str) || len(m.current.dense) == 0 && start >= 0 {
+			return start, end
+		}
+		char, size := decodeRune(str[i:])
+		m.next.clear()
+		for k, pc := range m.current.dense {
+			if in := &prog[pc]; in.op == instRune && p.segmentMatches(in.tok, char, fold) {
+				m.add(prog, &m.next, pc+1, m.current.starts[k])
+			}
+		}
+		m.current, m.next = m.next, m.current
+		i += size
+	}
+}
+
+// segmentsSubmatchIndex is SubmatchIndex for a Separator pattern. It
+// repeats the walk of matchSegments, recording where each segment of the
+// pattern matched, then collects the captures of each in turn. A "**" is a
+// capture of the whole segments it matched, without the separators around
+// them, or an empty one where it matched none.
+func (p *Pattern) segmentsSubmatchIndex(str string, fold bool) ([]int, bool) {
+	segments := p.segments
+	spans := make([]int, 2*len(segments))
+	index, pos := 0, 0
+	starIndex, starPos := -1, 0
+	for {
+		if index < len(segments) {
+			if segments[index] == nil {
+				starIndex, starPos = index, pos
+				spans[2*index] = pos
+				index++
+				continue
+			}
+			if pos <= len(str) {
+				end, next := p.nextSegment(str, pos)
+				if segments[index].match(str[pos:end], fold) {
+					spans[2*index], spans[2*index+1] = pos, end
+					index, pos = index+1, next
+					continue
+				}
+			}
+		} else if pos > len(str) {
+			break
+		}
+		if starIndex < 0 || starPos > len(str) {
+			return nil, false
+		}
+		_, starPos = p.nextSegment(str, starPos)
+		index, pos = starIndex+1, starPos
+	}
+	out := make([]int, 0, 2*len(segments))
+	for i, segment := range segments {
+		start, end := spans[2*i], spans[2*i+1]
+		if segment == nil {
+			// The segment after a "**" starts where it stopped, and a
+			// trailing "**" runs to the end.
+			switch {
+			case start > len(str):
+				start, end = len(str), len(str)
+			case i+1 == len(segments):
+				end = len(str)
+			case spans[2*(i+1)] == start:
+				end = start
+			default:
+				end = spans[2*(i+1)] - utf8.RuneLen(p.separator)
+			}
+			out = append(out, start, end)
+			continue
+		}
+		captures, _ := segment.submatchIndex(str[start:end], fold)
+		for _, offset := range captures {
+			if offset >= 0 {
+				offset += start
+			}
+			out = append(out, offset)
+		}
+	}
+	return out, true
+}
+
+// nextSegment returns the end of the segment of str starting at pos and the
+// start of the one after it, or len(str)+1 if it is the last.
+func (p *Pattern) nextSegment(str string, pos int) (end, next int) {
+	var i int
+	if p.separator < utf8.RuneSelf {
+		i = strings.IndexByte(str[pos:], byte(p.separator))
+	} else {
+		i = strings.IndexRune(str[pos:], p.separator)
+	}
+	if i < 0 {
+		return len(str), len(str) + 1
+	}
+	return pos + i, pos + i + utf8.RuneLen(p.separator)
+}
+
+// segmentsLiteralPrefix is LiteralPrefix for a Separator pattern: the
+// literal prefixes of whole literal segments, joined by the separator.
+func (p *Pattern) segmentsLiteralPrefix() (string, bool) {
+	var prefix []byte
+	for i, segment := range p.segments {
+		if segment == nil {
+			return string(prefix), false
+		}
+		lit, complete := segment.LiteralPrefix()
+		prefix = append(prefix, lit...)
+		if !complete || p.trailingGlobstar(i+1) {
+			return string(prefix), false
+		}
+		if i+1 < len(p.segments) {
+			prefix = utf8.AppendRune(prefix, p.separator)
+		}
+	}
+	return string(prefix), true
+}
+
+// segmentsBounds is tokenBounds for a Separator pattern.
+func (p *Pattern) segmentsBounds(fold bool) (lo, hi string, complete bool) {
+	var loBuf, hiBuf []byte
+	for i, segment := range p.segments {
+		if segment == nil {
+			return string(loBuf), string(hiBuf), false
+		}
+		segmentLo, segmentHi, complete := tokenBounds(segment.tokenStream(), fold)
+		loBuf, hiBuf = append(loBuf, segmentLo...), append(hiBuf, segmentHi...)
+		if !complete || p.trailingGlobstar(i+1) {
+			return string(loBuf), string(hiBuf), false
+		}
+		if i+1 < len(p.segments) {
+			loBuf = utf8.AppendRune(loBuf, p.separator)
+			hiBuf = utf8.AppendRune(hiBuf, p.separator)
+		}
+	}
+	return string(loBuf), string(hiBuf), true
+}
+
+// trailingGlobstar reports whether segment i is a final "**", which also
+// matches nothing, so that "a/**" matches "a" without the separator.
+func (p *Pattern) trailingGlobstar(i int) bool {
+	return i == len(p.segments)-1 && p.segments[i] == nil
+}
@@ -0,0 +1,254 @@
+package redglob
+
+import (
+	"errors"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func compileSeparator(t testing.TB, pattern string, sep rune) *Pattern {
+	t.Helper()
+	p, err := CompileOptions{Separator: sep}.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Compile(%q) with separator %q: %v", pattern, sep, err)
+	}
+	return p
+}
+
+func TestSeparator(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"a/*", "a/b", true},
+		{"a/*", "a/b/c", false},
+		{"a/*/c", "a/b/c", true},
+		{"a/*/c", "a//c", true},
+		{"a?c", "a/c", false},
+		{"a[^x]c", "a/c", false},
+		{"a[/]c", "a/c", false},
+		{`a\/b`, "a/b", true},
+		{"a/**", "a", true},
+		{"a/**", "a/", true},
+		{"a/**", "a/b/c", true},
+		{"a/**", "ab", false},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/b/c", true},
+		{"a/**/c", "a/b/d/c", true},
+		{"a/**/c", "a/b/d/cc", false},
+		{"**/c", "c", true},
+		{"**/c", "x/y/c", true},
+		{"**/c", "x/yc", false},
+		{"**", "", true},
+		{"**", "a/b/c", true},
+		{"a/**/**/b", "a/b", true},
+		{"a/**/b/**/c", "a/x/b/y/b/z/c", true},
+		{"a/**/b/*", "a/x/b/y/z", false},
+		{"a**b", "axb", true},
+		{"a**b", "a/b", false},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "cmd/main.go", true},
+		{"", "", true},
+		{"", "/", false},
+		{"/", "/", true},
+		{"*/*", "/", true},
+	}
+	for _, tt := range tests {
+		p := compileSeparator(t, tt.pattern, '/')
+		if got := p.Match(tt.str); got != tt.want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+		if got := p.MatchBytes([]byte(tt.str)); got != tt.want {
+			t.Errorf("Compile(%q).MatchBytes(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+		if _, ok := p.Submatch(tt.str); ok != tt.want {
+			t.Errorf("Compile(%q).Submatch(%q) ok = %v, want %v", tt.pattern, tt.str, ok, tt.want)
+		}
+	}
+}
+
+func TestSeparatorSubmatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		want         []string
+	}{
+		{"a/*/c", "a/b/c", []string{"b"}},
+		{"a/**", "a", []string{""}},
+		{"a/**", "a/", []string{""}},
+		{"a/**", "a/b/c", []string{"b/c"}},
+		{"a/**/c", "a/c", []string{""}},
+		{"a/**/c", "a/b/d/c", []string{"b/d"}},
+		{"**/*.go", "cmd/main.go", []string{"cmd", "main"}},
+		{"**/*.go", "main.go", []string{"", "main"}},
+		{"**", "", []string{""}},
+		{"**/b/**", "a/b/b/c", []string{"a", "b/c"}},
+		{"s/?/*/**", "s/x/y", []string{"x", "y", ""}},
+	}
+	for _, tt := range cases {
+		p := compileSeparator(t, tt.pattern, '/')
+		got, ok := p.Submatch(tt.str)
+		if !ok || !slices.Equal(got, tt.want) {
+			t.Errorf("Compile(%q).Submatch(%q) = %q, %v; want %q, true", tt.pattern, tt.str, got, ok, tt.want)
+		}
+	}
+	p := compileSeparator(t, "a·**·c", '·')
+	index, ok := p.SubmatchIndex("a·x·y·c")
+	if want := []int{3, 7}; !ok || !slices.Equal(index, want) {
+		t.Errorf("SubmatchIndex = %v, %v; want %v, true", index, ok, want)
+	}
+
+	r := rand.New(rand.NewPCG(7, 8))
+	random := func(alphabet string, n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	for range 20000 {
+		pattern, str := random("ab/*?", 8), random("ab/", 7)
+		p := compileSeparator(t, pattern, '/')
+		if _, ok := p.Submatch(str); ok != p.Match(str) {
+			t.Fatalf("Compile(%q).Submatch(%q) ok = %v, Match = %v", pattern, str, ok, !ok)
+		}
+	}
+}
+
+func TestSeparatorFoldAndRunes(t *testing.T) {
+	p := compileSeparator(t, "user:*:SESSION", ':')
+	if !p.MatchFold("USER:42:session") || p.MatchFold("user:4:2:session") {
+		t.Error("MatchFold with ':' separator")
+	}
+	p = compileSeparator(t, "a→*→**→z", '→')
+	for str, want := range map[string]bool{"a→b→z": true, "a→b→c→d→z": true, "a→b→c": false, "a→bc": false} {
+		if got := p.Match(str); got != want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", "a→*→**→z", str, got, want)
+		}
+	}
+}
+
+func TestSeparatorErrors(t *testing.T) {
+	_, err := CompileOptions{Separator: '/'}.Compile("a/b/[c")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Code != ErrUnterminatedClass || syntaxErr.Offset != 4 || syntaxErr.Pattern != "a/b/[c" {
+		t.Errorf("unterminated class in last segment: %v", err)
+	}
+	_, err = CompileOptions{Separator: '/'}.Compile("a/[b/c]")
+	if err != nil {
+		t.Errorf("class containing the separator: %v", err)
+	}
+	for _, opts := range []CompileOptions{{Separator: '*'}, {Separator: '\\'}, {Separator: 0xd800}, {Separator: '/', Mode: ModeRedisBytes}} {
+		if _, err := opts.Compile("a"); err == nil {
+			t.Errorf("%+v: no error", opts)
+		}
+	}
+}
+
+// TestSeparatorPathMatch compares with path.Match, which has the same
+// semantics for '*' and '?' with '/' as the separator.
+func TestSeparatorPathMatch(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	random := func(alphabet string, n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	for range 20000 {
+		pattern, str := random("ab/*?", 7), random("ab/", 7)
+		if strings.Contains(pattern, "**") {
+			continue
+		}
+		want, err := path.Match(pattern, str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := compileSeparator(t, pattern, '/').Match(str); got != want {
+			t.Fatalf("Compile(%q).Match(%q) = %v, path.Match = %v", pattern, str, got, want)
+		}
+	}
+}
+
+func TestSeparatorLiteralPrefix(t *testing.T) {
+	tests := []struct {
+		pattern      string
+		prefix       string
+		complete     bool
+		lo, hi       string
+		keyRangeOkay bool
+	}{
+		{"a/b/c", "a/b/c", true, "a/b/c", "a/b/c\x00", true},
+		{"a/b*/c", "a/b", false, "a/b", "a/c", true},
+		{"a/**/c", "a/", false, "a/", "a0", true},
+		{"a/**", "a", false, "a", "b", true},
+		{"a/[x-z]/c", "a/", false, "a/x/c", "a/z/c\x00", true},
+		{"**/c", "", false, "", "", false},
+	}
+	for _, tt := range tests {
+		p := compileSeparator(t, tt.pattern, '/')
+		prefix, complete := p.LiteralPrefix()
+		if prefix != tt.prefix || complete != tt.complete {
+			t.Errorf("Compile(%q).LiteralPrefix() = %q, %v, want %q, %v", tt.pattern, prefix, complete, tt.prefix, tt.complete)
+		}
+		lo, hi, ok := p.KeyRange()
+		if lo != tt.lo || hi != tt.hi || ok != tt.keyRangeOkay {
+			t.Errorf("Compile(%q).KeyRange() = %q, %q, %v, want %q, %q, %v", tt.pattern, lo, hi, ok, tt.lo, tt.hi, tt.keyRangeOkay)
+		}
+	}
+}
+
+func TestSeparatorExpand(t *testing.T) {
+	p := compileSeparator(t, "s/?/[a/]", '/')
+	if !p.IsFinite() {
+		t.Fatal("IsFinite() = false")
+	}
+	got := slices.Collect(p.Expand(Alphabet("x/"), 0))
+	if want := []string{"s/x/a"}; !slices.Equal(got, want) {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+	if compileSeparator(t, "s/**", '/').IsFinite() {
+		t.Error(`Compile("s/**").IsFinite() = true`)
+	}
+}
+
+func TestSeparatorUnsupported(t *testing.T) {
+	p := compileSeparator(t, "a/*", '/')
+	if p.Regexp() != nil {
+		t.Error("Regexp() != nil")
+	}
+}
+
+func TestSeparatorMatchPrefix(t *testing.T) {
+	tests := []struct {
+		pattern, prefix string
+		want            Feasibility
+	}{
+		{"a/*", "b", NoMatch},
+		{"a/*", "a/x", MaybeMatch},
+		{"a/*", "a/x/", NoMatch},
+		{"a/**", "a/", MatchesAllExtensions},
+		{"a/**/b", "a/x/y", MaybeMatch},
+		{"**/b", "x/y/", MaybeMatch},
+		{"a/b", "a/c", NoMatch},
+		{"**", "", MatchesAllExtensions},
+	}
+	for _, tt := range tests {
+		if got := compileSeparator(t, tt.pattern, '/').MatchPrefix(tt.prefix); got != tt.want {
+			t.Errorf("Compile(%q).MatchPrefix(%q) = %v, want %v", tt.pattern, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkSeparator(b *testing.B) {
+	p := compileSeparator(b, "services/**/config/*.yaml", '/')
+	str := "services/api/v2/internal/config/server.yaml"
+	for b.Loop() {
+		p.Match(str)
+	}
+}
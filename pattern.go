@@ -12,10 +12,31 @@ type Pattern struct {
 	tokens       []token
 	prefix       string
 	suffix       string
+	source       string // original pattern, kept for ModeRedisBytes and Separator
+	mode         Mode
 	valid        bool
 	simple       bool
 	hasStar      bool
 	literalStars bool
+
+	// With CompileOptions.Separator, the pattern is matched segment by
+	// segment; a nil segment is "**". segmentsProg is the same pattern as
+	// one program over the whole string, for searching.
+	separator    rune
+	segments     []*Pattern
+	segmentsProg []inst
+
+	// With CompileOptions.Braces, a pattern with a group is matched by
+	// running prog instead of walking tokens. captureProg is the program
+	// for SubmatchIndex, with captures captures.
+	prog        []inst
+	captureProg []inst
+	captures    int
+
+	// With ModeRedisBytes, source is matched by stringmatchRedis; redis
+	// holds it as tokens for everything else. See compileRedis.
+	redis     []redisToken
+	redisDeep bool
 }
 
 type token struct {
@@ -24,15 +45,18 @@ type token struct {
 	lit   string // multi-rune literal run (tokenLiteralRun)
 	count int    // consecutive '?' count (tokenAnyN)
 	class *compiledClass
+	alt   *alternation // brace group (tokenAlt)
 }
 
 // compiledClass holds a 128-bit membership map for ASCII. The common single
-// range is inline; larger classes allocate an overflow slice.
+// range is inline; larger classes allocate an overflow slice. Unicode
+// properties are kept as tables, with their ASCII members in the map.
 type compiledClass struct {
 	bits       [2]uint64
 	rangeOne   charRange
 	ranges     []charRange
 	rangeCount int
+	tables     []classTable
 	negated    bool
 }
 
@@ -45,6 +69,7 @@ const (
 	tokenAnyN
 	tokenStar
 	tokenClass
+	tokenAlt
 )
 
 type charRange struct {
@@ -52,39 +77,122 @@ type charRange struct {
 }
 
 // Compile parses pattern for repeated matching. Invalid patterns compile to a
-// Pattern that never matches, consistent with Match's existing behavior.
+// Pattern that never matches, consistent with Match's existing behavior. Use
+// CompileErr to find out why a pattern is invalid.
 func Compile(pattern string) *Pattern {
+	p, _ := compile(pattern)
+	return p
+}
+
+// CompileErr is like Compile but rejects malformed patterns, such as an
+// unterminated class or a trailing backslash. The returned error is a
+// *SyntaxError locating the offending construct. An empty class such as "[]"
+// is well formed; the pattern is returned and never matches.
+func CompileErr(pattern string) (*Pattern, error) {
+	p, err := compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate reports whether pattern is well formed. It returns the same
+// *SyntaxError as CompileErr, or nil.
+func Validate(pattern string) error {
+	_, err := compile(pattern)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// compile returns the compiled pattern together with the first syntax error,
+// which also leaves p.valid false. An empty class such as "[]" is not an
+// error: the pattern is valid and never matches.
+func compile(pattern string) (*Pattern, *SyntaxError) {
 	p := &Pattern{valid: true}
 	if p.prefix, p.suffix, p.hasStar, p.simple = splitSimplePattern(pattern); p.simple {
-		return p
+		return p, nil
 	}
 	if isLiteralStarsPattern(pattern) {
 		p.literalStars = true
 		p.prefix = pattern
-		return p
+		return p, nil
 	}
+	t := tokenizer{source: pattern}
+	var err *SyntaxError
+	if p.tokens, _, err = t.sequence(0, false); err != nil {
+		p.valid = false
+		return p, err
+	}
+	return p, nil
+}
+
+// extensions holds the opt-in syntax of CompileOptions.
+type extensions struct {
+	braces       bool
+	namedClasses bool
+}
+
+// compile compiles pattern with the extensions. Patterns that cannot use
+// any of them compile exactly as without, fast paths included.
+func (ext extensions) compile(pattern string) (*Pattern, *SyntaxError) {
+	if !(ext.braces && strings.Contains(pattern, "{")) &&
+		!(ext.namedClasses && strings.Contains(pattern, "[")) {
+		return compile(pattern)
+	}
+	p := &Pattern{valid: true}
+	t := tokenizer{source: pattern, extensions: ext}
+	var err *SyntaxError
+	if p.tokens, _, err = t.sequence(0, false); err != nil {
+		p.valid = false
+		return p, err
+	}
+	if t.grouped {
+		p.prog = compileProgram(nil, p.tokens)
+		p.prog = append(p.prog, inst{op: instMatch})
+		p.captureProg = compileCaptures(nil, p.tokens, &p.captures)
+		p.captureProg = append(p.captureProg, inst{op: instMatch})
+	}
+	return p, nil
+}
+
+// tokenizer turns pattern text into tokens. With braces set, '{' starts a
+// group and a sequence inside a group stops at ',' or '}'.
+type tokenizer struct {
+	extensions
+	source  string
+	grouped bool // a brace group was compiled
+}
+
+// sequence compiles source from offset to its end, or, if nested, to the ','
+// or '}' that ends the current alternative. It returns the tokens and the
+// offset where it stopped.
+func (t *tokenizer) sequence(offset int, nested bool) ([]token, int, *SyntaxError) {
+	source := t.source
+	pattern := source[offset:]
 	// Most patterns compile to only a few tokens. Keep the initial allocation
 	// bounded so a long literal does not retain a token array many times larger
 	// than the pattern itself.
-	p.tokens = make([]token, 0, min(len(pattern)/2+1, 32))
+	tokens := make([]token, 0, min(len(pattern)/2+1, 32))
 	var litBuf []byte
 	flushLit := func() {
 		if len(litBuf) == 0 {
 			return
 		}
 		if len(litBuf) == 1 && litBuf[0] < utf8.RuneSelf {
-			p.tokens = append(p.tokens, token{
+			tokens = append(tokens, token{
 				kind: tokenLiteral,
 				char: rune(litBuf[0]),
 			})
 		} else if r, n := utf8.DecodeRune(litBuf); n == len(litBuf) && r != utf8.RuneError {
 			// Single well-formed non-ASCII rune.
-			p.tokens = append(p.tokens, token{
+			tokens = append(tokens, token{
 				kind: tokenLiteral,
 				char: r,
 			})
 		} else {
-			p.tokens = append(p.tokens, token{
+			tokens = append(tokens, token{
 				kind: tokenLiteralRun,
 				lit:  string(litBuf),
 			})
@@ -97,7 +205,7 @@ func Compile(pattern string) *Pattern {
 		// stay as single-rune tokens and must not merge into byte runs.
 		if char == utf8.RuneError && (len(raw) != 3 || raw != string(utf8.RuneError)) {
 			flushLit()
-			p.tokens = append(p.tokens, token{
+			tokens = append(tokens, token{
 				kind: tokenLiteral,
 				char: utf8.RuneError,
 			})
@@ -110,48 +218,74 @@ func Compile(pattern string) *Pattern {
 		switch char {
 		case '*':
 			flushLit()
-			if len(p.tokens) == 0 || p.tokens[len(p.tokens)-1].kind != tokenStar {
-				p.tokens = append(p.tokens, token{kind: tokenStar})
+			if len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenStar {
+				tokens = append(tokens, token{kind: tokenStar})
 			}
 		case '?':
 			flushLit()
-			if n := len(p.tokens); n > 0 && p.tokens[n-1].kind == tokenAnyN {
-				p.tokens[n-1].count++
-			} else if n > 0 && p.tokens[n-1].kind == tokenAny {
-				p.tokens[n-1] = token{kind: tokenAnyN, count: 2}
+			if n := len(tokens); n > 0 && tokens[n-1].kind == tokenAnyN {
+				tokens[n-1].count++
+			} else if n > 0 && tokens[n-1].kind == tokenAny {
+				tokens[n-1] = token{kind: tokenAnyN, count: 2}
 			} else {
-				p.tokens = append(p.tokens, token{kind: tokenAny})
+				tokens = append(tokens, token{kind: tokenAny})
 			}
 		case '[':
 			flushLit()
-			var class token
-			class, pattern, p.valid = compileClass(pattern[size:])
-			if !p.valid {
-				return p
+			offset := len(source) - len(pattern)
+			class, rest, code := compileClass(pattern[size:], t.namedClasses)
+			switch code {
+			case "":
+			case ErrUnterminatedClass:
+				return tokens, len(source), newSyntaxError(code, source, offset)
+			default:
+				return tokens, len(source), newSyntaxError(code, source, len(source)-len(rest))
 			}
-			p.tokens = append(p.tokens, class)
+			tokens = append(tokens, class)
+			pattern = rest
 			continue
 		case '\\':
+			offset := len(source) - len(pattern)
 			pattern = pattern[size:]
 			if len(pattern) == 0 {
-				p.valid = false
-				return p
+				return tokens, len(source), newSyntaxError(ErrDanglingEscape, source, offset)
 			}
 			char, size = decodeRune(pattern)
 			appendLiteral(char, pattern[:size])
+		case '{', ',', '}':
+			offset := len(source) - len(pattern)
+			switch {
+			case t.braces && char == '{':
+				flushLit()
+				group, end, err := t.group(offset)
+				if err != nil {
+					return tokens, len(source), err
+				}
+				tokens = append(tokens, group)
+				pattern = source[end:]
+				continue
+			case t.braces && nested:
+				flushLit()
+				return tokens, offset, nil
+			}
+			appendLiteral(char, pattern[:size])
 		default:
 			appendLiteral(char, pattern[:size])
 		}
 		pattern = pattern[size:]
 	}
 	flushLit()
-	return p
+	return tokens, len(source), nil
 }
 
-func compileClass(pattern string) (token, string, bool) {
+// compileClass compiles the class whose body starts pattern. On error it
+// returns the code and, except for ErrUnterminatedClass, the rest of the
+// pattern from the offending item. With named set, the body may contain
+// POSIX classes and Unicode properties.
+func compileClass(pattern string, named bool) (token, string, ErrorCode) {
 	class := &compiledClass{}
 	if len(pattern) == 0 {
-		return token{kind: tokenClass}, pattern, false
+		return token{kind: tokenClass}, pattern, ErrUnterminatedClass
 	}
 	if pattern[0] == '^' {
 		class.negated = true
@@ -159,17 +293,35 @@ func compileClass(pattern string) (token, string, bool) {
 	}
 	for {
 		if len(pattern) == 0 {
-			return token{kind: tokenClass}, pattern, false
+			return token{kind: tokenClass}, pattern, ErrUnterminatedClass
+		}
+		if named {
+			rest, ok, code := class.posixClass(pattern)
+			if code != "" {
+				return token{kind: tokenClass}, pattern, code
+			}
+			if ok {
+				pattern = rest
+				continue
+			}
+			if len(pattern) > 1 && pattern[0] == '\\' && (pattern[1] == 'p' || pattern[1] == 'P') {
+				rest, code := class.unicodeClass(pattern[2:], pattern[1] == 'P')
+				if code != "" {
+					return token{kind: tokenClass}, pattern, code
+				}
+				pattern = rest
+				continue
+			}
 		}
 		start, size := decodeRune(pattern)
 		if start == '\\' {
 			pattern = pattern[size:]
 			if len(pattern) == 0 {
-				return token{kind: tokenClass}, pattern, false
+				return token{kind: tokenClass}, pattern, ErrUnterminatedClass
 			}
 			start, size = decodeRune(pattern)
 		} else if start == ']' {
-			return token{kind: tokenClass, class: class}, pattern[size:], true
+			return token{kind: tokenClass, class: class}, pattern[size:], ""
 		} else if len(pattern) > size+1 && pattern[size] == '-' {
 			pattern = pattern[size+1:]
 			end, endSize := decodeRune(pattern)
@@ -215,10 +367,13 @@ func (class *compiledClass) contains(char rune) bool {
 	if char <= unicode.MaxASCII {
 		return asciiBit(class.bits, byte(char))
 	}
-	return class.matchesRange(char)
+	return class.matchesRange(char) || len(class.tables) > 0 && class.matchesTables(char)
 }
 
 func (class *compiledClass) matchesFold(char rune) bool {
+	if len(class.tables) > 0 {
+		return class.matchesFoldTables(char)
+	}
 	candidate := char
 	for {
 		if class.contains(candidate) {
@@ -268,7 +423,8 @@ func (p *Pattern) Match(str string) bool {
 }
 
 // MatchFold reports whether str matches the compiled pattern using Unicode
-// simple case folding.
+// simple case folding. Patterns compiled with ModeRedisBytes fold like
+// MatchRedisFold instead.
 func (p *Pattern) MatchFold(str string) bool {
 	return p.match(str, true)
 }
@@ -288,6 +444,15 @@ func (p *Pattern) match(str string, fold bool) bool {
 	if p == nil || !p.valid {
 		return false
 	}
+	if p.mode == ModeRedisBytes {
+		return stringmatchRedis(str, p.source, fold)
+	}
+	if p.segments != nil {
+		return p.matchSegments(str, fold)
+	}
+	if p.prog != nil {
+		return p.matchProgram(str, fold)
+	}
 	if p.simple {
 		if !p.hasStar {
 			if fold {
@@ -0,0 +1,419 @@
+// Package pubsub is an in-process publish/subscribe router with the
+// semantics of Redis's SUBSCRIBE, PSUBSCRIBE and PUBLISH, for emulating
+// Redis pub/sub in tests and edge services.
+//
+// As in Redis, a subscriber receives a message once for the channel if it
+// subscribed to it, and once more for every pattern it subscribed to that
+// matches the channel:
+//
+//	var r pubsub.Router
+//	sub := r.NewSubscriber()
+//	defer sub.Close()
+//	sub.Subscribe("news")
+//	sub.PSubscribe("n*")
+//	r.Publish("news", "hello") // 2: a message and a pmessage
+//	for msg := range sub.C {
+//		// msg.Pattern is "" for the message and "n*" for the pmessage
+//	}
+//
+// Patterns are compiled with redglob.ModeRedisBytes, so they match channels
+// byte for byte as in Redis, and every pattern is accepted. Publish looks
+// them up in a redglob.PatternSet, so its cost grows with the number of
+// matching patterns rather than the number of subscribed ones.
+package pubsub
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/maolonglong/redglob"
+)
+
+// DefaultBufferSize is the capacity of a subscriber's channel when
+// Router.BufferSize is zero.
+const DefaultBufferSize = 128
+
+// SlowConsumerPolicy decides what Publish does when a subscriber's channel
+// is full.
+type SlowConsumerPolicy uint8
+
+const (
+	// DropNewest discards the message being published for that
+	// subscriber.
+	DropNewest SlowConsumerPolicy = iota
+	// DropOldest discards the oldest message in the channel to make room.
+	DropOldest
+	// Block waits until the subscriber makes room or is closed, which
+	// also holds up delivery to the other subscribers.
+	Block
+	// Disconnect closes the subscriber, as Redis does when a client
+	// exceeds its pubsub output buffer limit.
+	Disconnect
+)
+
+// compile returns the matcher for a PSUBSCRIBE pattern. Compiling in
+// ModeRedisBytes cannot fail, just as Redis subscribes to any pattern.
+func compile(pattern string) *redglob.Pattern {
+	p, _ := redglob.CompileOptions{Mode: redglob.ModeRedisBytes}.Compile(pattern)
+	return p
+}
+
+// Message is a message delivered to a subscriber.
+type Message struct {
+	// Pattern is the pattern that matched Channel for a pmessage, or ""
+	// for a message on a subscribed channel.
+	Pattern string
+	Channel string
+	Payload string
+}
+
+// Router routes published messages to subscribers. The zero value is a
+// router ready to use; the exported fields must not be changed after the
+// first subscriber is created. A Router is safe for concurrent use.
+type Router struct {
+	// BufferSize is the capacity of each subscriber's channel. Zero means
+	// DefaultBufferSize.
+	BufferSize int
+	// Policy applies when a subscriber's channel is full.
+	Policy SlowConsumerPolicy
+
+	mu       sync.RWMutex
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+	index    redglob.PatternSet[string]
+}
+
+// Subscriber is one client of a Router, with its own set of channels and
+// patterns and a single channel of messages. Its methods are safe for
+// concurrent use.
+type Subscriber struct {
+	// C delivers the messages. It is closed by Close, and when the
+	// Disconnect policy drops the subscriber.
+	C <-chan Message
+
+	router  *Router
+	c       chan Message
+	done    chan struct{}
+	once    sync.Once
+	sendMu  sync.Mutex // serializes sends with closing c
+	dropped atomic.Uint64
+
+	// Guarded by router.mu.
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// NewSubscriber returns a subscriber with no subscriptions.
+func (r *Router) NewSubscriber() *Subscriber {
+	size := r.BufferSize
+	if size == 0 {
+		size = DefaultBufferSize
+	}
+	c := make(chan Message, size)
+	return &Subscriber{
+		C:        c,
+		router:   r,
+		c:        c,
+		done:     make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Publish delivers payload to the subscribers of channel and of every
+// pattern matching it, and returns the number of deliveries, counted as
+// Redis counts PUBLISH receivers: a subscriber of the channel and of two
+// matching patterns counts three times. Messages dropped under the
+// DropNewest or Disconnect policy are not counted.
+func (r *Router) Publish(channel, payload string) (receivers int) {
+	type delivery struct {
+		sub *Subscriber
+		msg Message
+	}
+	var deliveries []delivery
+	r.mu.RLock()
+	for sub := range r.channels[channel] {
+		deliveries = append(deliveries, delivery{sub, Message{Channel: channel, Payload: payload}})
+	}
+	for _, pattern := range r.index.Match(channel) {
+		for sub := range r.patterns[pattern] {
+			deliveries = append(deliveries, delivery{sub, Message{Pattern: pattern, Channel: channel, Payload: payload}})
+		}
+	}
+	r.mu.RUnlock()
+
+	// Deliver outside the lock, so that a blocked send does not hold up
+	// subscription changes, including the Close that unblocks it.
+	for _, d := range deliveries {
+		if d.sub.deliver(d.msg, r.Policy) {
+			receivers++
+		}
+	}
+	return receivers
+}
+
+// NumSub returns the number of subscribers of channel, like PUBSUB NUMSUB.
+func (r *Router) NumSub(channel string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.channels[channel])
+}
+
+// NumPat returns the number of distinct patterns subscribed to, like
+// PUBSUB NUMPAT.
+func (r *Router) NumPat() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.patterns)
+}
+
+// Channels returns the channels with at least one subscriber that match
+// pattern, or all of them if pattern is "", in sorted order, like PUBSUB
+// CHANNELS.
+func (r *Router) Channels(pattern string) []string {
+	p := compile(pattern)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var channels []string
+	for channel := range r.channels {
+		if pattern == "" || p.Match(channel) {
+			channels = append(channels, channel)
+		}
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+// Subscribe subscribes to channels and returns the number of channels and
+// patterns the subscriber is now subscribed to. Channels already
+// subscribed to are ignored. Subscribe does nothing on a closed
+// subscriber.
+func (s *Subscriber) Subscribe(channels ...string) int {
+	r := s.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.closed() {
+		return 0
+	}
+	for _, channel := range channels {
+		if _, ok := s.channels[channel]; ok {
+			continue
+		}
+		s.channels[channel] = struct{}{}
+		subs := r.channels[channel]
+		if subs == nil {
+			subs = make(map[*Subscriber]struct{})
+			if r.channels == nil {
+				r.channels = make(map[string]map[*Subscriber]struct{})
+			}
+			r.channels[channel] = subs
+		}
+		subs[s] = struct{}{}
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// PSubscribe subscribes to patterns and returns the number of channels and
+// patterns the subscriber is now subscribed to. As in Redis, every pattern
+// is accepted; one that can match no channel, such as "a[", simply receives
+// nothing. PSubscribe does nothing on a closed subscriber.
+func (s *Subscriber) PSubscribe(patterns ...string) int {
+	r := s.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.closed() {
+		return 0
+	}
+	for _, pattern := range patterns {
+		if _, ok := s.patterns[pattern]; ok {
+			continue
+		}
+		s.patterns[pattern] = struct{}{}
+		subs := r.patterns[pattern]
+		if subs == nil {
+			subs = make(map[*Subscriber]struct{})
+			if r.patterns == nil {
+				r.patterns = make(map[string]map[*Subscriber]struct{})
+			}
+			r.patterns[pattern] = subs
+			r.index.AddPattern(pattern, compile(pattern))
+		}
+		subs[s] = struct{}{}
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// Unsubscribe unsubscribes from channels, or from every channel if none
+// are given, and returns the number of channels and patterns the
+// subscriber is still subscribed to. On a closed subscriber, which is
+// subscribed to nothing, Unsubscribe does nothing and returns 0.
+func (s *Subscriber) Unsubscribe(channels ...string) int {
+	r := s.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(channels) == 0 {
+		channels = slices.Collect(maps.Keys(s.channels))
+	}
+	for _, channel := range channels {
+		s.unsubscribe(channel)
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// PUnsubscribe unsubscribes from patterns, or from every pattern if none
+// are given, and returns the number of channels and patterns the
+// subscriber is still subscribed to. Patterns are compared as text, as in
+// Redis, so "a[b]" does not undo a subscription to "ab". Like Unsubscribe,
+// PUnsubscribe does nothing on a closed subscriber.
+func (s *Subscriber) PUnsubscribe(patterns ...string) int {
+	r := s.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(patterns) == 0 {
+		patterns = slices.Collect(maps.Keys(s.patterns))
+	}
+	for _, pattern := range patterns {
+		s.punsubscribe(pattern)
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// Count returns the number of channels and patterns the subscriber is
+// subscribed to.
+func (s *Subscriber) Count() int {
+	s.router.mu.RLock()
+	defer s.router.mu.RUnlock()
+	return len(s.channels) + len(s.patterns)
+}
+
+// Dropped returns the number of messages dropped for the subscriber under
+// the DropNewest or DropOldest policy.
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes from everything and closes C. Messages already in C
+// can still be received. Close may be called more than once.
+func (s *Subscriber) Close() {
+	if !s.shutdown() {
+		return
+	}
+	s.sendMu.Lock()
+	close(s.c)
+	s.sendMu.Unlock()
+	s.detach()
+}
+
+// shutdown closes done the first time it is called and reports whether
+// this call did.
+func (s *Subscriber) shutdown() bool {
+	first := false
+	s.once.Do(func() {
+		close(s.done)
+		first = true
+	})
+	return first
+}
+
+func (s *Subscriber) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// detach removes every subscription of s from the router.
+func (s *Subscriber) detach() {
+	s.router.mu.Lock()
+	defer s.router.mu.Unlock()
+	for channel := range s.channels {
+		s.unsubscribe(channel)
+	}
+	for pattern := range s.patterns {
+		s.punsubscribe(pattern)
+	}
+}
+
+// unsubscribe and punsubscribe are called with router.mu held.
+func (s *Subscriber) unsubscribe(channel string) {
+	r := s.router
+	if _, ok := s.channels[channel]; !ok {
+		return
+	}
+	delete(s.channels, channel)
+	delete(r.channels[channel], s)
+	if len(r.channels[channel]) == 0 {
+		delete(r.channels, channel)
+	}
+}
+
+func (s *Subscriber) punsubscribe(pattern string) {
+	r := s.router
+	if _, ok := s.patterns[pattern]; !ok {
+		return
+	}
+	delete(s.patterns, pattern)
+	delete(r.patterns[pattern], s)
+	if len(r.patterns[pattern]) == 0 {
+		delete(r.patterns, pattern)
+		r.index.Remove(pattern)
+	}
+}
+
+// deliver sends msg to s under policy and reports whether it was queued.
+func (s *Subscriber) deliver(msg Message, policy SlowConsumerPolicy) bool {
+	s.sendMu.Lock()
+	if s.closed() {
+		s.sendMu.Unlock()
+		return false
+	}
+	select {
+	case s.c <- msg:
+		s.sendMu.Unlock()
+		return true
+	default:
+	}
+	switch policy {
+	case Block:
+		defer s.sendMu.Unlock()
+		select {
+		case s.c <- msg:
+			return true
+		case <-s.done:
+			// Close is waiting for sendMu to close c.
+			return false
+		}
+	case DropOldest:
+		defer s.sendMu.Unlock()
+		for {
+			select {
+			case <-s.c:
+				s.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.c <- msg:
+				return true
+			default:
+			}
+		}
+	case Disconnect:
+		disconnected := s.shutdown()
+		if disconnected {
+			close(s.c)
+		}
+		s.sendMu.Unlock()
+		if disconnected {
+			s.detach()
+		}
+		return false
+	default:
+		s.dropped.Add(1)
+		s.sendMu.Unlock()
+		return false
+	}
+}
@@ -0,0 +1,274 @@
+package pubsub
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive returns the messages waiting in sub.C.
+func receive(sub *Subscriber) []Message {
+	var msgs []Message
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var r Router
+	sub := r.NewSubscriber()
+	defer sub.Close()
+	if n := sub.Subscribe("news", "news"); n != 1 {
+		t.Errorf("Subscribe() = %d, want 1", n)
+	}
+	if n := sub.PSubscribe("n*", "*s", "n*"); n != 3 {
+		t.Errorf("PSubscribe() = %d, want 3", n)
+	}
+	other := r.NewSubscriber()
+	defer other.Close()
+	other.PSubscribe("n*")
+
+	if n := r.Publish("news", "hello"); n != 4 {
+		t.Errorf("Publish() = %d, want 4", n)
+	}
+	got := receive(sub)
+	slices.SortFunc(got, func(a, b Message) int { return strings.Compare(a.Pattern, b.Pattern) })
+	want := []Message{
+		{Channel: "news", Payload: "hello"},
+		{Pattern: "*s", Channel: "news", Payload: "hello"},
+		{Pattern: "n*", Channel: "news", Payload: "hello"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	if got := receive(other); !slices.Equal(got, want[2:]) {
+		t.Errorf("other received %v", got)
+	}
+	if n := r.Publish("sport", "goal"); n != 0 {
+		t.Errorf("Publish() = %d, want 0", n)
+	}
+	if n := r.Publish("nope", "x"); n != 2 {
+		t.Errorf("Publish() = %d, want 2", n)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	var r Router
+	sub := r.NewSubscriber()
+	defer sub.Close()
+	sub.Subscribe("a", "b")
+	sub.PSubscribe("ab", "a*")
+	if n := sub.PUnsubscribe("a[b]"); n != 4 {
+		t.Errorf("PUnsubscribe(a[b]) = %d, want 4", n)
+	}
+	if n := sub.PUnsubscribe("ab"); n != 3 {
+		t.Errorf("PUnsubscribe(ab) = %d, want 3", n)
+	}
+	if n := sub.Unsubscribe(); n != 1 {
+		t.Errorf("Unsubscribe() = %d, want 1", n)
+	}
+	if r.NumSub("a") != 0 || r.NumPat() != 1 {
+		t.Errorf("NumSub() = %d, NumPat() = %d", r.NumSub("a"), r.NumPat())
+	}
+	if n := r.Publish("ab", "x"); n != 1 {
+		t.Errorf("Publish() = %d, want 1", n)
+	}
+	if n := sub.PUnsubscribe(); n != 0 || r.NumPat() != 0 {
+		t.Errorf("PUnsubscribe() = %d, NumPat() = %d", n, r.NumPat())
+	}
+	if n := r.Publish("ab", "x"); n != 0 {
+		t.Errorf("Publish() = %d after unsubscribing", n)
+	}
+}
+
+func TestPSubscribeRedisPatterns(t *testing.T) {
+	var r Router
+	sub := r.NewSubscriber()
+	defer sub.Close()
+	// Every pattern is accepted and matches bytes as in Redis: '?' is one
+	// byte, so "??" matches "é", "user:[0-9" is an unclosed class, and a
+	// trailing '\' is literal.
+	if n := sub.PSubscribe("??", "user:[0-9", "a\\"); n != 3 {
+		t.Fatalf("PSubscribe() = %d, want 3", n)
+	}
+	for _, tt := range []struct {
+		channel string
+		want    int
+	}{
+		{"é", 1},
+		{"\xff\xfe", 1},
+		{"user:5", 1},
+		{"a\\", 2},
+		{"a", 0},
+	} {
+		if n := r.Publish(tt.channel, "x"); n != tt.want {
+			t.Errorf("Publish(%q) = %d, want %d", tt.channel, n, tt.want)
+		}
+	}
+	sub.Subscribe("é", "ab")
+	if got := r.Channels("?"); got != nil {
+		t.Errorf("Channels(%q) = %q, want none", "?", got)
+	}
+	if got := r.Channels("??"); !slices.Equal(got, []string{"ab", "é"}) {
+		t.Errorf("Channels(%q) = %q", "??", got)
+	}
+	sub.Close()
+	if n := sub.PSubscribe("*"); n != 0 {
+		t.Errorf("PSubscribe() after Close = %d, want 0", n)
+	}
+	if n := sub.Subscribe("ab"); n != 0 {
+		t.Errorf("Subscribe() after Close = %d, want 0", n)
+	}
+	if n := r.Publish("ab", "x"); n != 0 {
+		t.Errorf("Publish() after Close = %d, want 0", n)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	var r Router
+	a, b := r.NewSubscriber(), r.NewSubscriber()
+	a.Subscribe("news.tech", "news.art")
+	b.Subscribe("news.tech", "sport")
+	if got := r.Channels("news.*"); !slices.Equal(got, []string{"news.art", "news.tech"}) {
+		t.Errorf("Channels() = %q", got)
+	}
+	if got := r.Channels(""); len(got) != 3 {
+		t.Errorf("Channels(\"\") = %q", got)
+	}
+	if r.NumSub("news.tech") != 2 {
+		t.Errorf("NumSub() = %d", r.NumSub("news.tech"))
+	}
+	a.Close()
+	a.Close()
+	if got := r.Channels(""); !slices.Equal(got, []string{"news.tech", "sport"}) {
+		t.Errorf("Channels() after Close = %q", got)
+	}
+	if n := a.Subscribe("x"); n != 0 || r.NumSub("x") != 0 {
+		t.Error("Subscribe after Close subscribed")
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	t.Run("DropNewest", func(t *testing.T) {
+		r := Router{BufferSize: 1, Policy: DropNewest}
+		sub := r.NewSubscriber()
+		sub.Subscribe("c")
+		if n1, n2 := r.Publish("c", "1"), r.Publish("c", "2"); n1 != 1 || n2 != 0 {
+			t.Errorf("Publish() = %d, %d", n1, n2)
+		}
+		if got := receive(sub); len(got) != 1 || got[0].Payload != "1" || sub.Dropped() != 1 {
+			t.Errorf("received %v with %d dropped", got, sub.Dropped())
+		}
+	})
+	t.Run("DropOldest", func(t *testing.T) {
+		r := Router{BufferSize: 2, Policy: DropOldest}
+		sub := r.NewSubscriber()
+		sub.Subscribe("c")
+		for i := range 5 {
+			if n := r.Publish("c", strconv.Itoa(i)); n != 1 {
+				t.Errorf("Publish() = %d", n)
+			}
+		}
+		if got := receive(sub); len(got) != 2 || got[0].Payload != "3" || got[1].Payload != "4" || sub.Dropped() != 3 {
+			t.Errorf("received %v with %d dropped", got, sub.Dropped())
+		}
+	})
+	t.Run("Block", func(t *testing.T) {
+		r := Router{BufferSize: 1, Policy: Block}
+		sub := r.NewSubscriber()
+		sub.Subscribe("c")
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			for i := range 100 {
+				r.Publish("c", strconv.Itoa(i))
+			}
+		})
+		for i := range 100 {
+			if msg := <-sub.C; msg.Payload != strconv.Itoa(i) {
+				t.Fatalf("received %q, want %d", msg.Payload, i)
+			}
+		}
+		wg.Wait()
+		// Close releases a blocked publisher.
+		r.Publish("c", "fill")
+		published := make(chan int)
+		go func() { published <- r.Publish("c", "blocked") }()
+		time.Sleep(10 * time.Millisecond)
+		sub.Close()
+		if n := <-published; n != 0 {
+			t.Errorf("blocked Publish() = %d after Close", n)
+		}
+	})
+	t.Run("Disconnect", func(t *testing.T) {
+		r := Router{BufferSize: 1, Policy: Disconnect}
+		sub := r.NewSubscriber()
+		sub.Subscribe("c")
+		sub.PSubscribe("*")
+		if n := r.Publish("c", "1"); n != 1 {
+			t.Errorf("Publish() = %d, want 1", n)
+		}
+		if got := receive(sub); len(got) != 1 {
+			t.Errorf("received %v, want the first message before C closed", got)
+		}
+		if _, ok := <-sub.C; ok {
+			t.Error("C is still open")
+		}
+		if r.NumSub("c") != 0 || r.NumPat() != 0 {
+			t.Error("disconnected subscriber is still subscribed")
+		}
+	})
+}
+
+func TestConcurrent(t *testing.T) {
+	r := Router{Policy: DropOldest}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			sub := r.NewSubscriber()
+			defer sub.Close()
+			for j := range 100 {
+				sub.Subscribe("c" + strconv.Itoa(j%10))
+				sub.PSubscribe("c" + strconv.Itoa(i) + "*")
+				receive(sub)
+				if j%7 == 0 {
+					sub.PUnsubscribe()
+					sub.Unsubscribe()
+				}
+			}
+		})
+		wg.Go(func() {
+			for j := range 1000 {
+				r.Publish("c"+strconv.Itoa(j%10), "x")
+			}
+		})
+	}
+	wg.Wait()
+	if r.NumPat() != 0 || len(r.Channels("")) != 0 {
+		t.Errorf("%d patterns and channels %q left after every subscriber closed", r.NumPat(), r.Channels(""))
+	}
+}
+
+func BenchmarkPublish(b *testing.B) {
+	var r Router
+	for i := range 10000 {
+		sub := r.NewSubscriber()
+		sub.PSubscribe("tenant:" + strconv.Itoa(i) + ":*")
+	}
+	sub := r.NewSubscriber()
+	sub.PSubscribe("tenant:42:*")
+	for b.Loop() {
+		r.Publish("tenant:42:events", "x")
+		<-sub.C
+	}
+}
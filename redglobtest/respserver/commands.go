@@ -0,0 +1,390 @@
+package respserver
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/maolonglong/redglob"
+)
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// command is a command handler with its Redis arity: the exact number of
+// arguments including the name, or minus the minimum.
+type command struct {
+	arity int
+	run   func(s *Server, c *client, args []string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":     {-1, (*Server).ping},
+		"ECHO":     {2, func(s *Server, c *client, args []string) { c.w.bulk(args[1]) }},
+		"HELLO":    {-1, (*Server).hello},
+		"SELECT":   {2, (*Server).selectDB},
+		"CLIENT":   {-2, func(s *Server, c *client, args []string) { c.w.simple("OK") }},
+		"COMMAND":  {-1, func(s *Server, c *client, args []string) { c.w.array(0) }},
+		"SET":      {3, (*Server).set},
+		"GET":      {2, (*Server).get},
+		"DEL":      {-2, (*Server).del},
+		"EXISTS":   {-2, (*Server).exists},
+		"TYPE":     {2, (*Server).typeOf},
+		"DBSIZE":   {1, (*Server).dbSize},
+		"FLUSHALL": {-1, (*Server).flush},
+		"FLUSHDB":  {-1, (*Server).flush},
+		"HSET":     {-4, (*Server).hset},
+		"HGET":     {3, (*Server).hget},
+		"SADD":     {-3, (*Server).sadd},
+		"ZADD":     {-4, (*Server).zadd},
+		"KEYS":     {2, (*Server).keysCommand},
+		"SCAN":     {-2, (*Server).scanCommand},
+		"HSCAN":    {-3, (*Server).scanValue},
+		"SSCAN":    {-3, (*Server).scanValue},
+		"ZSCAN":    {-3, (*Server).scanValue},
+	}
+}
+
+// execute runs one command and reports whether the connection should be
+// closed.
+func (s *Server) execute(c *client, args []string) (quit bool) {
+	name := strings.ToUpper(args[0])
+	if name == "QUIT" {
+		c.w.simple("OK")
+		return true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.error("ERR unknown command '" + args[0] + "'")
+		return false
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		c.w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmd.run(s, c, args)
+	return false
+}
+
+func (s *Server) ping(c *client, args []string) {
+	switch len(args) {
+	case 1:
+		c.w.simple("PONG")
+	case 2:
+		c.w.bulk(args[1])
+	default:
+		c.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func (s *Server) hello(c *client, args []string) {
+	if len(args) > 1 {
+		proto, err := strconv.Atoi(args[1])
+		if err != nil || proto < 2 || proto > 3 {
+			c.w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		c.w.proto = proto
+	}
+	c.w.mapHeader(7)
+	c.w.bulk("server")
+	c.w.bulk("redis")
+	c.w.bulk("version")
+	c.w.bulk("7.4.0")
+	c.w.bulk("proto")
+	c.w.integer(c.w.proto)
+	c.w.bulk("id")
+	c.w.integer(1)
+	c.w.bulk("mode")
+	c.w.bulk("standalone")
+	c.w.bulk("role")
+	c.w.bulk("master")
+	c.w.bulk("modules")
+	c.w.array(0)
+}
+
+func (s *Server) selectDB(c *client, args []string) {
+	if args[1] != "0" {
+		c.w.error("ERR DB index is out of range")
+		return
+	}
+	c.w.simple("OK")
+}
+
+func (s *Server) set(c *client, args []string) {
+	s.put(args[1], &value{kind: kindString, str: args[2]})
+	c.w.simple("OK")
+}
+
+func (s *Server) get(c *client, args []string) {
+	v, ok := s.keys[args[1]]
+	switch {
+	case !ok:
+		c.w.null()
+	case v.kind != kindString:
+		c.w.error(errWrongType.Error())
+	default:
+		c.w.bulk(v.str)
+	}
+}
+
+func (s *Server) del(c *client, args []string) {
+	n := 0
+	for _, key := range args[1:] {
+		if s.delete(key) {
+			n++
+		}
+	}
+	c.w.integer(n)
+}
+
+func (s *Server) exists(c *client, args []string) {
+	n := 0
+	for _, key := range args[1:] {
+		if _, ok := s.keys[key]; ok {
+			n++
+		}
+	}
+	c.w.integer(n)
+}
+
+func (s *Server) typeOf(c *client, args []string) {
+	v, ok := s.keys[args[1]]
+	if !ok {
+		c.w.simple("none")
+		return
+	}
+	c.w.simple(v.kind.String())
+}
+
+func (s *Server) dbSize(c *client, args []string) {
+	c.w.integer(len(s.keys))
+}
+
+func (s *Server) flush(c *client, args []string) {
+	clear(s.keys)
+	s.sorted = nil
+	c.w.simple("OK")
+}
+
+func (s *Server) hset(c *client, args []string) {
+	if len(args)%2 != 0 {
+		c.w.error("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+	v, err := s.getOrCreate(args[1], kindHash)
+	if err != nil {
+		c.w.error(err.Error())
+		return
+	}
+	added := 0
+	for i := 2; i < len(args); i += 2 {
+		if _, ok := v.hash[args[i]]; !ok {
+			added++
+		}
+		v.hash[args[i]] = args[i+1]
+	}
+	c.w.integer(added)
+}
+
+func (s *Server) hget(c *client, args []string) {
+	v, ok := s.keys[args[1]]
+	if ok && v.kind != kindHash {
+		c.w.error(errWrongType.Error())
+		return
+	}
+	val, ok := "", false
+	if v != nil {
+		val, ok = v.hash[args[2]]
+	}
+	if !ok {
+		c.w.null()
+		return
+	}
+	c.w.bulk(val)
+}
+
+func (s *Server) sadd(c *client, args []string) {
+	v, err := s.getOrCreate(args[1], kindSet)
+	if err != nil {
+		c.w.error(err.Error())
+		return
+	}
+	added := 0
+	for _, member := range args[2:] {
+		if _, ok := v.set[member]; !ok {
+			added++
+			v.set[member] = struct{}{}
+		}
+	}
+	c.w.integer(added)
+}
+
+func (s *Server) zadd(c *client, args []string) {
+	if len(args)%2 != 0 {
+		c.w.error("ERR syntax error")
+		return
+	}
+	scores := make([]float64, 0, len(args)/2-1)
+	for i := 2; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i], 64)
+		if err != nil || math.IsNaN(score) {
+			c.w.error("ERR value is not a valid float")
+			return
+		}
+		scores = append(scores, score)
+	}
+	if v, ok := s.keys[args[1]]; ok && v.kind != kindZSet {
+		c.w.error(errWrongType.Error())
+		return
+	}
+	v, _ := s.getOrCreate(args[1], kindZSet)
+	added := 0
+	for i, score := range scores {
+		member := args[3+2*i]
+		if _, ok := v.zset[member]; !ok {
+			added++
+		}
+		v.zset[member] = score
+	}
+	c.w.integer(added)
+}
+
+func (s *Server) compile(pattern string) (*redglob.Pattern, error) {
+	p, err := s.CompileOptions.Compile(pattern)
+	if err != nil {
+		first, _, _ := strings.Cut(err.Error(), "\n")
+		return nil, errors.New("ERR invalid pattern: " + first)
+	}
+	return p, nil
+}
+
+func (s *Server) keysCommand(c *client, args []string) {
+	p, err := s.compile(args[1])
+	if err != nil {
+		c.w.error(err.Error())
+		return
+	}
+	var out []string
+	for _, key := range s.keysInRange(p) {
+		if p.Match(key) {
+			out = append(out, key)
+		}
+	}
+	c.w.bulks(out)
+}
+
+// scanArgs are the arguments of the scan commands after the key.
+type scanArgs struct {
+	cursor   uint64
+	pattern  *redglob.Pattern
+	count    int
+	typ      string
+	noValues bool
+}
+
+// parseScanArgs parses "cursor [MATCH pattern] [COUNT count]" and, if
+// allowed, "[TYPE type]" for SCAN and "[NOVALUES]" for HSCAN.
+func (s *Server) parseScanArgs(args []string, allowType, allowNoValues bool) (scanArgs, error) {
+	a := scanArgs{count: defaultScanCount}
+	var err error
+	if a.cursor, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+		return a, errors.New("ERR invalid cursor")
+	}
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "MATCH" && i+1 < len(args):
+			i++
+			if a.pattern, err = s.compile(args[i]); err != nil {
+				return a, err
+			}
+		case option == "COUNT" && i+1 < len(args):
+			i++
+			if a.count, err = strconv.Atoi(args[i]); err != nil {
+				return a, errors.New("ERR value is not an integer or out of range")
+			}
+			if a.count < 1 {
+				return a, errors.New("ERR syntax error")
+			}
+		case option == "TYPE" && allowType && i+1 < len(args):
+			i++
+			a.typ = strings.ToLower(args[i])
+		case option == "NOVALUES" && allowNoValues:
+			a.noValues = true
+		default:
+			return a, errors.New("ERR syntax error")
+		}
+	}
+	return a, nil
+}
+
+func (a *scanArgs) matches(member string) bool {
+	return a.pattern == nil || a.pattern.Match(member)
+}
+
+func (s *Server) scanCommand(c *client, args []string) {
+	a, err := s.parseScanArgs(args[1:], true, false)
+	if err != nil {
+		c.w.error(err.Error())
+		return
+	}
+	keys, next := s.scan(s.keysInRange(a.pattern), len(s.keys), a.cursor, a.count)
+	out := keys[:0]
+	for _, key := range keys {
+		if a.matches(key) && (a.typ == "" || s.keys[key].kind.String() == a.typ) {
+			out = append(out, key)
+		}
+	}
+	c.w.array(2)
+	c.w.bulk(strconv.FormatUint(next, 10))
+	c.w.bulks(out)
+}
+
+// scanValue is HSCAN, SSCAN and ZSCAN.
+func (s *Server) scanValue(c *client, args []string) {
+	name := strings.ToUpper(args[0])
+	kind := map[string]valueKind{"HSCAN": kindHash, "SSCAN": kindSet, "ZSCAN": kindZSet}[name]
+	a, err := s.parseScanArgs(args[2:], false, kind == kindHash)
+	if err != nil {
+		c.w.error(err.Error())
+		return
+	}
+	v, ok := s.keys[args[1]]
+	if ok && v.kind != kind {
+		c.w.error(errWrongType.Error())
+		return
+	}
+	var members []string
+	var next uint64
+	switch {
+	case !ok:
+	case kind == kindHash:
+		members, next = s.scan(inRange(v.hash, a.pattern), len(v.hash), a.cursor, a.count)
+	case kind == kindSet:
+		members, next = s.scan(inRange(v.set, a.pattern), len(v.set), a.cursor, a.count)
+	default:
+		members, next = s.scan(inRange(v.zset, a.pattern), len(v.zset), a.cursor, a.count)
+	}
+	var out []string
+	for _, member := range members {
+		if !a.matches(member) {
+			continue
+		}
+		out = append(out, member)
+		switch {
+		case kind == kindHash && !a.noValues:
+			out = append(out, v.hash[member])
+		case kind == kindZSet:
+			out = append(out, formatScore(v.zset[member]))
+		}
+	}
+	c.w.array(2)
+	c.w.bulk(strconv.FormatUint(next, 10))
+	c.w.bulks(out)
+}
@@ -0,0 +1,466 @@
+package respserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maolonglong/redglob"
+)
+
+// testClient is a minimal RESP client. Replies decode to string for simple
+// strings and bulk strings, int for integers, error for errors, nil for
+// nulls and []any for arrays and maps.
+type testClient struct {
+	t    testing.TB
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func startServer(t testing.TB, s *Server) *testClient {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testClient) do(args ...string) any {
+	c.t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		c.t.Fatal(err)
+	}
+	reply, err := c.read()
+	if err != nil {
+		c.t.Fatalf("%q: %v", args, err)
+	}
+	return reply
+}
+
+func (c *testClient) read() (any, error) {
+	line, err := readLine(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty line")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return errors.New(line[1:]), nil
+	case ':':
+		return strconv.Atoi(line[1:])
+	case '_':
+		return nil, nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+// scanAll runs a scan command to the end and returns the items, calling
+// between, if not nil, after each step.
+func (c *testClient) scanAll(args []string, cursorAt int, between func()) []string {
+	c.t.Helper()
+	var out []string
+	cursor := "0"
+	for {
+		args[cursorAt] = cursor
+		reply, ok := c.do(args...).([]any)
+		if !ok || len(reply) != 2 {
+			c.t.Fatalf("%q = %v", args, reply)
+		}
+		for _, item := range reply[1].([]any) {
+			out = append(out, item.(string))
+		}
+		if cursor = reply[0].(string); cursor == "0" {
+			return out
+		}
+		if between != nil {
+			between()
+		}
+	}
+}
+
+func errorText(reply any) string {
+	if err, ok := reply.(error); ok {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestCommands(t *testing.T) {
+	c := startServer(t, &Server{})
+	tests := []struct {
+		args []string
+		want any
+	}{
+		{[]string{"PING"}, "PONG"},
+		{[]string{"ping", "hi"}, "hi"},
+		{[]string{"ECHO", "x"}, "x"},
+		{[]string{"SELECT", "0"}, "OK"},
+		{[]string{"CLIENT", "SETNAME", "t"}, "OK"},
+		{[]string{"SET", "k", "v"}, "OK"},
+		{[]string{"GET", "k"}, "v"},
+		{[]string{"GET", "missing"}, nil},
+		{[]string{"HSET", "h", "f1", "a", "f2", "b"}, 2},
+		{[]string{"HSET", "h", "f1", "c"}, 0},
+		{[]string{"HGET", "h", "f1"}, "c"},
+		{[]string{"HGET", "h", "nope"}, nil},
+		{[]string{"SADD", "s", "x", "y", "x"}, 2},
+		{[]string{"ZADD", "z", "1.5", "m", "inf", "n"}, 2},
+		{[]string{"EXISTS", "k", "h", "missing"}, 2},
+		{[]string{"TYPE", "z"}, "zset"},
+		{[]string{"TYPE", "missing"}, "none"},
+		{[]string{"DBSIZE"}, 4},
+		{[]string{"DEL", "k", "missing"}, 1},
+		{[]string{"DBSIZE"}, 3},
+		{[]string{"FLUSHDB"}, "OK"},
+		{[]string{"DBSIZE"}, 0},
+	}
+	for _, tt := range tests {
+		if got := c.do(tt.args...); got != tt.want {
+			t.Errorf("%q = %#v, want %#v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := startServer(t, &Server{})
+	c.do("SET", "str", "v")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"NOPE"}, "ERR unknown command 'NOPE'"},
+		{[]string{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"HSET", "h", "f"}, "ERR wrong number of arguments for 'hset' command"},
+		{[]string{"SELECT", "1"}, "ERR DB index is out of range"},
+		{[]string{"HELLO", "4"}, "NOPROTO unsupported protocol version"},
+		{[]string{"ZADD", "z", "x", "m"}, "ERR value is not a valid float"},
+		{[]string{"SADD", "str", "m"}, errWrongType.Error()},
+		{[]string{"HSCAN", "str", "0"}, errWrongType.Error()},
+		{[]string{"SCAN", "x"}, "ERR invalid cursor"},
+		{[]string{"SCAN", "0", "COUNT", "x"}, "ERR value is not an integer or out of range"},
+		{[]string{"SCAN", "0", "COUNT", "0"}, "ERR syntax error"},
+		{[]string{"SCAN", "0", "NOVALUES"}, "ERR syntax error"},
+		{[]string{"SSCAN", "s", "0", "TYPE", "set"}, "ERR syntax error"},
+		{[]string{"SCAN", "0", "MATCH"}, "ERR syntax error"},
+	}
+	for _, tt := range tests {
+		if got := errorText(c.do(tt.args...)); got != tt.want {
+			t.Errorf("%q = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if got := errorText(c.do("KEYS", "a[")); !strings.HasPrefix(got, "ERR invalid pattern") {
+		t.Errorf("KEYS with an invalid pattern = %q", got)
+	}
+}
+
+func TestRESP3(t *testing.T) {
+	c := startServer(t, &Server{})
+	if reply, ok := c.do("HELLO").([]any); !ok || len(reply) != 14 || reply[5] != 2 {
+		t.Errorf("HELLO = %v, want a flat RESP2 map", reply)
+	}
+	if got := c.do("GET", "missing"); got != nil {
+		t.Errorf("GET in RESP2 = %v", got)
+	}
+	reply, ok := c.do("HELLO", "3").([]any)
+	if !ok || len(reply) != 14 || reply[4] != "proto" || reply[5] != 3 {
+		t.Errorf("HELLO 3 = %v", reply)
+	}
+	if got := c.do("GET", "missing"); got != nil {
+		t.Errorf("GET in RESP3 = %v", got)
+	}
+}
+
+func TestRESP3Null(t *testing.T) {
+	s := &Server{}
+	c := startServer(t, s)
+	c.do("HELLO", "3")
+	c.conn.Write([]byte("*2\r\n$3\r\nGET\r\n$1\r\nx\r\n"))
+	if line, err := readLine(c.r); err != nil || line != "_" {
+		t.Errorf("GET in RESP3 sent %q, %v, want _", line, err)
+	}
+}
+
+func TestInlineCommand(t *testing.T) {
+	c := startServer(t, &Server{})
+	c.conn.Write([]byte("SET a b\r\nGET a\r\n"))
+	for _, want := range []any{"OK", "b"} {
+		if got, err := c.read(); err != nil || got != want {
+			t.Errorf("inline reply = %v, %v, want %v", got, err, want)
+		}
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	c := startServer(t, &Server{})
+	c.conn.Write([]byte("*1\r\n+PING\r\n"))
+	if got := errorText(mustRead(t, c)); !strings.HasPrefix(got, "ERR Protocol error") {
+		t.Errorf("reply = %q", got)
+	}
+	if _, err := c.read(); err == nil {
+		t.Error("connection still open after a protocol error")
+	}
+}
+
+func mustRead(t *testing.T, c *testClient) any {
+	t.Helper()
+	reply, err := c.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestKeys(t *testing.T) {
+	s := &Server{}
+	c := startServer(t, s)
+	keys := []string{"user:1", "user:2", "user:10", "users", "order:1", "a", "user:\xff"}
+	for _, key := range keys {
+		s.Set(key, "v")
+	}
+	for _, pattern := range []string{"*", "user:?", "user:*", "user*", "[ou]*:1", "nope*", "a", "*1"} {
+		reply := c.do("KEYS", pattern).([]any)
+		var got []string
+		for _, key := range reply {
+			got = append(got, key.(string))
+		}
+		var want []string
+		for _, key := range keys {
+			if redglob.Match(key, pattern) {
+				want = append(want, key)
+			}
+		}
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("KEYS %q = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestKeysCompileOptions(t *testing.T) {
+	s := &Server{CompileOptions: redglob.CompileOptions{Braces: true}}
+	c := startServer(t, s)
+	for _, key := range []string{"log:warn", "log:error", "log:info"} {
+		s.Set(key, "v")
+	}
+	if got := c.do("KEYS", "log:{warn,error}"); !slices.Equal(got.([]any), []any{"log:error", "log:warn"}) {
+		t.Errorf("KEYS with braces = %v", got)
+	}
+}
+
+func TestScan(t *testing.T) {
+	s := &Server{}
+	c := startServer(t, s)
+	var want []string
+	for i := range 1000 {
+		key := fmt.Sprintf("key:%d", i)
+		s.Set(key, "v")
+		want = append(want, key)
+	}
+	s.HSet("hash", "f", "v")
+	got := c.scanAll([]string{"SCAN", "", "COUNT", "7", "TYPE", "string"}, 1, nil)
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("SCAN TYPE string returned %d keys, want %d", len(got), len(want))
+	}
+	got = c.scanAll([]string{"SCAN", "", "MATCH", "key:1?"}, 1, nil)
+	slices.Sort(got)
+	if want := []string{"key:10", "key:11", "key:12", "key:13", "key:14", "key:15", "key:16", "key:17", "key:18", "key:19"}; !slices.Equal(got, want) {
+		t.Errorf("SCAN MATCH key:1? = %q", got)
+	}
+	if got := c.scanAll([]string{"SCAN", "", "TYPE", "hash"}, 1, nil); !slices.Equal(got, []string{"hash"}) {
+		t.Errorf("SCAN TYPE hash = %q", got)
+	}
+}
+
+// TestScanCount checks that a step returns about COUNT keys, like Redis.
+func TestScanCount(t *testing.T) {
+	s := &Server{}
+	c := startServer(t, s)
+	for i := range 1000 {
+		s.Set(strconv.Itoa(i), "v")
+	}
+	reply := c.do("SCAN", "0", "COUNT", "100").([]any)
+	if n := len(reply[1].([]any)); n < 100 || n > 150 || reply[0] == "0" {
+		t.Errorf("SCAN COUNT 100 returned %d keys and cursor %v", n, reply[0])
+	}
+	reply = c.do("SCAN", "0", "COUNT", "5000").([]any)
+	if n := len(reply[1].([]any)); n != 1000 || reply[0] != "0" {
+		t.Errorf("SCAN COUNT 5000 returned %d keys and cursor %v", n, reply[0])
+	}
+}
+
+// TestScanGuarantee checks Redis's guarantee while the key set grows and
+// shrinks across table sizes: every key present for the whole scan is
+// returned exactly once, and no key is returned that never existed.
+func TestScanGuarantee(t *testing.T) {
+	s := &Server{}
+	c := startServer(t, s)
+	stable := make(map[string]bool)
+	for i := range 300 {
+		key := fmt.Sprintf("stable:%d", i)
+		s.Set(key, "v")
+		stable[key] = true
+	}
+	for i := range 200 {
+		s.Set(fmt.Sprintf("gone:%d", i), "v")
+	}
+	for _, match := range []string{"*", "stable:*"} {
+		step := 0
+		got := c.scanAll([]string{"SCAN", "", "COUNT", "5", "MATCH", match}, 1, func() {
+			step++
+			switch {
+			case step < 20:
+				// Grow the table past several powers of two.
+				for i := range 100 {
+					s.Set(fmt.Sprintf("new:%d:%d", step, i), "v")
+				}
+			case step < 40:
+				// Then shrink it below the starting size.
+				c.do("FLUSHDB")
+				for key := range stable {
+					s.Set(key, "v")
+				}
+			}
+		})
+		seen := make(map[string]int)
+		for _, key := range got {
+			seen[key]++
+			if !strings.HasPrefix(key, "stable:") && !strings.HasPrefix(key, "gone:") && !strings.HasPrefix(key, "new:") {
+				t.Fatalf("SCAN returned unknown key %q", key)
+			}
+		}
+		for key := range stable {
+			if seen[key] != 1 {
+				t.Errorf("MATCH %q: key %q returned %d times", match, key, seen[key])
+			}
+		}
+		c.do("FLUSHDB")
+		for key := range stable {
+			s.Set(key, "v")
+		}
+	}
+}
+
+// TestScanCursorOrder checks that with one key per bucket of a table of
+// four, the cursors follow Redis's reverse-binary order 0, 2, 1, 3.
+func TestScanCursorOrder(t *testing.T) {
+	s := &Server{}
+	byBucket := make(map[uint64]string)
+	for i := 0; len(byBucket) < 4; i++ {
+		key := strconv.Itoa(i)
+		s.Set(key, "v")
+		bucket := maphash.String(s.seed, key) & 3
+		if _, ok := byBucket[bucket]; !ok {
+			byBucket[bucket] = key
+		}
+	}
+	candidates := slices.Collect(maps.Values(byBucket))
+	var cursors []uint64
+	var cursor uint64
+	for {
+		keys, next := s.scan(candidates, 4, cursor, 1)
+		if len(keys) != 1 || keys[0] != byBucket[cursor] {
+			t.Fatalf("scan from %d = %q, want %q", cursor, keys, byBucket[cursor])
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+		cursors = append(cursors, cursor)
+	}
+	if want := []uint64{2, 1, 3}; !slices.Equal(cursors, want) {
+		t.Errorf("cursors = %v, want %v", cursors, want)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := &Server{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.do("SET", "k", "v")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("Serve() = %v, want ErrServerClosed", err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.read(); err == nil {
+		t.Error("connection still open after Close")
+	}
+	if err := s.Serve(l); err != ErrServerClosed {
+		t.Errorf("Serve() after Close = %v", err)
+	}
+	if got := s.Keys(); !slices.Equal(got, []string{"k"}) {
+		t.Errorf("Keys() after Close = %q", got)
+	}
+}
+
+func BenchmarkScanMatch(b *testing.B) {
+	s := &Server{}
+	for i := range 10000 {
+		s.Set(fmt.Sprintf("user:%d", i), "v")
+		s.Set(fmt.Sprintf("order:%d", i), "v")
+	}
+	p, _ := s.compile("user:1*")
+	for b.Loop() {
+		s.scan(s.keysInRange(p), len(s.keys), 0, 10)
+	}
+}
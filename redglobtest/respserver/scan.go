@@ -0,0 +1,85 @@
+package respserver
+
+import (
+	"cmp"
+	"hash/maphash"
+	"math/bits"
+	"slices"
+
+	"github.com/maolonglong/redglob"
+)
+
+// defaultScanCount is the COUNT of a scan without one, as in Redis.
+const defaultScanCount = 10
+
+// scan returns the candidates from cursor on, in cursor order, until at
+// least count have been returned and a bucket is complete, together with
+// the cursor to continue from, or 0 at the end.
+//
+// Like Redis, it walks the buckets of a hash table of size elements, a
+// power of two of at least four, in the order of their bit-reversed
+// indexes. The cursor is the bit-reversed index of the next bucket. Since
+// the order is that of the reversed hash bits, which a resize only splits
+// or merges into finer or coarser buckets, an element present for the
+// whole scan is returned exactly once even if the table grows or shrinks
+// between calls. candidates may leave out elements that cannot match, as
+// long as it is a subset of the size elements.
+func (s *Server) scan(candidates []string, size int, cursor uint64, count int) ([]string, uint64) {
+	width := max(2, bits.Len(uint(max(size, 1)-1)))
+	position := bits.Reverse64(cursor)
+	type item struct {
+		rev uint64
+		key string
+	}
+	items := make([]item, 0, len(candidates))
+	for _, candidate := range candidates {
+		if rev := bits.Reverse64(maphash.String(s.seed, candidate)); rev >= position {
+			items = append(items, item{rev, candidate})
+		}
+	}
+	slices.SortFunc(items, func(a, b item) int { return cmp.Compare(a.rev, b.rev) })
+	var out []string
+	for i, it := range items {
+		bucket := it.rev >> (64 - width)
+		if len(out) >= count && bucket != items[i-1].rev>>(64-width) {
+			return out, bits.Reverse64(bucket << (64 - width))
+		}
+		out = append(out, it.key)
+	}
+	return out, 0
+}
+
+// keysInRange returns the sorted keys that can match p: those in
+// p.KeyRange, found by binary search, or all of them.
+func (s *Server) keysInRange(p *redglob.Pattern) []string {
+	keys := s.sortedKeys()
+	if p == nil {
+		return keys
+	}
+	lo, hi, ok := p.KeyRange()
+	if !ok {
+		return keys
+	}
+	start, _ := slices.BinarySearch(keys, lo)
+	end := len(keys)
+	if hi != "" {
+		end, _ = slices.BinarySearch(keys, hi)
+	}
+	return keys[start:max(start, end)]
+}
+
+// inRange returns the members that can match p, keeping those in
+// p.KeyRange.
+func inRange[V any](members map[string]V, p *redglob.Pattern) []string {
+	lo, hi, ok := "", "", false
+	if p != nil {
+		lo, hi, ok = p.KeyRange()
+	}
+	out := make([]string, 0, len(members))
+	for member := range members {
+		if !ok || member >= lo && (hi == "" || member < hi) {
+			out = append(out, member)
+		}
+	}
+	return out
+}
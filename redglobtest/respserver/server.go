@@ -0,0 +1,279 @@
+// Package respserver is a small in-memory Redis stand-in for integration
+// tests whose KEYS and SCAN MATCH filtering is exactly redglob's.
+//
+// It speaks RESP2 and, after HELLO 3, RESP3 over any net.Listener, and
+// stores strings, hashes, sets and sorted sets:
+//
+//	srv := &respserver.Server{}
+//	l, _ := net.Listen("tcp", "127.0.0.1:0")
+//	go srv.Serve(l)
+//	defer srv.Close()
+//	srv.Set("user:1", "alice")
+//	// point a Redis client at l.Addr()
+//
+// The commands are PING, ECHO, HELLO, SELECT, CLIENT, COMMAND, QUIT, SET,
+// GET, DEL, EXISTS, TYPE, DBSIZE, FLUSHALL, FLUSHDB, HSET, HGET, SADD,
+// ZADD, KEYS, SCAN, HSCAN, SSCAN and ZSCAN, with the arguments Redis
+// accepts for the scan family. Cursors follow Redis's reverse-binary
+// iteration: an element present for a whole scan is returned, and only
+// once, however the data set changes in between.
+package respserver
+
+import (
+	"bufio"
+	"errors"
+	"hash/maphash"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/maolonglong/redglob"
+)
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("respserver: server closed")
+
+// Server is an in-memory Redis stand-in with a single database. The zero
+// value is an empty server ready to use; CompileOptions must not be
+// changed after the first command. A Server is safe for concurrent use.
+type Server struct {
+	// CompileOptions compiles the patterns of KEYS and MATCH. A pattern
+	// that does not compile is answered with an error.
+	CompileOptions redglob.CompileOptions
+
+	mu     sync.Mutex
+	seed   maphash.Seed
+	keys   map[string]*value
+	sorted []string // keys in byte order, or nil after a change
+	closed bool
+
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+}
+
+type valueKind uint8
+
+const (
+	kindString valueKind = iota
+	kindHash
+	kindSet
+	kindZSet
+)
+
+func (k valueKind) String() string {
+	return [...]string{"string", "hash", "set", "zset"}[k]
+}
+
+type value struct {
+	kind valueKind
+	str  string
+	hash map[string]string
+	set  map[string]struct{}
+	zset map[string]float64
+}
+
+// Serve accepts connections on l and serves each in its own goroutine
+// until l fails or the server is closed. It always returns an error,
+// ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes the listeners and connections. The data is kept, so the
+// in-process helpers keep working.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		err = errors.Join(err, l.Close())
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	c := &client{
+		r: bufio.NewReader(conn),
+		w: &writer{w: bufio.NewWriter(conn), proto: 2},
+	}
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			var protoErr protocolError
+			if errors.As(err, &protoErr) {
+				c.w.error("ERR Protocol error: " + string(protoErr))
+				c.w.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(c, args)
+		if err := c.w.w.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// client is the state of one connection.
+type client struct {
+	r *bufio.Reader
+	w *writer
+}
+
+// Set stores a string value under key, like SET.
+func (s *Server) Set(key, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(key, &value{kind: kindString, str: val})
+}
+
+// HSet sets field of the hash at key, like HSET. It panics if key holds
+// another type.
+func (s *Server) HSet(key, field, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mustGet(key, kindHash).hash[field] = val
+}
+
+// SAdd adds members to the set at key, like SADD. It panics if key holds
+// another type.
+func (s *Server) SAdd(key string, members ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.mustGet(key, kindSet)
+	for _, member := range members {
+		v.set[member] = struct{}{}
+	}
+}
+
+// ZAdd adds member with score to the sorted set at key, like ZADD. It
+// panics if key holds another type.
+func (s *Server) ZAdd(key string, score float64, member string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mustGet(key, kindZSet).zset[member] = score
+}
+
+// Keys returns the keys in byte order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.sortedKeys())
+}
+
+func (s *Server) mustGet(key string, kind valueKind) *value {
+	v, err := s.getOrCreate(key, kind)
+	if err != nil {
+		panic("respserver: " + key + " holds a " + s.keys[key].kind.String())
+	}
+	return v
+}
+
+// getOrCreate returns the value at key, creating an empty one of kind if
+// there is none. It fails if key holds another kind.
+func (s *Server) getOrCreate(key string, kind valueKind) (*value, error) {
+	if v, ok := s.keys[key]; ok {
+		if v.kind != kind {
+			return nil, errWrongType
+		}
+		return v, nil
+	}
+	v := &value{kind: kind}
+	switch kind {
+	case kindHash:
+		v.hash = make(map[string]string)
+	case kindSet:
+		v.set = make(map[string]struct{})
+	case kindZSet:
+		v.zset = make(map[string]float64)
+	}
+	s.put(key, v)
+	return v, nil
+}
+
+func (s *Server) put(key string, v *value) {
+	if s.keys == nil {
+		s.keys = make(map[string]*value)
+		s.seed = maphash.MakeSeed()
+	}
+	if _, ok := s.keys[key]; !ok {
+		s.sorted = nil
+	}
+	s.keys[key] = v
+}
+
+func (s *Server) delete(key string) bool {
+	if _, ok := s.keys[key]; !ok {
+		return false
+	}
+	delete(s.keys, key)
+	s.sorted = nil
+	return true
+}
+
+func (s *Server) sortedKeys() []string {
+	if s.sorted == nil {
+		s.sorted = make([]string, 0, len(s.keys))
+		for key := range s.keys {
+			s.sorted = append(s.sorted, key)
+		}
+		slices.Sort(s.sorted)
+	}
+	return s.sorted
+}
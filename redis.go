@@ -0,0 +1,366 @@
+package redglob
+
+// MatchRedis reports whether str matches pattern exactly as Redis 7's
+// stringmatchlen does for KEYS, SCAN MATCH, PSUBSCRIBE and ACL patterns.
+//
+// It differs from Match in the places Redis does:
+//   - The pattern and input are bytes: '?' and classes consume one byte, not
+//     one UTF-8 rune, and ranges compare signed C chars as on x86-64 Linux,
+//     so bytes >= 0x80 sort before NUL.
+//   - A '[' without a closing ']' is a class running to the end of the
+//     pattern, and a trailing '\\' matches a literal backslash.
+//   - An empty str matches only the empty pattern, so "*" does not match "".
+//   - Patterns nesting more than 1000 '*' groups deep never match.
+func MatchRedis(str, pattern string) bool {
+	return stringmatchRedis(str, pattern, false)
+}
+
+// MatchRedisFold is MatchRedis with Redis's nocase flag. Redis folds with
+// C tolower in the "C" locale, so only ASCII letters are folded; escaped
+// characters inside a class are still compared case-sensitively.
+func MatchRedisFold(str, pattern string) bool {
+	return stringmatchRedis(str, pattern, true)
+}
+
+// MatchBytesRedis is like MatchRedis, but it receives a byte slice.
+func MatchBytesRedis(b []byte, pattern string) bool {
+	return stringmatchRedis(b2s(b), pattern, false)
+}
+
+// MatchBytesRedisFold is like MatchRedisFold, but it receives a byte slice.
+func MatchBytesRedisFold(b []byte, pattern string) bool {
+	return stringmatchRedis(b2s(b), pattern, true)
+}
+
+// redisMaxNesting mirrors the recursion limit stringmatchlen_impl uses to
+// defend against abusive patterns.
+const redisMaxNesting = 1000
+
+func stringmatchRedis(str, pattern string, nocase bool) bool {
+	skipLongerMatches := false
+	return stringmatchRedisImpl(str, pattern, nocase, &skipLongerMatches, 0)
+}
+
+// stringmatchRedisImpl is a line-by-line port of stringmatchlen_impl from
+// Redis's util.c, with the class loop moved to redisClass. Where the C code
+// reads the NUL terminator past the end of the pattern, the port checks the
+// length instead.
+//
+//gocyclo:ignore
+func stringmatchRedisImpl(str, pattern string, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	if nesting > redisMaxNesting {
+		return false
+	}
+	for len(pattern) > 0 && len(str) > 0 {
+		advance := 1
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for len(str) > 0 {
+				if stringmatchRedisImpl(str, pattern[1:], nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+				str = str[1:]
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			str = str[1:]
+		case '[':
+			match, rest := redisClass(pattern[1:], str[0], nocase)
+			if !match {
+				return false
+			}
+			str = str[1:]
+			pattern, advance = rest, 0
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if !nocase {
+				if pattern[0] != str[0] {
+					return false
+				}
+			} else if cToLower(cChar(pattern[0])) != cToLower(cChar(str[0])) {
+				return false
+			}
+			str = str[1:]
+		}
+		pattern = pattern[advance:]
+		if len(str) == 0 {
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			break
+		}
+	}
+	return len(pattern) == 0 && len(str) == 0
+}
+
+// redisClass is the class loop of stringmatchlen_impl. pattern starts after
+// the '['; redisClass reports whether c is in the class and returns the
+// pattern after its ']', or "" if the class runs to the end of the pattern.
+// Where the class ends does not depend on c.
+func redisClass(pattern string, c byte, nocase bool) (bool, string) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	match := false
+	for {
+		if len(pattern) >= 2 && pattern[0] == '\\' {
+			pattern = pattern[1:]
+			if pattern[0] == c {
+				match = true
+			}
+		} else if len(pattern) > 0 && pattern[0] == ']' {
+			pattern = pattern[1:]
+			break
+		} else if len(pattern) == 0 {
+			break
+		} else if len(pattern) >= 3 && pattern[1] == '-' {
+			start, end, char := cChar(pattern[0]), cChar(pattern[2]), cChar(c)
+			if start > end {
+				start, end = end, start
+			}
+			if nocase {
+				start, end, char = cToLower(start), cToLower(end), cToLower(char)
+			}
+			pattern = pattern[2:]
+			if char >= start && char <= end {
+				match = true
+			}
+		} else if !nocase {
+			if pattern[0] == c {
+				match = true
+			}
+		} else if cToLower(cChar(pattern[0])) == cToLower(cChar(c)) {
+			match = true
+		}
+		pattern = pattern[1:]
+	}
+	return match != not, pattern
+}
+
+// cChar converts b the way a signed C char is promoted to int.
+func cChar(b byte) int {
+	return int(int8(b))
+}
+
+// cToLower is glibc's tolower in the "C" locale, which Redis never changes.
+// glibc maps a negative signed char other than EOF (-1) to its unsigned
+// value, which matters when a range bound or input byte is >= 0x80.
+func cToLower(c int) int {
+	switch {
+	case c >= 'A' && c <= 'Z':
+		return c + ('a' - 'A')
+	case c < -1:
+		return c + 256
+	default:
+		return c
+	}
+}
+
+// redisToken is an element of a ModeRedisBytes pattern, for the operations
+// that need more than stringmatchRedis's yes or no. kind is tokenLiteralRun,
+// tokenAnyN, tokenStar or tokenClass, and '?' and classes consume one byte.
+type redisToken struct {
+	kind    tokenKind
+	lit     string
+	count   int
+	class   *redisClassSet
+	negated bool // the class starts with '^'
+}
+
+// redisClassSet holds the bytes a class matches, exactly and with nocase.
+type redisClassSet [2]byteSet
+
+type byteSet [4]uint64
+
+func (s *byteSet) add(b byte) {
+	s[b>>6] |= 1 << (b & 63)
+}
+
+func (s *byteSet) contains(b byte) bool {
+	return s[b>>6]&(1<<(b&63)) != 0
+}
+
+// compileRedis splits a ModeRedisBytes pattern into tokens, reading it as
+// stringmatchlen_impl does. Apart from two quirks, Redis matches the tokens
+// like an ordinary glob over bytes: an empty string matches only the empty
+// pattern, and a pattern with more than redisMaxNesting stars before its
+// last token never matches, which compileRedis reports as deep.
+func compileRedis(pattern string) (tokens []redisToken, deep bool) {
+	var lit []byte
+	flush := func() {
+		if len(lit) > 0 {
+			tokens = append(tokens, redisToken{kind: tokenLiteralRun, lit: string(lit)})
+			lit = lit[:0]
+		}
+	}
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			flush()
+			if n := len(tokens); n == 0 || tokens[n-1].kind != tokenStar {
+				tokens = append(tokens, redisToken{kind: tokenStar})
+			}
+			pattern = pattern[1:]
+		case '?':
+			flush()
+			if n := len(tokens); n > 0 && tokens[n-1].kind == tokenAnyN {
+				tokens[n-1].count++
+			} else {
+				tokens = append(tokens, redisToken{kind: tokenAnyN, count: 1})
+			}
+			pattern = pattern[1:]
+		case '[':
+			flush()
+			set := &redisClassSet{}
+			rest := ""
+			for c := range 256 {
+				var match bool
+				if match, rest = redisClass(pattern[1:], byte(c), false); match {
+					set[0].add(byte(c))
+				}
+				if match, _ = redisClass(pattern[1:], byte(c), true); match {
+					set[1].add(byte(c))
+				}
+			}
+			negated := len(pattern) > 1 && pattern[1] == '^'
+			tokens = append(tokens, redisToken{kind: tokenClass, class: set, negated: negated})
+			pattern = rest
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			lit = append(lit, pattern[0])
+			pattern = pattern[1:]
+		}
+	}
+	flush()
+	stars := 0
+	for i := range tokens {
+		if tokens[i].kind == tokenStar && i < len(tokens)-1 {
+			stars++
+		}
+	}
+	return tokens, stars > redisMaxNesting
+}
+
+// matchesByte reports whether the '?' or class t matches c.
+func (t *redisToken) matchesByte(c byte, fold bool) bool {
+	if t.kind != tokenClass {
+		return true
+	}
+	if fold {
+		return t.class[1].contains(c)
+	}
+	return t.class[0].contains(c)
+}
+
+// hasRedisPrefix reports whether str starts with lit, folding like
+// MatchRedisFold: only ASCII letters.
+func hasRedisPrefix(str, lit string, fold bool) bool {
+	if len(str) < len(lit) {
+		return false
+	}
+	if fold {
+		return equalASCIIFold(str[:len(lit)], lit)
+	}
+	return str[:len(lit)] == lit
+}
+
+// redisSubmatchIndex is SubmatchIndex for a ModeRedisBytes pattern: the
+// walk of submatchIndex over p.redis, a byte at a time.
+//
+//gocyclo:ignore
+func (p *Pattern) redisSubmatchIndex(str string, fold bool) ([]int, bool) {
+	tokens := p.redis
+	if p.redisDeep || str == "" && len(tokens) > 0 {
+		return nil, false
+	}
+	groups := make([]int, len(tokens))
+	count := 0
+	for i := range tokens {
+		groups[i] = -1
+		if tokens[i].kind != tokenLiteralRun {
+			groups[i] = count
+			count++
+		}
+	}
+	spans := make([]int, 2*count)
+	record := func(tokenIndex, start, end int) {
+		if group := groups[tokenIndex]; group >= 0 {
+			spans[2*group], spans[2*group+1] = start, end
+		}
+	}
+
+	tokenIndex, stringIndex := 0, 0
+	starToken, starString := -1, 0
+	for stringIndex < len(str) || tokenIndex < len(tokens) {
+		if tokenIndex < len(tokens) {
+			tok := &tokens[tokenIndex]
+			switch tok.kind {
+			case tokenStar:
+				if tokenIndex == len(tokens)-1 {
+					record(tokenIndex, stringIndex, len(str))
+					return spans, true
+				}
+				starToken, starString = tokenIndex, stringIndex
+				record(tokenIndex, stringIndex, stringIndex)
+				tokenIndex++
+				continue
+			case tokenLiteralRun:
+				if hasRedisPrefix(str[stringIndex:], tok.lit, fold) {
+					tokenIndex++
+					stringIndex += len(tok.lit)
+					continue
+				}
+			case tokenAnyN:
+				if stringIndex+tok.count <= len(str) {
+					record(tokenIndex, stringIndex, stringIndex+tok.count)
+					tokenIndex++
+					stringIndex += tok.count
+					continue
+				}
+			default:
+				if stringIndex < len(str) && tok.matchesByte(str[stringIndex], fold) {
+					record(tokenIndex, stringIndex, stringIndex+1)
+					tokenIndex++
+					stringIndex++
+					continue
+				}
+			}
+		} else if stringIndex >= len(str) {
+			break
+		}
+		if starToken < 0 || starString >= len(str) {
+			return nil, false
+		}
+		starString++
+		record(starToken, spans[2*groups[starToken]], starString)
+		stringIndex = starString
+		tokenIndex = starToken + 1
+	}
+	for tokenIndex < len(tokens) && tokens[tokenIndex].kind == tokenStar {
+		record(tokenIndex, stringIndex, stringIndex)
+		tokenIndex++
+	}
+	if tokenIndex != len(tokens) || stringIndex != len(str) {
+		return nil, false
+	}
+	return spans, true
+}
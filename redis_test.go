@@ -0,0 +1,193 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// redisTests were checked against stringmatchlen from Redis 7's util.c,
+// built with gcc on x86-64 Linux (signed char, glibc tolower). The first
+// group follows tests/unit/keyspace.tcl; the rest pin down byte, class and
+// escape edge cases where Redis differs from Match.
+var redisTests = []struct {
+	str, pattern string
+	want, nocase bool
+}{
+	{"foo_a", "foo*", true, true},
+	{"key_x", "foo*", false, false},
+	{"foo_a", "*", true, true},
+	{"", "*", false, false},
+	{"", "", true, true},
+	{"", "?", false, false},
+	{"a", "", false, false},
+	{"hello", "h?llo", true, true},
+	{"hallo", "h[ae]llo", true, true},
+	{"hillo", "h[^e]llo", true, true},
+	{"hbllo", "h[a-b]llo", true, true},
+	{strings.Repeat("a", 120), "a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*a*b", false, false},
+	{"a", "[abc", true, true},
+	{"d", "[abc", false, false},
+	{"ab", "[abc", false, false},
+	{"a", "[^abc", false, false},
+	{"x", "[", false, false},
+	{"[", "[", false, false},
+	{"x", "[^", true, true},
+	{"\\", "\\", true, true},
+	{"a\\", "a\\", true, true},
+	{"a", "a\\", false, false},
+	{"a\\", "a\\\\", true, true},
+	{"é", "?", false, false},
+	{"é", "??", true, true},
+	{"é", "[é]", false, false},
+	{"\xc3", "[é]", true, true},
+	{"\xa9", "[^é]?", false, false},
+	{"\x80", "[\x00-a]", false, false},
+	{"\x80", "[a-\xff]", false, false},
+	{"b", "[a-\xff]", false, false},
+	{"\x00", "[\x80-\x01]", true, false},
+	{"B", "[a-c]", false, true},
+	{"b", "[Z-a]", false, false},
+	{"A", "[\\a]", false, false},
+	{"a", "[\\a]", true, true},
+	{"A", "\\a", false, true},
+	{"A", "a", false, true},
+	{"]", "[]]", false, false},
+	{"]", "[]", false, false},
+	{"x]", "[]", false, false},
+	{"-", "[a-]", false, false},
+	{"]", "[a-]", true, true},
+	{"b", "[a-]", false, false},
+	{"a", "a*", true, true},
+	{"ab", "a**", true, true},
+	{"a", "*a*", true, true},
+	{"a", "**", true, true},
+	{"abc", "a*c", true, true},
+	{"ac", "a*?c", false, false},
+	{"axc", "a*?c", true, true},
+	{"K", "k", false, true},
+	{"K", "k", false, false},
+	{"É", "é", false, false},
+}
+
+func TestMatchRedis(t *testing.T) {
+	for _, tt := range redisTests {
+		if got := MatchRedis(tt.str, tt.pattern); got != tt.want {
+			t.Errorf("MatchRedis(%q, %q) = %v, want %v", tt.str, tt.pattern, got, tt.want)
+		}
+		if got := MatchRedisFold(tt.str, tt.pattern); got != tt.nocase {
+			t.Errorf("MatchRedisFold(%q, %q) = %v, want %v", tt.str, tt.pattern, got, tt.nocase)
+		}
+		if got := MatchBytesRedis([]byte(tt.str), tt.pattern); got != tt.want {
+			t.Errorf("MatchBytesRedis(%q, %q) = %v, want %v", tt.str, tt.pattern, got, tt.want)
+		}
+		if got := MatchBytesRedisFold([]byte(tt.str), tt.pattern); got != tt.nocase {
+			t.Errorf("MatchBytesRedisFold(%q, %q) = %v, want %v", tt.str, tt.pattern, got, tt.nocase)
+		}
+		p, err := CompileOptions{Mode: ModeRedisBytes}.Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q, ModeRedisBytes) error = %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.str); got != tt.want {
+			t.Errorf("Compile(%q, ModeRedisBytes).Match(%q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+		if got := p.MatchFold(tt.str); got != tt.nocase {
+			t.Errorf("Compile(%q, ModeRedisBytes).MatchFold(%q) = %v, want %v", tt.pattern, tt.str, got, tt.nocase)
+		}
+		if _, ok := p.Submatch(tt.str); ok != tt.want {
+			t.Errorf("Compile(%q, ModeRedisBytes).Submatch(%q) ok = %v, want %v", tt.pattern, tt.str, ok, tt.want)
+		}
+		if _, ok := p.SubmatchFold(tt.str); ok != tt.nocase {
+			t.Errorf("Compile(%q, ModeRedisBytes).SubmatchFold(%q) ok = %v, want %v", tt.pattern, tt.str, ok, tt.nocase)
+		}
+	}
+}
+
+func TestMatchRedisNesting(t *testing.T) {
+	str := strings.Repeat("a", 1200)
+	if !MatchRedis(str, strings.Repeat("a*", 999)) {
+		t.Error("MatchRedis rejected a pattern within the nesting limit")
+	}
+	if MatchRedis(str, strings.Repeat("a*", 1002)) {
+		t.Error("MatchRedis accepted a pattern beyond the nesting limit")
+	}
+	// Regression for pattern matching very long nested loops (keyspace.tcl).
+	if MatchRedis(strings.Repeat("a", 100), strings.Repeat("*?", 50000)) {
+		t.Error(`MatchRedis("a"*100, "*?"*50000) = true, want false`)
+	}
+}
+
+func TestMatchRedisAgreesOnASCII(t *testing.T) {
+	// Without classes, escapes or empty input the two modes only differ on
+	// multi-byte runes.
+	for _, tt := range allMatchCases() {
+		if tt.args.str == "" || !isASCII(tt.args.str) || !isASCII(tt.args.pattern) ||
+			strings.ContainsAny(tt.args.pattern, `[\`) {
+			continue
+		}
+		if got := MatchRedis(tt.args.str, tt.args.pattern); got != tt.want {
+			t.Errorf("MatchRedis(%q, %q) = %v, want %v", tt.args.str, tt.args.pattern, got, tt.want)
+		}
+	}
+}
+
+func compileRedisMode(t testing.TB, pattern string) *Pattern {
+	t.Helper()
+	p, err := CompileOptions{Mode: ModeRedisBytes}.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Compile(%q, ModeRedisBytes) error = %v", pattern, err)
+	}
+	return p
+}
+
+func TestRedisSubmatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		want         []string
+	}{
+		{"user:*:?", "user:42:x", []string{"42", "x"}},
+		{"??", "é", []string{"é"}},
+		{"[é]*", "é", []string{"\xc3", "\xa9"}},
+		{`a\\*`, `a\b`, []string{"b"}},
+		{"[abc", "b", []string{"b"}},
+		{"*", "x", []string{"x"}},
+		{"", "", []string{}},
+	}
+	for _, tt := range cases {
+		got, ok := compileRedisMode(t, tt.pattern).Submatch(tt.str)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Compile(%q, ModeRedisBytes).Submatch(%q) = %q, %v; want %q, true", tt.pattern, tt.str, got, ok, tt.want)
+		}
+	}
+	deep := compileRedisMode(t, strings.Repeat("a*", 1002))
+	if _, ok := deep.Submatch(strings.Repeat("a", 1200)); ok {
+		t.Error("Submatch accepted a pattern beyond the nesting limit")
+	}
+}
+
+// TestRedisTokens checks the operations built on compileRedis against
+// stringmatchRedis on random patterns and inputs.
+func TestRedisTokens(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	random := func(alphabet string, n int) string {
+		b := make([]byte, r.IntN(n))
+		for i := range b {
+			b[i] = alphabet[r.IntN(len(alphabet))]
+		}
+		return string(b)
+	}
+	for range 5000 {
+		pattern := random("aB*?[]^-\\\xff", 10)
+		p := compileRedisMode(t, pattern)
+		for range 20 {
+			str := random("abAB-]\\\xff", 6)
+			for _, fold := range []bool{false, true} {
+				want := stringmatchRedis(str, pattern, fold)
+				if _, ok := p.submatchIndex(str, fold); ok != want {
+					t.Fatalf("Compile(%q, ModeRedisBytes).submatchIndex(%q, %v) ok = %v, want %v", pattern, str, fold, ok, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package redglob
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Regexp returns an anchored regexp.Regexp that matches exactly the strings
+// Match accepts. Go's regexp package reads invalid UTF-8 as U+FFFD one byte
+// at a time, as Match does, so the two agree on arbitrary input. Use
+// ToRegexpString for the MatchFold equivalent.
+//
+// An invalid pattern yields a regexp that never matches. Patterns compiled
+// with ModeRedisBytes cannot be expressed over runes, and Regexp returns nil
+// for them and for patterns compiled with a Separator. Each call compiles a
+// new regexp.
+func (p *Pattern) Regexp() *regexp.Regexp {
+	if p != nil && (p.mode == ModeRedisBytes || p.segments != nil) {
+		return nil
+	}
+	return regexp.MustCompile(p.regexpString(false))
+}
+
+// ToRegexpString translates pattern to an anchored RE2 expression with the
+// same semantics as Match, or as MatchFold if fold is true. It returns the
+// *SyntaxError from CompileErr for a malformed pattern. An empty class "[]"
+// translates to a class that matches nothing.
+func ToRegexpString(pattern string, fold bool) (string, error) {
+	p, err := CompileErr(pattern)
+	if err != nil {
+		return "", err
+	}
+	return p.regexpString(fold), nil
+}
+
+// ToRegexpSyntax is like ToRegexpString but returns the parsed
+// regexp/syntax tree, for tools that consume or rewrite the AST.
+func ToRegexpSyntax(pattern string, fold bool) (*syntax.Regexp, error) {
+	expr, err := ToRegexpString(pattern, fold)
+	if err != nil {
+		return nil, err
+	}
+	return syntax.Parse(expr, syntax.Perl)
+}
+
+// regexpNever is a class with no members. regexp/syntax accepts it and it
+// never matches.
+const regexpNever = `[^\x00-\x{10FFFF}]`
+
+func (p *Pattern) regexpString(fold bool) string {
+	var b strings.Builder
+	if fold {
+		b.WriteString("(?i)")
+	}
+	b.WriteString(`\A`)
+	if p == nil || !p.valid {
+		b.WriteString(regexpNever)
+	} else {
+		tokens := p.tokenStream()
+		for i := range tokens {
+			writeRegexpToken(&b, &tokens[i])
+		}
+	}
+	b.WriteString(`\z`)
+	return b.String()
+}
+
+func writeRegexpToken(b *strings.Builder, tok *token) {
+	switch tok.kind {
+	case tokenLiteral:
+		writeRegexpRune(b, tok.char)
+	case tokenLiteralRun:
+		for _, char := range tok.lit {
+			writeRegexpRune(b, char)
+		}
+	case tokenAny:
+		b.WriteString(`(?s:.)`)
+	case tokenAnyN:
+		// RE2 caps repetition counts at 1000.
+		for count := tok.count; count > 0; count -= 1000 {
+			b.WriteString(`(?s:.){`)
+			b.WriteString(strconv.Itoa(min(count, 1000)))
+			b.WriteByte('}')
+		}
+	case tokenStar:
+		b.WriteString(`(?s:.*)`)
+	case tokenClass:
+		writeRegexpClass(b, tok.class)
+	case tokenAlt:
+		b.WriteString(`(?:`)
+		for i, alt := range tok.alt.alts {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			for j := range alt {
+				writeRegexpToken(b, &alt[j])
+			}
+		}
+		b.WriteByte(')')
+	}
+}
+
+// writeRegexpRune writes a literal rune. U+FFFD is written as a class so it
+// is not folded into the regexp's byte-wise literal prefix, which would stop
+// it from matching invalid input bytes the way Match does.
+func writeRegexpRune(b *strings.Builder, char rune) {
+	if char == utf8.RuneError {
+		b.WriteString(`[\x{FFFD}]`)
+		return
+	}
+	if char < utf8.RuneSelf && (unicode.IsLetter(char) || unicode.IsDigit(char)) {
+		b.WriteRune(char)
+		return
+	}
+	writeRegexpEscape(b, char)
+}
+
+func writeRegexpEscape(b *strings.Builder, char rune) {
+	b.WriteString(`\x{`)
+	b.WriteString(strconv.FormatInt(int64(char), 16))
+	b.WriteByte('}')
+}
+
+// writeRegexpClass writes class with its ranges already ordered, which is
+// how compileClass stores reversed ranges such as "[z-a]".
+func writeRegexpClass(b *strings.Builder, class *compiledClass) {
+	if class.rangeCount == 0 && len(class.tables) == 0 {
+		if class.negated {
+			b.WriteString(`(?s:.)`)
+		} else {
+			b.WriteString(regexpNever)
+		}
+		return
+	}
+	ranges := class.ranges
+	if class.rangeCount == 1 {
+		ranges = []charRange{class.rangeOne}
+	}
+	b.WriteByte('[')
+	if class.negated {
+		b.WriteByte('^')
+	}
+	for _, r := range ranges {
+		writeRegexpEscape(b, r.start)
+		if r.end != r.start {
+			b.WriteByte('-')
+			writeRegexpEscape(b, r.end)
+		}
+	}
+	for _, t := range class.tables {
+		switch {
+		case t.posix:
+			b.WriteString(`[:^`)
+			b.WriteString(t.name)
+			b.WriteString(`:]`)
+		case t.negated:
+			b.WriteString(`\P{`)
+			b.WriteString(t.name)
+			b.WriteByte('}')
+		default:
+			b.WriteString(`\p{`)
+			b.WriteString(t.name)
+			b.WriteByte('}')
+		}
+	}
+	b.WriteByte(']')
+}
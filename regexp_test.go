@@ -0,0 +1,102 @@
+package redglob
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	check := func(str, pattern string) {
+		t.Helper()
+		expr, err := ToRegexpString(pattern, false)
+		if err != nil {
+			if Compile(pattern).valid {
+				t.Errorf("ToRegexpString(%q) error = %v", pattern, err)
+			}
+			return
+		}
+		if got, want := regexp.MustCompile(expr).MatchString(str), stringmatch(str, pattern, false); got != want {
+			t.Errorf("ToRegexpString(%q) = %q; MatchString(%q) = %v, want %v", pattern, expr, str, got, want)
+		}
+		foldExpr, _ := ToRegexpString(pattern, true)
+		if got, want := regexp.MustCompile(foldExpr).MatchString(str), stringmatch(str, pattern, true); got != want {
+			t.Errorf("ToRegexpString(%q, fold) = %q; MatchString(%q) = %v, want %v", pattern, foldExpr, str, got, want)
+		}
+	}
+	for _, tt := range allMatchCases() {
+		check(tt.args.str, tt.args.pattern)
+	}
+	for _, tt := range foldTests {
+		check(tt.str, tt.pattern)
+	}
+	for _, tt := range []struct{ str, pattern string }{
+		{"a.b", `a.b`},
+		{"axb", `a.b`},
+		{"a$^|()+{}b", `a$^|()+{}b`},
+		{"\n", "?"},
+		{"\n", "[^a]"},
+		{"m", "[z-a]"},
+		{"]", `[\]]`},
+		{"-", `[a\-z]`},
+		{"\xff", "?"},
+		{"\xff", "\xfe"},
+		{"\xff", "�"},
+		{"\xff", "[^a]"},
+		{"x", "[]"},
+		{"x", "[^]"},
+		{strings.Repeat("a", 1500), strings.Repeat("?", 1500)},
+		{"K", "k"},
+		{"K", "[j-l]"},
+	} {
+		check(tt.str, tt.pattern)
+	}
+}
+
+func TestPatternRegexp(t *testing.T) {
+	re := Compile("user:[0-9]*").Regexp()
+	if !re.MatchString("user:42") || re.MatchString("admin:42") || re.MatchString("xuser:42") {
+		t.Errorf("Compile(user:[0-9]*).Regexp() = %q has wrong semantics", re)
+	}
+	if re := Compile("[").Regexp(); re.MatchString("[") || re.MatchString("") {
+		t.Errorf("Compile([).Regexp() = %q matched", re)
+	}
+	redis, _ := CompileOptions{Mode: ModeRedisBytes}.Compile("a*")
+	if redis.Regexp() != nil {
+		t.Error("ModeRedisBytes pattern produced a regexp")
+	}
+	var syntaxErr *SyntaxError
+	if _, err := ToRegexpString(`a\`, false); !errors.As(err, &syntaxErr) || syntaxErr.Code != ErrDanglingEscape {
+		t.Errorf("ToRegexpString(`a\\`) error = %v, want %s", err, ErrDanglingEscape)
+	}
+	ast, err := ToRegexpSyntax("a*", false)
+	if err != nil || ast.String() == "" {
+		t.Errorf("ToRegexpSyntax(a*) = %v, %v", ast, err)
+	}
+}
+
+func FuzzRegexp(f *testing.F) {
+	for _, tt := range allMatchCases() {
+		f.Add(tt.args.str, tt.args.pattern)
+	}
+	f.Add("\xff\n", "?[^a]")
+	f.Add("K", "[^j-l]")
+	f.Fuzz(func(t *testing.T, str, pattern string) {
+		expr, err := ToRegexpString(pattern, false)
+		if err != nil {
+			return
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			t.Fatalf("ToRegexpString(%q) = %q does not compile: %v", pattern, expr, err)
+		}
+		if got, want := re.MatchString(str), stringmatch(str, pattern, false); got != want {
+			t.Errorf("ToRegexpString(%q) = %q; MatchString(%q) = %v, want %v", pattern, expr, str, got, want)
+		}
+		foldExpr, _ := ToRegexpString(pattern, true)
+		if got, want := regexp.MustCompile(foldExpr).MatchString(str), stringmatch(str, pattern, true); got != want {
+			t.Errorf("ToRegexpString(%q, fold) = %q; MatchString(%q) = %v, want %v", pattern, foldExpr, str, got, want)
+		}
+	})
+}
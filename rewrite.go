@@ -0,0 +1,132 @@
+package redglob
+
+import "strings"
+
+// Rewriter maps strings matching a source pattern onto a target template,
+// in the style of mmv. Its methods are safe for concurrent use.
+//
+// The template is literal text with capture references:
+//
+//	'*'      the next '*' capture of the source, left to right
+//	'#' n    the n-th capture of any kind (1-based, see Submatch)
+//	'\\' c   the character c, so '\*', '\#' and '\\' are literal
+//
+// For example, source "session:*:data" with template "sess:v2:*" rewrites
+// "session:42:data" to "sess:v2:42".
+type Rewriter struct {
+	pattern *Pattern
+	parts   []templatePart
+}
+
+// templatePart is a literal run when capture < 0, else a capture index.
+type templatePart struct {
+	lit     string
+	capture int
+}
+
+// NewRewriter compiles source and template. It returns a *SyntaxError if
+// source is invalid or the template references a capture source lacks.
+func NewRewriter(source, template string) (*Rewriter, error) {
+	p, err := compile(source)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := compileTemplate(template, p.tokenStream())
+	if err != nil {
+		return nil, err
+	}
+	return &Rewriter{pattern: p, parts: parts}, nil
+}
+
+// Rewrite applies the template to str. It reports false, and returns "", if
+// str does not match the source pattern.
+func (r *Rewriter) Rewrite(str string) (string, bool) {
+	index, ok := r.pattern.SubmatchIndex(str)
+	if !ok {
+		return "", false
+	}
+	size := 0
+	for _, part := range r.parts {
+		if part.capture < 0 {
+			size += len(part.lit)
+		} else {
+			size += index[2*part.capture+1] - index[2*part.capture]
+		}
+	}
+	var b strings.Builder
+	b.Grow(size)
+	for _, part := range r.parts {
+		if part.capture < 0 {
+			b.WriteString(part.lit)
+		} else {
+			b.WriteString(str[index[2*part.capture]:index[2*part.capture+1]])
+		}
+	}
+	return b.String(), true
+}
+
+func compileTemplate(template string, tokens []token) ([]templatePart, *SyntaxError) {
+	var stars []int
+	captures := 0
+	for _, tok := range tokens {
+		if tok.kind == tokenStar {
+			stars = append(stars, captures)
+		}
+		if isCapture(tok.kind) {
+			captures++
+		}
+	}
+
+	var parts []templatePart
+	var lit []byte
+	appendCapture := func(capture int) {
+		if len(lit) > 0 {
+			parts = append(parts, templatePart{lit: string(lit), capture: -1})
+			lit = lit[:0]
+		}
+		parts = append(parts, templatePart{capture: capture})
+	}
+	nextStar := 0
+	for offset := 0; offset < len(template); {
+		switch template[offset] {
+		case '\\':
+			if offset+1 == len(template) {
+				return nil, newSyntaxError(ErrDanglingEscape, template, offset)
+			}
+			_, size := decodeRune(template[offset+1:])
+			lit = append(lit, template[offset+1:offset+1+size]...)
+			offset += 1 + size
+		case '*':
+			if nextStar == len(stars) {
+				return nil, newSyntaxError(ErrMissingCapture, template, offset)
+			}
+			appendCapture(stars[nextStar])
+			nextStar++
+			offset++
+		case '#':
+			end := offset + 1
+			n := 0
+			for end < len(template) && template[end] >= '0' && template[end] <= '9' {
+				if n <= captures {
+					n = n*10 + int(template[end]-'0')
+				}
+				end++
+			}
+			if end == offset+1 {
+				return nil, newSyntaxError(ErrInvalidReference, template, offset)
+			}
+			if n < 1 || n > captures {
+				return nil, newSyntaxError(ErrMissingCapture, template, offset)
+			}
+			appendCapture(n - 1)
+			offset = end
+		default:
+			lit = append(lit, template[offset])
+			offset++
+		}
+	}
+	if len(lit) > 0 {
+		parts = append(parts, templatePart{lit: string(lit), capture: -1})
+	}
+	return parts, nil
+}
@@ -0,0 +1,63 @@
+package redglob
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRewriter(t *testing.T) {
+	cases := []struct {
+		source, template, str string
+		want                  string
+		ok                    bool
+	}{
+		{"session:*:data", "sess:v2:*", "session:42:data", "sess:v2:42", true},
+		{"session:*:data", "sess:v2:*", "session:42:meta", "", false},
+		{"a:*:b:*", "*/*", "a:x:b:y", "x/y", true},
+		{"a:*:b:*", "#2/#1", "a:x:b:y", "y/x", true},
+		{"user:[0-9]*", "u:#1#2", "user:42", "u:42", true},
+		{"user:[0-9]*", "u:*", "user:42", "u:2", true},
+		{"log-??.*", "#1/#2", "log-07.txt", "07/txt", true},
+		{"*", `\*\#\\#1`, "x", `*#\x`, true},
+		{"前*後", "[*]", "前中後", "[中]", true},
+		{"k", "v", "k", "v", true},
+		{"a*b*c", "#2#1", "axbyc", "yx", true},
+	}
+	for _, tt := range cases {
+		r, err := NewRewriter(tt.source, tt.template)
+		if err != nil {
+			t.Errorf("NewRewriter(%q, %q) error = %v", tt.source, tt.template, err)
+			continue
+		}
+		got, ok := r.Rewrite(tt.str)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NewRewriter(%q, %q).Rewrite(%q) = %q, %v; want %q, %v",
+				tt.source, tt.template, tt.str, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRewriterErrors(t *testing.T) {
+	cases := []struct {
+		source, template string
+		code             ErrorCode
+		offset           int
+	}{
+		{"a[", "x", ErrUnterminatedClass, 1},
+		{"a*", "**", ErrMissingCapture, 1},
+		{"前*後", "*·*", ErrMissingCapture, 3},
+		{"a?", "*", ErrMissingCapture, 0},
+		{"a*", "#2", ErrMissingCapture, 0},
+		{"a*", "x#0", ErrMissingCapture, 1},
+		{"a*", "x#99999999999999999999", ErrMissingCapture, 1},
+		{"a*", "#x", ErrInvalidReference, 0},
+		{"a*", `*\`, ErrDanglingEscape, 1},
+	}
+	for _, tt := range cases {
+		_, err := NewRewriter(tt.source, tt.template)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Code != tt.code || syntaxErr.Offset != tt.offset {
+			t.Errorf("NewRewriter(%q, %q) error = %v, want %s at offset %d", tt.source, tt.template, err, tt.code, tt.offset)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package redglob
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// PatternSet matches one string against many patterns at once. Patterns are
+// indexed by their literal prefix, literal suffix, or a required literal
+// factor, so a lookup runs the full matcher only on plausible candidates.
+//
+// The zero value is an empty set ready to use. Match and MatchAny are safe
+// for concurrent use with each other and with updates: readers see an
+// immutable snapshot, and Add and Remove publish a modified copy. Updates
+// therefore cost time proportional to the set size; a PatternSet suits
+// read-mostly workloads such as subscription fan-out or ACL checks.
+type PatternSet[K comparable] struct {
+	mu    sync.Mutex // serializes writers
+	index atomic.Pointer[patternIndex[K]]
+}
+
+type patternIndex[K comparable] struct {
+	entries map[K]*setEntry[K]
+
+	exact      map[string][]*setEntry[K]
+	prefixes   map[string][]*setEntry[K]
+	prefixLens []int // distinct key lengths of prefixes, ascending
+	suffixes   map[string][]*setEntry[K]
+	suffixLens []int // distinct key lengths of suffixes, ascending
+	trigrams   map[uint32][]*setEntry[K]
+	rest       []*setEntry[K]
+}
+
+type setEntry[K comparable] struct {
+	id      K
+	pattern *Pattern
+	bucket  setBucket
+	key     string
+}
+
+type setBucket uint8
+
+const (
+	bucketRest setBucket = iota
+	bucketExact
+	bucketPrefix
+	bucketSuffix
+	bucketTrigram
+)
+
+// Add compiles pattern and stores it under id, replacing any pattern already
+// stored under id. A malformed pattern, such as "a[" or a trailing '\\', is
+// rejected with the error CompileErr returns, and the set is left unchanged.
+func (s *PatternSet[K]) Add(id K, pattern string) error {
+	p, err := CompileErr(pattern)
+	if err != nil {
+		return err
+	}
+	s.AddPattern(id, p)
+	return nil
+}
+
+// AddPattern stores the compiled pattern p under id, replacing any pattern
+// already stored under id. It accepts patterns compiled with CompileOptions,
+// such as ModeRedisBytes patterns, which Add cannot express. A nil or
+// never-matching p is stored and matches nothing.
+func (s *PatternSet[K]) AddPattern(id K, p *Pattern) {
+	entry := newSetEntry(id, p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.index.Load().clone()
+	if old, ok := index.entries[id]; ok {
+		index.remove(old)
+	}
+	index.add(entry)
+	s.index.Store(index)
+}
+
+// Remove deletes the pattern stored under id and reports whether there was
+// one.
+func (s *PatternSet[K]) Remove(id K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.index.Load()
+	if current == nil {
+		return false
+	}
+	old, ok := current.entries[id]
+	if !ok {
+		return false
+	}
+	index := current.clone()
+	index.remove(old)
+	s.index.Store(index)
+	return true
+}
+
+// Len returns the number of patterns in the set.
+func (s *PatternSet[K]) Len() int {
+	index := s.index.Load()
+	if index == nil {
+		return 0
+	}
+	return len(index.entries)
+}
+
+// Match returns the ids of every pattern that matches str, in no particular
+// order. It returns nil if none match.
+func (s *PatternSet[K]) Match(str string) []K {
+	var ids []K
+	s.index.Load().candidates(str, func(entry *setEntry[K]) bool {
+		if entry.pattern.Match(str) {
+			ids = append(ids, entry.id)
+		}
+		return true
+	})
+	return ids
+}
+
+// MatchAny reports whether any pattern in the set matches str.
+func (s *PatternSet[K]) MatchAny(str string) bool {
+	found := false
+	s.index.Load().candidates(str, func(entry *setEntry[K]) bool {
+		found = entry.pattern.Match(str)
+		return !found
+	})
+	return found
+}
+
+// newSetEntry picks the most selective index for p. A literal prefix or
+// suffix narrows lookups to a map probe per distinct key length; otherwise a
+// trigram from the longest required factor is probed once per input offset.
+func newSetEntry[K comparable](id K, p *Pattern) *setEntry[K] {
+	entry := &setEntry[K]{id: id, pattern: p}
+	var prefix, suffix string
+	var exact bool
+	switch {
+	case p == nil || !p.valid:
+		return entry
+	case p.mode == ModeRedisBytes || p.segments != nil:
+		// The tokens are not flat, so only the literal prefix is indexed.
+		prefix, exact = p.LiteralPrefix()
+	default:
+		prefix, suffix, exact = p.literalAffixes()
+	}
+	switch {
+	case exact:
+		entry.bucket, entry.key = bucketExact, prefix
+	case prefix != "" && len(prefix) >= len(suffix):
+		entry.bucket, entry.key = bucketPrefix, prefix
+	case suffix != "":
+		entry.bucket, entry.key = bucketSuffix, suffix
+	default:
+		longest := ""
+		for _, factor := range p.literalFactors() {
+			if len(factor) > len(longest) {
+				longest = factor
+			}
+		}
+		if len(longest) >= 3 {
+			entry.bucket, entry.key = bucketTrigram, longest[:3]
+		}
+	}
+	return entry
+}
+
+func trigram(s string) uint32 {
+	return uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16
+}
+
+func (index *patternIndex[K]) clone() *patternIndex[K] {
+	if index == nil {
+		return &patternIndex[K]{
+			entries:  make(map[K]*setEntry[K]),
+			exact:    make(map[string][]*setEntry[K]),
+			prefixes: make(map[string][]*setEntry[K]),
+			suffixes: make(map[string][]*setEntry[K]),
+			trigrams: make(map[uint32][]*setEntry[K]),
+		}
+	}
+	// Buckets are shared with the previous snapshot. add and remove replace
+	// a bucket slice instead of writing through it.
+	return &patternIndex[K]{
+		entries:    maps.Clone(index.entries),
+		exact:      maps.Clone(index.exact),
+		prefixes:   maps.Clone(index.prefixes),
+		prefixLens: index.prefixLens,
+		suffixes:   maps.Clone(index.suffixes),
+		suffixLens: index.suffixLens,
+		trigrams:   maps.Clone(index.trigrams),
+		rest:       index.rest,
+	}
+}
+
+func (index *patternIndex[K]) add(entry *setEntry[K]) {
+	index.entries[entry.id] = entry
+	switch entry.bucket {
+	case bucketExact:
+		index.exact[entry.key] = appendEntry(index.exact[entry.key], entry)
+	case bucketPrefix:
+		index.prefixes[entry.key] = appendEntry(index.prefixes[entry.key], entry)
+		index.prefixLens = insertLen(index.prefixLens, len(entry.key))
+	case bucketSuffix:
+		index.suffixes[entry.key] = appendEntry(index.suffixes[entry.key], entry)
+		index.suffixLens = insertLen(index.suffixLens, len(entry.key))
+	case bucketTrigram:
+		key := trigram(entry.key)
+		index.trigrams[key] = appendEntry(index.trigrams[key], entry)
+	default:
+		index.rest = appendEntry(index.rest, entry)
+	}
+}
+
+func (index *patternIndex[K]) remove(entry *setEntry[K]) {
+	delete(index.entries, entry.id)
+	switch entry.bucket {
+	case bucketExact:
+		removeKey(index.exact, entry.key, entry)
+	case bucketPrefix:
+		if removeKey(index.prefixes, entry.key, entry) {
+			index.prefixLens = index.removeLen(index.prefixLens, len(entry.key), bucketPrefix)
+		}
+	case bucketSuffix:
+		if removeKey(index.suffixes, entry.key, entry) {
+			index.suffixLens = index.removeLen(index.suffixLens, len(entry.key), bucketSuffix)
+		}
+	case bucketTrigram:
+		removeKey(index.trigrams, trigram(entry.key), entry)
+	default:
+		index.rest = removeEntry(index.rest, entry)
+	}
+}
+
+// removeLen drops length from lengths unless another key of the same bucket
+// still has it.
+func (index *patternIndex[K]) removeLen(lengths []int, length int, bucket setBucket) []int {
+	keys := index.prefixes
+	if bucket == bucketSuffix {
+		keys = index.suffixes
+	}
+	for key := range keys {
+		if len(key) == length {
+			return lengths
+		}
+	}
+	i, _ := slices.BinarySearch(lengths, length)
+	return slices.Delete(slices.Clone(lengths), i, i+1)
+}
+
+func (index *patternIndex[K]) candidates(str string, yield func(*setEntry[K]) bool) {
+	if index == nil {
+		return
+	}
+	for _, entry := range index.exact[str] {
+		if !yield(entry) {
+			return
+		}
+	}
+	for _, length := range index.prefixLens {
+		if length > len(str) {
+			break
+		}
+		for _, entry := range index.prefixes[str[:length]] {
+			if !yield(entry) {
+				return
+			}
+		}
+	}
+	for _, length := range index.suffixLens {
+		if length > len(str) {
+			break
+		}
+		for _, entry := range index.suffixes[str[len(str)-length:]] {
+			if !yield(entry) {
+				return
+			}
+		}
+	}
+	if len(index.trigrams) > 0 && len(str) >= 3 {
+		var seen map[uint32]struct{}
+		for i := 0; i+3 <= len(str); i++ {
+			key := trigram(str[i:])
+			entries := index.trigrams[key]
+			if len(entries) == 0 {
+				continue
+			}
+			if seen == nil {
+				seen = make(map[uint32]struct{})
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			for _, entry := range entries {
+				if !yield(entry) {
+					return
+				}
+			}
+		}
+	}
+	for _, entry := range index.rest {
+		if !yield(entry) {
+			return
+		}
+	}
+}
+
+func appendEntry[K comparable](entries []*setEntry[K], entry *setEntry[K]) []*setEntry[K] {
+	return append(entries[:len(entries):len(entries)], entry)
+}
+
+func removeEntry[K comparable](entries []*setEntry[K], entry *setEntry[K]) []*setEntry[K] {
+	i := slices.Index(entries, entry)
+	if i < 0 {
+		return entries
+	}
+	return slices.Delete(slices.Clone(entries), i, i+1)
+}
+
+// removeKey removes entry from the bucket at key and reports whether the
+// bucket became empty.
+func removeKey[M ~map[T][]*setEntry[K], T comparable, K comparable](buckets M, key T, entry *setEntry[K]) bool {
+	entries := removeEntry(buckets[key], entry)
+	if len(entries) == 0 {
+		delete(buckets, key)
+		return true
+	}
+	buckets[key] = entries
+	return false
+}
+
+func insertLen(lengths []int, length int) []int {
+	i, found := slices.BinarySearch(lengths, length)
+	if found {
+		return lengths
+	}
+	return slices.Insert(slices.Clone(lengths), i, length)
+}
@@ -0,0 +1,205 @@
+package redglob
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestPatternSet(t *testing.T) {
+	var set PatternSet[string]
+	if got := set.Match("anything"); got != nil || set.MatchAny("anything") || set.Len() != 0 {
+		t.Fatalf("empty set matched: %v", got)
+	}
+	patterns := map[string]string{
+		"exact":   "cache:user:1",
+		"prefix":  "cache:*",
+		"suffix":  "*:profile",
+		"factor":  "*[a-z]user*",
+		"rest":    "?*",
+		"class":   "cache:[u]ser:?",
+		"unicode": "前*",
+		"empty":   "cache:[]*",
+	}
+	for id, pattern := range patterns {
+		if err := set.Add(id, pattern); err != nil {
+			t.Fatalf("Add(%q, %q) error = %v", id, pattern, err)
+		}
+	}
+	if err := set.Add("bad", "cache:["); err == nil {
+		t.Error("Add accepted an invalid pattern")
+	}
+	if set.Len() != len(patterns) {
+		t.Errorf("Len() = %d, want %d", set.Len(), len(patterns))
+	}
+
+	check := func(str string) {
+		t.Helper()
+		var want []string
+		for id, pattern := range patterns {
+			if Match(str, pattern) {
+				want = append(want, id)
+			}
+		}
+		got := set.Match(str)
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("Match(%q) = %v, want %v", str, got, want)
+		}
+		if matched := set.MatchAny(str); matched != (len(want) > 0) {
+			t.Errorf("MatchAny(%q) = %v, want %v", str, matched, len(want) > 0)
+		}
+	}
+	for _, str := range []string{"", "c", "cache:user:1", "cache:user:2", "x:profile", "xuser", "前後", "Auser"} {
+		check(str)
+	}
+
+	if !set.Remove("prefix") || set.Remove("prefix") {
+		t.Error("Remove did not report presence correctly")
+	}
+	delete(patterns, "prefix")
+	patterns["rest"] = "cache:user:?"
+	if err := set.Add("rest", patterns["rest"]); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"", "cache:user:1", "cache:user:2", "cache:x", "x:profile"} {
+		check(str)
+	}
+}
+
+func TestPatternSetRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", ":", "*", "?", "[ab]", "[^a]", "ab", "ba"}
+	var set PatternSet[int]
+	patterns := make(map[int]string)
+	for i := 0; i < 300; i++ {
+		var pattern string
+		for n := rng.Intn(6); n >= 0; n-- {
+			pattern += alphabet[rng.Intn(len(alphabet))]
+		}
+		id := rng.Intn(200)
+		if rng.Intn(5) == 0 {
+			set.Remove(id)
+			delete(patterns, id)
+			continue
+		}
+		if err := set.Add(id, pattern); err != nil {
+			t.Fatal(err)
+		}
+		patterns[id] = pattern
+	}
+	for i := 0; i < 500; i++ {
+		var str string
+		for n := rng.Intn(8); n > 0; n-- {
+			str += string("ab:"[rng.Intn(3)])
+		}
+		var want []int
+		for id, pattern := range patterns {
+			if Match(str, pattern) {
+				want = append(want, id)
+			}
+		}
+		got := set.Match(str)
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Fatalf("Match(%q) = %v, want %v", str, got, want)
+		}
+	}
+}
+
+func TestPatternSetConcurrent(t *testing.T) {
+	var set PatternSet[int]
+	if err := set.Add(-1, "key:*"); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			_ = set.Add(i, fmt.Sprintf("key:%d:*", i))
+			if i%2 == 0 {
+				set.Remove(i)
+			}
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if !set.MatchAny("key:7:x") {
+					t.Error("MatchAny lost the stable pattern")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkPatternSet(b *testing.B) {
+	var set PatternSet[int]
+	for i := 0; i < 5000; i++ {
+		_ = set.Add(i, fmt.Sprintf("tenant:%d:*", i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if got := set.Match("tenant:4242:orders"); len(got) != 1 {
+			b.Fatalf("Match() = %v", got)
+		}
+	}
+}
+
+// TestPatternSetAddPattern checks the index against Match for patterns
+// compiled with options, including a nil one.
+func TestPatternSetAddPattern(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	options := []CompileOptions{
+		{Mode: ModeRedisBytes},
+		{Separator: ':'},
+		{Braces: true},
+	}
+	alphabet := []string{"a", "b", ":", "*", "?", "[ab]", "[^a]", "ab", "{a,b}", "\\"}
+	var set PatternSet[int]
+	patterns := make(map[int]*Pattern)
+	set.AddPattern(-1, nil)
+	for i := 0; i < 300; i++ {
+		var pattern string
+		for n := rng.Intn(6); n >= 0; n-- {
+			pattern += alphabet[rng.Intn(len(alphabet))]
+		}
+		p, err := options[rng.Intn(len(options))].Compile(pattern)
+		if err != nil {
+			continue
+		}
+		id := rng.Intn(200)
+		set.AddPattern(id, p)
+		patterns[id] = p
+	}
+	if set.Len() != len(patterns)+1 {
+		t.Fatalf("Len() = %d, want %d", set.Len(), len(patterns)+1)
+	}
+	for i := 0; i < 500; i++ {
+		var str string
+		for n := rng.Intn(8); n > 0; n-- {
+			str += []string{"a", "b", ":", "é"}[rng.Intn(4)]
+		}
+		var want []int
+		for id, p := range patterns {
+			if p.Match(str) {
+				want = append(want, id)
+			}
+		}
+		got := set.Match(str)
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Fatalf("Match(%q) = %v, want %v", str, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sqlglob
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+type itemKind uint8
+
+const (
+	itemLiteral itemKind = iota
+	itemAny
+	itemStar
+	itemClass
+)
+
+// item is one element of a redglob pattern: a literal rune, '?', a run of
+// '*', or a class with its ranges sorted and merged.
+type item struct {
+	kind    itemKind
+	char    rune
+	negated bool
+	ranges  []runeRange
+}
+
+type runeRange struct {
+	lo, hi rune
+}
+
+// parse splits a pattern that redglob.Validate accepted into items. It
+// follows redglob's parser: a '-' makes a range whenever at least one more
+// byte follows it, even if that byte is ']', and reversed ranges are
+// swapped.
+func parse(pattern string) []item {
+	var items []item
+	for len(pattern) > 0 {
+		char, size := utf8.DecodeRuneInString(pattern)
+		pattern = pattern[size:]
+		switch char {
+		case '*':
+			if len(items) == 0 || items[len(items)-1].kind != itemStar {
+				items = append(items, item{kind: itemStar})
+			}
+		case '?':
+			items = append(items, item{kind: itemAny})
+		case '[':
+			var class item
+			class, pattern = parseClass(pattern)
+			items = append(items, class)
+		case '\\':
+			char, size = utf8.DecodeRuneInString(pattern)
+			pattern = pattern[size:]
+			items = append(items, item{kind: itemLiteral, char: char})
+		default:
+			items = append(items, item{kind: itemLiteral, char: char})
+		}
+	}
+	return items
+}
+
+func parseClass(pattern string) (item, string) {
+	class := item{kind: itemClass}
+	if len(pattern) > 0 && pattern[0] == '^' {
+		class.negated = true
+		pattern = pattern[1:]
+	}
+	for {
+		start, size := utf8.DecodeRuneInString(pattern)
+		switch {
+		case start == '\\':
+			pattern = pattern[size:]
+			start, size = utf8.DecodeRuneInString(pattern)
+		case start == ']':
+			class.ranges = normalize(class.ranges)
+			return class, pattern[size:]
+		case len(pattern) > size+1 && pattern[size] == '-':
+			end, endSize := utf8.DecodeRuneInString(pattern[size+1:])
+			class.ranges = append(class.ranges, runeRange{min(start, end), max(start, end)})
+			pattern = pattern[size+1+endSize:]
+			continue
+		}
+		class.ranges = append(class.ranges, runeRange{start, start})
+		pattern = pattern[size:]
+	}
+}
+
+// normalize sorts ranges and merges overlapping or adjacent ones.
+func normalize(ranges []runeRange) []runeRange {
+	slices.SortFunc(ranges, func(a, b runeRange) int {
+		return int(a.lo - b.lo)
+	})
+	out := ranges[:0]
+	for _, r := range ranges {
+		if n := len(out); n > 0 && r.lo <= out[n-1].hi+1 {
+			out[n-1].hi = max(out[n-1].hi, r.hi)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// size returns the number of runes in ranges.
+func size(ranges []runeRange) int {
+	n := 0
+	for _, r := range ranges {
+		n += int(r.hi-r.lo) + 1
+	}
+	return n
+}
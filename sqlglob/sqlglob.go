@@ -0,0 +1,449 @@
+// Package sqlglob translates redglob patterns into parameterized SQL
+// predicates, so a database can filter rows server-side instead of
+// streaming every key to Go.
+//
+// Translations assume text columns hold valid UTF-8 and that the database
+// compares characters by code point, which is how SQLite and PostgreSQL
+// with a UTF-8 database treat GLOB, LIKE, regular expressions, and
+// SIMILAR TO. Under those assumptions '?' matches one rune on both sides.
+// Byte-oriented columns (BLOB, bytea, SQL_ASCII) do not meet them.
+//
+// When a dialect cannot express a pattern exactly, Translate falls back to
+// a LIKE prefilter that accepts a superset of the matching values and
+// returns the pattern as a residual to apply in Go:
+//
+//	pred, err := sqlglob.Translate("key", "user:[0-9]*", sqlglob.Options{Dialect: sqlglob.Like})
+//	rows, err := db.Query("SELECT key FROM kv WHERE "+pred.SQL, pred.Args...)
+//	for rows.Next() {
+//		// ...
+//		if pred.Filter(key) {
+//			// key matches the pattern
+//		}
+//	}
+package sqlglob
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/maolonglong/redglob"
+)
+
+// Dialect selects the SQL construct a pattern is translated into.
+type Dialect uint8
+
+const (
+	// SQLiteGlob translates to SQLite's case-sensitive GLOB operator.
+	SQLiteGlob Dialect = iota
+	// Like translates to LIKE with backslash as the ESCAPE character. It
+	// assumes LIKE is case-sensitive, as in PostgreSQL and the SQL standard.
+	// LIKE has no character classes, so patterns with classes are inexact.
+	Like
+	// PostgresRegex translates to PostgreSQL's ~ operator with an anchored
+	// advanced regular expression.
+	PostgresRegex
+	// PostgresSimilar translates to PostgreSQL's SIMILAR TO with backslash
+	// as the ESCAPE character.
+	PostgresSimilar
+)
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case SQLiteGlob:
+		return "SQLiteGlob"
+	case Like:
+		return "Like"
+	case PostgresRegex:
+		return "PostgresRegex"
+	case PostgresSimilar:
+		return "PostgresSimilar"
+	default:
+		return "Dialect(" + strconv.Itoa(int(d)) + ")"
+	}
+}
+
+// Options configures Translate.
+type Options struct {
+	// Dialect is the SQL construct to translate into.
+	Dialect Dialect
+	// Fold translates with the semantics of MatchFold instead of Match.
+	Fold bool
+	// Placeholder is the bind parameter written into the SQL. It defaults
+	// to "?" for SQLiteGlob and Like, and to "$1" for the PostgreSQL
+	// dialects.
+	Placeholder string
+}
+
+// Predicate is a translated pattern.
+type Predicate struct {
+	// SQL is a boolean expression such as `key GLOB ?`.
+	SQL string
+	// Args holds the values to bind to the placeholder in SQL.
+	Args []any
+	// Exact reports whether SQL selects exactly the values the pattern
+	// matches. When it is false, SQL selects a superset and Filter must be
+	// applied to each returned value.
+	Exact bool
+	// Reason explains why the translation is not exact. It is empty when
+	// Exact is true.
+	Reason string
+	// Residual is the compiled pattern. It is nil when Exact is true.
+	Residual *redglob.Pattern
+
+	fold bool
+}
+
+// Filter reports whether value, selected by SQL, matches the pattern. It
+// always returns true for exact translations.
+func (pred *Predicate) Filter(value string) bool {
+	if pred.Residual == nil {
+		return true
+	}
+	if pred.fold {
+		return pred.Residual.MatchFold(value)
+	}
+	return pred.Residual.Match(value)
+}
+
+// maxFoldClass bounds the number of runes whose case variants are
+// enumerated when a class is translated with Fold.
+const maxFoldClass = 256
+
+// Translate translates pattern into a predicate on column. Column is
+// written into the SQL verbatim and must be a trusted expression; the
+// pattern itself is always passed as a bind parameter.
+//
+// Invalid patterns return the *redglob.SyntaxError from redglob.Validate. A
+// pattern with an empty class such as "[]" matches nothing and translates
+// to the exact predicate "1 = 0", which binds no arguments.
+func Translate(column, pattern string, opts Options) (*Predicate, error) {
+	if err := redglob.Validate(pattern); err != nil {
+		return nil, err
+	}
+	items := parse(pattern)
+	if slices.ContainsFunc(items, func(it item) bool {
+		return it.kind == itemClass && !it.negated && len(it.ranges) == 0
+	}) {
+		return &Predicate{SQL: "1 = 0", Exact: true}, nil
+	}
+	hasClass := slices.ContainsFunc(items, func(it item) bool {
+		return it.kind == itemClass
+	})
+	if opts.Fold {
+		var ok bool
+		if items, ok = foldItems(items); !ok {
+			return fallback(column, pattern, items, opts,
+				"class too large to translate with case folding")
+		}
+	}
+	var b strings.Builder
+	var sql string
+	switch opts.Dialect {
+	case SQLiteGlob:
+		writeGlob(&b, items)
+		sql = column + " GLOB " + placeholder(opts, "?")
+	case Like:
+		if hasClass {
+			return fallback(column, pattern, items, opts,
+				"LIKE has no character classes")
+		}
+		if slices.ContainsFunc(items, func(it item) bool { return it.kind == itemClass }) {
+			return fallback(column, pattern, items, opts,
+				"LIKE cannot fold case")
+		}
+		writeLike(&b, items)
+		sql = column + " LIKE " + placeholder(opts, "?") + ` ESCAPE '\'`
+	case PostgresRegex:
+		writeRegex(&b, items)
+		sql = column + " ~ " + placeholder(opts, "$1")
+	case PostgresSimilar:
+		writeSimilar(&b, items)
+		sql = column + " SIMILAR TO " + placeholder(opts, "$1") + ` ESCAPE '\'`
+	default:
+		return fallback(column, pattern, items, opts,
+			"unknown dialect "+opts.Dialect.String())
+	}
+	return &Predicate{SQL: sql, Args: []any{b.String()}, Exact: true}, nil
+}
+
+func placeholder(opts Options, def string) string {
+	if opts.Placeholder != "" {
+		return opts.Placeholder
+	}
+	return def
+}
+
+// fallback returns a LIKE prefilter for items. Classes become '_', which
+// accepts a superset, and the pattern is kept as the residual.
+func fallback(column, pattern string, items []item, opts Options, reason string) (*Predicate, error) {
+	def := "?"
+	if opts.Dialect == PostgresRegex || opts.Dialect == PostgresSimilar {
+		def = "$1"
+	}
+	var b strings.Builder
+	writeLike(&b, items)
+	return &Predicate{
+		SQL:      column + " LIKE " + placeholder(opts, def) + ` ESCAPE '\'`,
+		Args:     []any{b.String()},
+		Reason:   reason,
+		Residual: redglob.Compile(pattern),
+		fold:     opts.Fold,
+	}, nil
+}
+
+// foldItems rewrites items so that case-sensitive matching of the result
+// is equivalent to MatchFold of the original: cased literals become classes
+// of their case variants and classes are closed under case folding. It
+// reports false, leaving classes untouched, if a class is too large to
+// close.
+func foldItems(items []item) ([]item, bool) {
+	out := make([]item, 0, len(items))
+	ok := true
+	for _, it := range items {
+		switch it.kind {
+		case itemLiteral:
+			if orbit := foldOrbit(nil, it.char); len(orbit) > 1 {
+				it = item{kind: itemClass, ranges: normalize(orbit)}
+			}
+		case itemClass:
+			if size(it.ranges) > maxFoldClass {
+				ok = false
+				break
+			}
+			var ranges []runeRange
+			for _, r := range it.ranges {
+				for char := r.lo; char <= r.hi; char++ {
+					ranges = foldOrbit(ranges, char)
+				}
+			}
+			it.ranges = normalize(ranges)
+		}
+		out = append(out, it)
+	}
+	return out, ok
+}
+
+// foldOrbit appends char and its case variants as single-rune ranges.
+func foldOrbit(ranges []runeRange, char rune) []runeRange {
+	ranges = append(ranges, runeRange{char, char})
+	for f := unicode.SimpleFold(char); f != char; f = unicode.SimpleFold(f) {
+		ranges = append(ranges, runeRange{f, f})
+	}
+	return ranges
+}
+
+// writeLike writes items as a LIKE pattern. Classes are written as '_', so
+// the result is exact only for patterns without classes.
+func writeLike(b *strings.Builder, items []item) {
+	for _, it := range items {
+		switch it.kind {
+		case itemStar:
+			b.WriteByte('%')
+		case itemAny, itemClass:
+			b.WriteByte('_')
+		case itemLiteral:
+			if it.char == '%' || it.char == '_' || it.char == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(it.char)
+		}
+	}
+}
+
+// writeGlob writes items as an SQLite GLOB pattern. GLOB has no escape
+// character; metacharacters are matched with one-rune classes instead.
+func writeGlob(b *strings.Builder, items []item) {
+	for _, it := range items {
+		switch it.kind {
+		case itemStar:
+			b.WriteByte('*')
+		case itemAny:
+			b.WriteByte('?')
+		case itemLiteral:
+			if it.char == '*' || it.char == '?' || it.char == '[' {
+				b.WriteByte('[')
+				b.WriteRune(it.char)
+				b.WriteByte(']')
+			} else {
+				b.WriteRune(it.char)
+			}
+		case itemClass:
+			writeGlobClass(b, it)
+		}
+	}
+}
+
+// writeGlobClass writes a class in SQLite's bracket syntax, which has no
+// escapes: ']' is literal only in first position, '-' only in last, and
+// '^' anywhere but first. Ranges are split around those three runes so
+// that they can be placed accordingly.
+func writeGlobClass(b *strings.Builder, it item) {
+	if len(it.ranges) == 0 {
+		if it.negated {
+			b.WriteByte('?')
+		} else {
+			b.WriteString("[]") // unreachable: Translate answers "[]" itself
+		}
+		return
+	}
+	if !it.negated && len(it.ranges) == 1 && it.ranges[0].lo == it.ranges[0].hi {
+		writeGlob(b, []item{{kind: itemLiteral, char: it.ranges[0].lo}})
+		return
+	}
+	ranges := it.ranges
+	var close, dash, caret bool
+	for _, special := range []rune{']', '-', '^'} {
+		var found bool
+		ranges, found = carve(ranges, special)
+		switch special {
+		case ']':
+			close = found
+		case '-':
+			dash = found
+		case '^':
+			caret = found
+		}
+	}
+	b.WriteByte('[')
+	if it.negated {
+		b.WriteByte('^')
+	}
+	if close {
+		b.WriteByte(']')
+	}
+	// A leading '^' would negate the class; it is safe after any other
+	// member, and a lone '^' is handled as a literal above.
+	if caret && (it.negated || close) {
+		b.WriteByte('^')
+		caret = false
+	}
+	for _, r := range ranges {
+		writeRange(b, r, nil)
+	}
+	if caret && len(ranges) == 0 {
+		// Only '-' precedes it, and a leading '-' is literal.
+		b.WriteString("-^")
+	} else {
+		if caret {
+			b.WriteByte('^')
+		}
+		if dash {
+			b.WriteByte('-')
+		}
+	}
+	b.WriteByte(']')
+}
+
+// carve removes special from ranges and reports whether it was present.
+func carve(ranges []runeRange, special rune) ([]runeRange, bool) {
+	out := make([]runeRange, 0, len(ranges)+1)
+	found := false
+	for _, r := range ranges {
+		if special < r.lo || special > r.hi {
+			out = append(out, r)
+			continue
+		}
+		found = true
+		if r.lo < special {
+			out = append(out, runeRange{r.lo, special - 1})
+		}
+		if special < r.hi {
+			out = append(out, runeRange{special + 1, r.hi})
+		}
+	}
+	return out, found
+}
+
+// writeRange writes r as a single rune or a lo-hi range, passing each rune
+// through escape when it is non-nil.
+func writeRange(b *strings.Builder, r runeRange, escape func(*strings.Builder, rune)) {
+	write := func(char rune) {
+		if escape != nil {
+			escape(b, char)
+		} else {
+			b.WriteRune(char)
+		}
+	}
+	write(r.lo)
+	if r.hi == r.lo {
+		return
+	}
+	if r.hi > r.lo+1 {
+		b.WriteByte('-')
+	}
+	write(r.hi)
+}
+
+// writeRegex writes items as an anchored PostgreSQL advanced regular
+// expression. In the default, non-newline-sensitive mode '.' and negated
+// brackets match newlines, as '?' does.
+func writeRegex(b *strings.Builder, items []item) {
+	b.WriteByte('^')
+	for _, it := range items {
+		switch it.kind {
+		case itemStar:
+			b.WriteString(".*")
+		case itemAny:
+			b.WriteByte('.')
+		case itemLiteral:
+			writeRegexRune(b, it.char)
+		case itemClass:
+			writeBracket(b, it, ".", writeRegexRune)
+		}
+	}
+	b.WriteByte('$')
+}
+
+// writeRegexRune writes char, escaping ASCII punctuation. A backslash
+// before a letter or digit would start an escape sequence instead.
+func writeRegexRune(b *strings.Builder, char rune) {
+	if char < 0x80 && !('a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9') && char > ' ' {
+		b.WriteByte('\\')
+	}
+	b.WriteRune(char)
+}
+
+// writeSimilar writes items as a SIMILAR TO pattern with backslash as the
+// escape character.
+func writeSimilar(b *strings.Builder, items []item) {
+	for _, it := range items {
+		switch it.kind {
+		case itemStar:
+			b.WriteByte('%')
+		case itemAny:
+			b.WriteByte('_')
+		case itemLiteral:
+			writeSimilarRune(b, it.char)
+		case itemClass:
+			writeBracket(b, it, "_", writeSimilarRune)
+		}
+	}
+}
+
+func writeSimilarRune(b *strings.Builder, char rune) {
+	if strings.ContainsRune(`%_|*+?{}()[]\^$-.`, char) {
+		b.WriteByte('\\')
+	}
+	b.WriteRune(char)
+}
+
+// writeBracket writes a class as a bracket expression whose members are
+// written by escape. An empty negated class, which matches any rune, is
+// written as anyRune.
+func writeBracket(b *strings.Builder, it item, anyRune string, escape func(*strings.Builder, rune)) {
+	if len(it.ranges) == 0 && it.negated {
+		b.WriteString(anyRune)
+		return
+	}
+	b.WriteByte('[')
+	if it.negated {
+		b.WriteByte('^')
+	}
+	for _, r := range it.ranges {
+		writeRange(b, r, escape)
+	}
+	b.WriteByte(']')
+}
@@ -0,0 +1,244 @@
+package sqlglob
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/maolonglong/redglob"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opts    Options
+		sql     string
+		arg     string
+		exact   bool
+	}{
+		{"user:*", Options{Dialect: SQLiteGlob}, "key GLOB ?", "user:*", true},
+		{`a\*b?[*]`, Options{Dialect: SQLiteGlob}, "key GLOB ?", "a[*]b?[*]", true},
+		{"[^a-c]x", Options{Dialect: SQLiteGlob}, "key GLOB ?", "[^a-c]x", true},
+		{`[\]a\-]`, Options{Dialect: SQLiteGlob}, "key GLOB ?", "[]a-]", true},
+		{`[\^]`, Options{Dialect: SQLiteGlob}, "key GLOB ?", "^", true},
+		{`[\^\-]`, Options{Dialect: SQLiteGlob}, "key GLOB ?", "[-^]", true},
+		{`[\^a]`, Options{Dialect: SQLiteGlob}, "key GLOB ?", "[a^]", true},
+		{`[Z-a]`, Options{Dialect: SQLiteGlob}, "key GLOB ?", "[]^Z-\\_-a]", true},
+		{"[^]", Options{Dialect: SQLiteGlob}, "key GLOB ?", "?", true},
+		{"k1", Options{Dialect: SQLiteGlob, Fold: true}, "key GLOB ?", "[KkK]1", true},
+		{"[a-b]", Options{Dialect: SQLiteGlob, Fold: true}, "key GLOB ?", "[ABab]", true},
+		{"a_%", Options{Dialect: Like}, `key LIKE ? ESCAPE '\'`, `a\_\%`, true},
+		{`*\\?`, Options{Dialect: Like, Placeholder: "$2"}, `key LIKE $2 ESCAPE '\'`, `%\\_`, true},
+		{"id:[0-9]*", Options{Dialect: Like}, `key LIKE ? ESCAPE '\'`, "id:_%", false},
+		{"id:*", Options{Dialect: Like, Fold: true}, `key LIKE ? ESCAPE '\'`, "__:%", false},
+		{"a.b*?", Options{Dialect: PostgresRegex}, "key ~ $1", `^a\.b.*.$`, true},
+		{`[^\]\\-]é`, Options{Dialect: PostgresRegex}, "key ~ $1", `^[^\-\\\]]é$`, true},
+		{"x", Options{Dialect: PostgresRegex, Fold: true}, "key ~ $1", `^[Xx]$`, true},
+		{"a_%(*?", Options{Dialect: PostgresSimilar}, `key SIMILAR TO $1 ESCAPE '\'`, `a\_\%\(%_`, true},
+		{"[a-c.]", Options{Dialect: PostgresSimilar}, `key SIMILAR TO $1 ESCAPE '\'`, `[\.a-c]`, true},
+		{"[^Ā-￿]", Options{Dialect: PostgresRegex, Fold: true}, `key LIKE $1 ESCAPE '\'`, "_", false},
+	}
+	for _, tt := range tests {
+		pred, err := Translate("key", tt.pattern, tt.opts)
+		if err != nil {
+			t.Fatalf("Translate(%q, %+v): %v", tt.pattern, tt.opts, err)
+		}
+		if pred.SQL != tt.sql || !reflect.DeepEqual(pred.Args, []any{tt.arg}) || pred.Exact != tt.exact {
+			t.Errorf("Translate(%q, %+v) = %q %q exact=%v, want %q %q exact=%v",
+				tt.pattern, tt.opts, pred.SQL, pred.Args, pred.Exact, tt.sql, tt.arg, tt.exact)
+		}
+		if pred.Exact != (pred.Reason == "") || pred.Exact != (pred.Residual == nil) {
+			t.Errorf("Translate(%q, %+v): exact=%v reason=%q residual=%v",
+				tt.pattern, tt.opts, pred.Exact, pred.Reason, pred.Residual)
+		}
+	}
+}
+
+func TestTranslateInvalid(t *testing.T) {
+	_, err := Translate("key", "a[b", Options{})
+	var syntaxErr *redglob.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Code != redglob.ErrUnterminatedClass {
+		t.Fatalf("Translate(%q) error = %v, want %v", "a[b", err, redglob.ErrUnterminatedClass)
+	}
+	// An empty class is well formed and matches nothing.
+	for _, dialect := range []Dialect{SQLiteGlob, Like, PostgresRegex, PostgresSimilar} {
+		pred, err := Translate("key", "a[]*", Options{Dialect: dialect, Fold: true})
+		if err != nil || pred.SQL != "1 = 0" || !pred.Exact || len(pred.Args) != 0 {
+			t.Errorf("%v: Translate(%q) = %+v, %v", dialect, "a[]*", pred, err)
+		}
+	}
+}
+
+func TestPredicateFilter(t *testing.T) {
+	pred, err := Translate("key", "ID:[0-9]", Options{Dialect: Like, Fold: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for value, want := range map[string]bool{"id:7": true, "Id:x": false, "ID:77": false} {
+		if got := pred.Filter(value); got != want {
+			t.Errorf("Filter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+// TestTranslateRandom checks exact translations against emulations of the
+// SQL operators, and that every LIKE translation accepts a superset.
+func TestTranslateRandom(t *testing.T) {
+	const alphabet = "ab-]^*?[\\%_.kKKé"
+	runes := []rune(alphabet)
+	r := rand.New(rand.NewPCG(1, 2))
+	randomString := func(n int) string {
+		var b strings.Builder
+		for range r.IntN(n) {
+			b.WriteRune(runes[r.IntN(len(runes))])
+		}
+		return b.String()
+	}
+	for range 3000 {
+		pattern := randomString(8)
+		if redglob.Validate(pattern) != nil {
+			continue
+		}
+		p := redglob.Compile(pattern)
+		for _, fold := range []bool{false, true} {
+			match := p.Match
+			if fold {
+				match = p.MatchFold
+			}
+			for _, dialect := range []Dialect{SQLiteGlob, Like, PostgresRegex} {
+				pred, err := Translate("key", pattern, Options{Dialect: dialect, Fold: fold})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var arg string
+				var sqlMatch func(string) bool
+				if len(pred.Args) > 0 {
+					arg = pred.Args[0].(string)
+				}
+				switch {
+				case pred.SQL == "1 = 0":
+					sqlMatch = func(string) bool { return false }
+				case !pred.Exact || dialect == Like:
+					sqlMatch = likeRegexp(arg).MatchString
+				case dialect == SQLiteGlob:
+					sqlMatch = func(s string) bool { return globMatch([]rune(arg), []rune(s)) }
+				default:
+					sqlMatch = regexp.MustCompile("(?s)" + arg).MatchString
+				}
+				for range 20 {
+					s := randomString(6)
+					want := match(s)
+					got := sqlMatch(s)
+					if pred.Exact && got != want || !pred.Exact && (want && !got || pred.Filter(s) != want) {
+						t.Fatalf("pattern %q fold=%v %v: %s %q on %q = %v, pattern match = %v",
+							pattern, fold, dialect, pred.SQL, arg, s, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func likeRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)\A`)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '\\':
+			i++
+			fallthrough
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString(`\z`)
+	return regexp.MustCompile(b.String())
+}
+
+// globMatch is a port of the GLOB branch of SQLite's patternCompare.
+func globMatch(pattern, str []rune) bool {
+	next := func() rune {
+		if len(pattern) == 0 {
+			return 0
+		}
+		c := pattern[0]
+		pattern = pattern[1:]
+		return c
+	}
+	for len(pattern) > 0 {
+		switch c := next(); c {
+		case '*':
+			for len(pattern) > 0 && (pattern[0] == '*' || pattern[0] == '?') {
+				if pattern[0] == '?' {
+					if len(str) == 0 {
+						return false
+					}
+					str = str[1:]
+				}
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if globMatch(pattern, str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			char := str[0]
+			str = str[1:]
+			var prior rune
+			seen, invert := false, false
+			c2 := next()
+			if c2 == '^' {
+				invert = true
+				c2 = next()
+			}
+			if c2 == ']' {
+				seen = char == ']'
+				c2 = next()
+			}
+			for c2 != 0 && c2 != ']' {
+				if c2 == '-' && len(pattern) > 0 && pattern[0] != ']' && prior > 0 {
+					c2 = next()
+					if char >= prior && char <= c2 {
+						seen = true
+					}
+					prior = 0
+				} else {
+					if char == c2 {
+						seen = true
+					}
+					prior = c2
+				}
+				c2 = next()
+			}
+			if c2 == 0 || seen == invert {
+				return false
+			}
+		default:
+			if len(str) == 0 || str[0] != c {
+				return false
+			}
+			str = str[1:]
+		}
+	}
+	return len(str) == 0
+}
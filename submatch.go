@@ -0,0 +1,188 @@
+package redglob
+
+import "strings"
+
+// Submatch reports whether str matches the pattern and, if so, the text bound
+// to each wildcard. There is one element per '*' (after collapsing runs of
+// stars), per run of consecutive '?', and per '[...]' class, in pattern order.
+//
+// Captures are leftmost-shortest: each '*' consumes as few runes as possible
+// given the captures to its left, which is also what the backtracking
+// reference matcher would settle on first.
+//
+// A brace group is a capture of the alternative that matched, numbered
+// before the captures inside it; alternatives are tried in order, and the
+// captures of those not taken are empty. A numeric range {lo..hi} is a
+// single capture. With a Separator, each "**" segment is a capture of the
+// whole segments it matched, without the separators around them. In
+// ModeRedisBytes, '?' and classes capture bytes, not runes.
+func (p *Pattern) Submatch(str string) ([]string, bool) {
+	return p.submatch(str, false)
+}
+
+// SubmatchFold is like Submatch but compares using Unicode simple case
+// folding, as MatchFold does.
+func (p *Pattern) SubmatchFold(str string) ([]string, bool) {
+	return p.submatch(str, true)
+}
+
+// SubmatchIndex is like Submatch but returns byte offsets into str. Element
+// 2*i and 2*i+1 are the start and end of the i-th capture, or -1 for a
+// capture in a brace alternative that did not match.
+func (p *Pattern) SubmatchIndex(str string) ([]int, bool) {
+	return p.submatchIndex(str, false)
+}
+
+// SubmatchIndexFold is like SubmatchIndex but compares using Unicode simple
+// case folding, as MatchFold does.
+func (p *Pattern) SubmatchIndexFold(str string) ([]int, bool) {
+	return p.submatchIndex(str, true)
+}
+
+func (p *Pattern) submatch(str string, fold bool) ([]string, bool) {
+	index, ok := p.submatchIndex(str, fold)
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, len(index)/2)
+	for i := range out {
+		if index[2*i] >= 0 {
+			out[i] = str[index[2*i]:index[2*i+1]]
+		}
+	}
+	return out, true
+}
+
+// submatchIndex runs the same single-checkpoint walk as match, but without the
+// suffix and star-literal shortcuts so every capturing token gets a span.
+//
+//gocyclo:ignore
+func (p *Pattern) submatchIndex(str string, fold bool) ([]int, bool) {
+	switch {
+	case p == nil || !p.valid:
+		return nil, false
+	case p.mode == ModeRedisBytes:
+		return p.redisSubmatchIndex(str, fold)
+	case p.segments != nil:
+		return p.segmentsSubmatchIndex(str, fold)
+	case p.prog != nil:
+		return p.programSubmatchIndex(str, fold)
+	}
+	tokens := p.tokenStream()
+	groups := make([]int, len(tokens))
+	count := 0
+	for i := range tokens {
+		groups[i] = -1
+		if isCapture(tokens[i].kind) {
+			groups[i] = count
+			count++
+		}
+	}
+	spans := make([]int, 2*count)
+	record := func(tokenIndex, start, end int) {
+		if group := groups[tokenIndex]; group >= 0 {
+			spans[2*group], spans[2*group+1] = start, end
+		}
+	}
+
+	tokenIndex, stringIndex := 0, 0
+	starToken, starString := -1, 0
+	for stringIndex < len(str) || tokenIndex < len(tokens) {
+		if tokenIndex < len(tokens) {
+			tok := &tokens[tokenIndex]
+			switch tok.kind {
+			case tokenStar:
+				if tokenIndex == len(tokens)-1 {
+					record(tokenIndex, stringIndex, len(str))
+					return spans, true
+				}
+				starToken, starString = tokenIndex, stringIndex
+				record(tokenIndex, stringIndex, stringIndex)
+				tokenIndex++
+				continue
+			case tokenAnyN:
+				if next, ok := consumeAnyN(str, stringIndex, tok.count); ok {
+					record(tokenIndex, stringIndex, next)
+					tokenIndex++
+					stringIndex = next
+					continue
+				}
+			case tokenLiteralRun:
+				if next, ok := consumeLiteralRun(str, stringIndex, tok.lit, fold); ok {
+					tokenIndex++
+					stringIndex = next
+					continue
+				}
+			default:
+				if stringIndex < len(str) {
+					char, size := decodeRune(str[stringIndex:])
+					if p.tokenMatches(tok, char, fold) {
+						record(tokenIndex, stringIndex, stringIndex+size)
+						tokenIndex++
+						stringIndex += size
+						continue
+					}
+				}
+			}
+		} else if stringIndex >= len(str) {
+			break
+		}
+		if starToken < 0 || starString >= len(str) {
+			return nil, false
+		}
+		_, size := decodeRune(str[starString:])
+		starString += size
+		record(starToken, spans[2*groups[starToken]], starString)
+		stringIndex = starString
+		tokenIndex = starToken + 1
+	}
+	for tokenIndex < len(tokens) && tokens[tokenIndex].kind == tokenStar {
+		record(tokenIndex, stringIndex, stringIndex)
+		tokenIndex++
+	}
+	if tokenIndex != len(tokens) || stringIndex != len(str) {
+		return nil, false
+	}
+	return spans, true
+}
+
+func isCapture(kind tokenKind) bool {
+	switch kind {
+	case tokenStar, tokenAny, tokenAnyN, tokenClass:
+		return true
+	default:
+		return false
+	}
+}
+
+// tokenStream returns the pattern's tokens, materializing them for the
+// simple and literal-star fast paths that Compile leaves untokenized.
+func (p *Pattern) tokenStream() []token {
+	switch {
+	case p.simple:
+		var tokens []token
+		if p.prefix != "" {
+			tokens = append(tokens, token{kind: tokenLiteralRun, lit: p.prefix})
+		}
+		if p.hasStar {
+			tokens = append(tokens, token{kind: tokenStar})
+			if p.suffix != "" {
+				tokens = append(tokens, token{kind: tokenLiteralRun, lit: p.suffix})
+			}
+		}
+		return tokens
+	case p.literalStars:
+		var tokens []token
+		for i, lit := range strings.Split(p.prefix, "*") {
+			if i > 0 && (len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenStar) {
+				tokens = append(tokens, token{kind: tokenStar})
+			}
+			if lit != "" {
+				tokens = append(tokens, token{kind: tokenLiteralRun, lit: lit})
+			}
+		}
+		return tokens
+	default:
+		return p.tokens
+	}
+}
@@ -0,0 +1,91 @@
+package redglob
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubmatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		want         []string
+	}{
+		{"tenant:*:order:*", "tenant:acme:order:42", []string{"acme", "42"}},
+		{"tenant:*:order:*", "tenant:a:order:b:order:c", []string{"a", "b:order:c"}},
+		{"*", "", []string{""}},
+		{"*", "abc", []string{"abc"}},
+		{"a*", "a", []string{""}},
+		{"*b", "abab", []string{"aba"}},
+		{"a*b*c", "axbybzc", []string{"x", "ybz"}},
+		{"**x**", "axb", []string{"a", "b"}},
+		{"file-??.txt", "file-01.txt", []string{"01"}},
+		{"?*?", "abcd", []string{"a", "bc", "d"}},
+		{"user:[0-9]*", "user:42", []string{"4", "2"}},
+		{"[^a]?*", "前後x", []string{"前", "後", "x"}},
+		{`\**`, "*x", []string{"x"}},
+		{"literal", "literal", []string{}},
+		{"a*?", "abc", []string{"b", "c"}},
+		{"*a*", "bab", []string{"b", "b"}},
+	}
+	for _, tt := range cases {
+		p := Compile(tt.pattern)
+		got, ok := p.Submatch(tt.str)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Compile(%q).Submatch(%q) = %q, %v; want %q, true", tt.pattern, tt.str, got, ok, tt.want)
+		}
+	}
+}
+
+func TestSubmatchIndex(t *testing.T) {
+	p := Compile("tenant:*:order:[0-9]?")
+	got, ok := p.SubmatchIndex("tenant:acme:order:42")
+	if want := []int{7, 11, 18, 19, 19, 20}; !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("SubmatchIndex = %v, %v; want %v, true", got, ok, want)
+	}
+	if got, ok := p.SubmatchIndex("tenant:acme:order:x2"); ok || got != nil {
+		t.Errorf("SubmatchIndex(miss) = %v, %v; want nil, false", got, ok)
+	}
+	if got, ok := Compile("[").SubmatchIndex("["); ok || got != nil {
+		t.Errorf("invalid pattern SubmatchIndex = %v, %v; want nil, false", got, ok)
+	}
+	var nilPattern *Pattern
+	if _, ok := nilPattern.Submatch(""); ok {
+		t.Error("a nil Pattern matched")
+	}
+}
+
+func TestSubmatchFold(t *testing.T) {
+	p := Compile("TENANT:*:Order:[a-z]")
+	got, ok := p.SubmatchFold("tenant:ACME:ORDER:K")
+	if want := []string{"ACME", "K"}; !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("SubmatchFold = %q, %v; want %q, true", got, ok, want)
+	}
+	if _, ok := p.Submatch("tenant:ACME:ORDER:K"); ok {
+		t.Error("case-sensitive Submatch matched folded input")
+	}
+	// Kelvin sign folds to k but is three bytes wide.
+	index, ok := Compile("*k*").SubmatchIndexFold("a\u212ab")
+	if want := []int{0, 1, 4, 5}; !ok || !reflect.DeepEqual(index, want) {
+		t.Errorf("SubmatchIndexFold = %v, %v; want %v, true", index, ok, want)
+	}
+}
+
+func TestSubmatchAgreesWithMatch(t *testing.T) {
+	check := func(str, pattern string) {
+		p := Compile(pattern)
+		if _, ok := p.Submatch(str); ok != p.Match(str) {
+			t.Errorf("Compile(%q).Submatch(%q) ok = %v, Match = %v", pattern, str, ok, !ok)
+		}
+		if _, ok := p.SubmatchFold(str); ok != p.MatchFold(str) {
+			t.Errorf("Compile(%q).SubmatchFold(%q) ok = %v, MatchFold = %v", pattern, str, ok, !ok)
+		}
+	}
+	for _, tt := range allMatchCases() {
+		check(tt.args.str, tt.args.pattern)
+	}
+	for _, tt := range foldTests {
+		check(tt.str, tt.pattern)
+		check(strings.ToUpper(tt.str), tt.pattern)
+	}
+}
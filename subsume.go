@@ -0,0 +1,476 @@
+package redglob
+
+import (
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Subsumes reports whether every string matched by b is also matched by a,
+// so that a rule for a makes a rule for b redundant. For example, "cache:*"
+// subsumes "cache:user:*" and "cache:[0-9]". A nil or invalid Pattern
+// matches nothing, so it is subsumed by every pattern.
+//
+// The analysis is exact for case-sensitive matching of valid UTF-8 strings,
+// and covers brace groups and Separator patterns. Patterns compiled in
+// ModeRedisBytes are compared byte for byte, as Redis matches them, but only
+// with each other: if exactly one of a and b is in ModeRedisBytes, and the
+// other matches something, Subsumes reports false.
+func Subsumes(a, b *Pattern) bool {
+	na, nb, ok := automata(a, b)
+	if !ok {
+		return false
+	}
+	return subsumes(na, nb)
+}
+
+// Overlaps reports whether some string is matched by both a and b, and
+// returns the shortest such string as an example. Like Subsumes, it reports
+// false if exactly one of a and b is compiled in ModeRedisBytes and the
+// other matches something.
+func Overlaps(a, b *Pattern) (bool, string) {
+	na, nb, ok := automata(a, b)
+	if !ok {
+		return false, ""
+	}
+	return overlaps(na, nb)
+}
+
+// Equivalent reports whether a and b match exactly the same strings. Like
+// Subsumes, it reports false if exactly one of a and b is compiled in
+// ModeRedisBytes and the other matches something.
+func Equivalent(a, b *Pattern) bool {
+	na, nb, ok := automata(a, b)
+	if !ok {
+		return false
+	}
+	return subsumes(na, nb) && subsumes(nb, na)
+}
+
+// neverClass is a class that matches no rune. It stands in for invalid
+// UTF-8 in a literal run, which no valid string can match.
+var neverClass = &compiledClass{}
+
+// automaton is a pattern as a nondeterministic automaton for the analysis,
+// in the instruction format of a brace program: every instRune consumes one
+// rune, the others none.
+//
+// For a Separator pattern sep is set, and only a literal sep consumes it.
+// For ModeRedisBytes, bytes is set and the program consumes bytes, written
+// as the runes 0 to 255; nonEmpty records that, as in Redis, the empty
+// string does not match even if the program accepts it.
+type automaton struct {
+	prog     []inst
+	sep      rune
+	bytes    bool
+	nonEmpty bool
+	never    bool // the pattern matches nothing
+}
+
+// automata returns the automata of a and b, and false if they cannot be
+// compared because only one of them matches bytes.
+func automata(a, b *Pattern) (na, nb *automaton, ok bool) {
+	na, nb = a.automaton(), b.automaton()
+	switch {
+	case na.never:
+		na.bytes = nb.bytes
+	case nb.never:
+		nb.bytes = na.bytes
+	}
+	return na, nb, na.bytes == nb.bytes
+}
+
+func (p *Pattern) automaton() *automaton {
+	switch {
+	case p == nil || !p.valid || p.mode == ModeRedisBytes && p.redisDeep:
+		return &automaton{
+			prog:  []inst{{op: instRune, tok: &token{kind: tokenClass, class: neverClass}}, {op: instMatch}},
+			never: true,
+		}
+	case p.mode == ModeRedisBytes:
+		return p.redisAutomaton()
+	case p.segments != nil:
+		return p.segmentsAutomaton()
+	}
+	prog := compileProgram(nil, analysisTokens(p.tokenStream()))
+	return &automaton{prog: append(prog, inst{op: instMatch})}
+}
+
+// analysisTokens returns tokens with every literal run split into single
+// runes, and invalid UTF-8 in a run replaced by neverClass, so that
+// compileProgram does not turn it into U+FFFD.
+func analysisTokens(tokens []token) []token {
+	var out []token
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenLiteralRun:
+			for lit := t.lit; len(lit) > 0; {
+				char, size := decodeRune(lit)
+				if char == utf8.RuneError && size == 1 {
+					out = append(out, token{kind: tokenClass, class: neverClass})
+				} else {
+					out = append(out, token{kind: tokenLiteral, char: char})
+				}
+				lit = lit[size:]
+			}
+		case tokenAlt:
+			alts := make([][]token, len(t.alt.alts))
+			for i, alt := range t.alt.alts {
+				alts[i] = analysisTokens(alt)
+			}
+			out = append(out, token{kind: tokenAlt, alt: &alternation{alts: alts}})
+		default:
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// segmentsAutomaton is the automaton of a Separator pattern.
+func (p *Pattern) segmentsAutomaton() *automaton {
+	return &automaton{prog: p.segmentsProgram(analysisTokens), sep: p.separator}
+}
+
+// redisAutomaton is the automaton of a ModeRedisBytes pattern, over bytes.
+func (p *Pattern) redisAutomaton() *automaton {
+	var tokens []token
+	for _, t := range p.redis {
+		switch t.kind {
+		case tokenLiteralRun:
+			for i := range len(t.lit) {
+				tokens = append(tokens, token{kind: tokenLiteral, char: rune(t.lit[i])})
+			}
+		case tokenAnyN:
+			for range t.count {
+				tokens = append(tokens, token{kind: tokenAny})
+			}
+		case tokenStar:
+			tokens = append(tokens, token{kind: tokenStar})
+		case tokenClass:
+			class := &compiledClass{}
+			for c := range 256 {
+				if t.class[0].contains(byte(c)) {
+					class.addRange(charRange{rune(c), rune(c)})
+					addClassRangeBits(class, rune(c), rune(c))
+				}
+			}
+			tokens = append(tokens, token{kind: tokenClass, class: class})
+		}
+	}
+	prog := compileProgram(nil, tokens)
+	return &automaton{
+		prog:     append(prog, inst{op: instMatch}),
+		bytes:    true,
+		nonEmpty: len(p.redis) > 0,
+	}
+}
+
+// matches reports whether the instRune in consumes char.
+func (a *automaton) matches(in *inst, char rune) bool {
+	if a.sep != 0 && (char == a.sep) != (in.tok.kind == tokenLiteral && in.tok.char == a.sep) {
+		return false
+	}
+	return analysisMatches(in.tok, char)
+}
+
+// analysisMatches reports whether t consumes char.
+func analysisMatches(t *token, char rune) bool {
+	switch t.kind {
+	case tokenLiteral:
+		return t.char == char
+	case tokenAny:
+		return true
+	case tokenClass:
+		return t.class.contains(char) != t.class.negated
+	default:
+		return false
+	}
+}
+
+// closure adds to set pc and every instruction reachable from it without
+// consuming input. Only instRune and instMatch are recorded in set; seen
+// holds the instructions visited.
+func (a *automaton) closure(pc int, set, seen []bool) {
+	if seen[pc] {
+		return
+	}
+	seen[pc] = true
+	switch in := &a.prog[pc]; in.op {
+	case instSplit:
+		a.closure(in.x, set, seen)
+		a.closure(in.y, set, seen)
+	case instJump:
+		a.closure(in.x, set, seen)
+	default:
+		set[pc] = true
+	}
+}
+
+// start returns the instructions a is in before consuming input.
+func (a *automaton) start() []bool {
+	set := make([]bool, len(a.prog))
+	a.closure(0, set, make([]bool, len(a.prog)))
+	return set
+}
+
+// step returns the instructions a is in after consuming char from set.
+func (a *automaton) step(set []bool, char rune) []bool {
+	next := make([]bool, len(a.prog))
+	seen := make([]bool, len(a.prog))
+	for pc, in := range set {
+		if in && a.prog[pc].op == instRune && a.matches(&a.prog[pc], char) {
+			a.closure(pc+1, next, seen)
+		}
+	}
+	return next
+}
+
+// accepts reports whether set contains the end of the program, after
+// consuming input if consumed is set.
+func (a *automaton) accepts(set []bool, consumed bool) bool {
+	return set[len(a.prog)-1] && (consumed || !a.nonEmpty)
+}
+
+// alphabet returns one sample rune per interval of partition.
+func alphabet(automata ...*automaton) []rune {
+	var samples []rune
+	for _, r := range partition(nil, automata...) {
+		samples = append(samples, sampleRune(r.start, r.end+1))
+	}
+	return samples
+}
+
+// partition splits the runes into intervals on which every instruction of
+// the given automata behaves the same, splitting also before each rune in
+// bounds. Byte automata only cover the runes 0 to 255, and the others skip
+// the surrogates.
+func partition(bounds []rune, automata ...*automaton) []charRange {
+	bounds = append(bounds, 0, 0xd800, 0xe000, unicode.MaxRune+1)
+	limit := rune(unicode.MaxRune)
+	if automata[0].bytes {
+		bounds, limit = append(bounds, 256), 255
+	}
+	for _, a := range automata {
+		if a.sep != 0 {
+			bounds = append(bounds, a.sep, a.sep+1)
+		}
+		for _, in := range a.prog {
+			if in.op != instRune {
+				continue
+			}
+			switch t := in.tok; t.kind {
+			case tokenLiteral:
+				bounds = append(bounds, t.char, t.char+1)
+			case tokenClass:
+				for _, r := range t.class.rangeList() {
+					bounds = append(bounds, r.start, r.end+1)
+				}
+			}
+		}
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	var intervals []charRange
+	for i := 0; i+1 < len(bounds); i++ {
+		lo, hi := bounds[i], bounds[i+1]
+		if lo >= 0xd800 && lo < 0xe000 || lo > limit {
+			continue
+		}
+		intervals = append(intervals, charRange{lo, hi - 1})
+	}
+	return intervals
+}
+
+// sampleRune picks a readable rune from [lo, hi) for example strings.
+func sampleRune(lo, hi rune) rune {
+	for _, r := range []rune{'a', 'z', '0', 'A', 'Z', '_'} {
+		if lo <= r && r < hi {
+			return r
+		}
+	}
+	for r := lo; r < hi && r < lo+256; r++ {
+		if unicode.IsGraphic(r) && r != ' ' {
+			return r
+		}
+	}
+	return lo
+}
+
+// viable reports, for each instruction of a, whether the end of the program
+// can be reached from it. An instruction that consumes none of the sample
+// runes, such as the class "[]", is a dead end.
+func (a *automaton) viable(samples []rune) []bool {
+	ok := make([]bool, len(a.prog))
+	for changed := true; changed; {
+		changed = false
+		for pc := len(a.prog) - 1; pc >= 0; pc-- {
+			if ok[pc] {
+				continue
+			}
+			switch in := &a.prog[pc]; in.op {
+			case instMatch:
+				ok[pc] = true
+			case instSplit:
+				ok[pc] = ok[in.x] || ok[in.y]
+			case instJump:
+				ok[pc] = ok[in.x]
+			case instRune:
+				ok[pc] = ok[pc+1] && slices.ContainsFunc(samples, func(char rune) bool {
+					return a.matches(in, char)
+				})
+			}
+			changed = changed || ok[pc]
+		}
+	}
+	return ok
+}
+
+// subsumes reports whether the language of b is contained in that of a.
+// It walks b's automaton in lockstep with the determinized automaton of a,
+// looking for a string that b accepts and a rejects.
+func subsumes(a, b *automaton) bool {
+	samples := alphabet(a, b)
+	live := b.viable(samples)
+	type state struct {
+		pc       int    // instruction of b
+		set      string // instructions of a, one byte each
+		consumed bool
+	}
+	encode := func(set []bool) string {
+		buf := make([]byte, len(set))
+		for i, in := range set {
+			if in {
+				buf[i] = 1
+			}
+		}
+		return string(buf)
+	}
+	decode := func(set string) []bool {
+		out := make([]bool, len(set))
+		for i := range set {
+			out[i] = set[i] == 1
+		}
+		return out
+	}
+	seen := make(map[state]bool)
+	var queue []state
+	push := func(pcs, set []bool, consumed bool) {
+		key := encode(set)
+		for pc, in := range pcs {
+			s := state{pc, key, consumed}
+			if in && live[pc] && !seen[s] {
+				seen[s] = true
+				queue = append(queue, s)
+			}
+		}
+	}
+	push(b.start(), a.start(), false)
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		set := decode(s.set)
+		if b.prog[s.pc].op == instMatch {
+			if (s.consumed || !b.nonEmpty) && !a.accepts(set, s.consumed) {
+				return false
+			}
+			continue
+		}
+		if !slices.Contains(set, true) {
+			// a rejects every extension, and b still reaches its end.
+			return false
+		}
+		in := &b.prog[s.pc]
+		for _, char := range samples {
+			if !b.matches(in, char) {
+				continue
+			}
+			next := make([]bool, len(b.prog))
+			b.closure(s.pc+1, next, make([]bool, len(b.prog)))
+			push(next, a.step(set, char), true)
+		}
+	}
+	return true
+}
+
+// overlaps searches the product of the automata of a and b breadth-first,
+// so the example it returns is a shortest common match. The states reached
+// by the same string are kept together and expanded in rune order, so the
+// example is also the first of the shortest in byte order.
+func overlaps(a, b *automaton) (bool, string) {
+	samples := alphabet(a, b)
+	width := len(b.prog)
+	// A state is a pair of instructions, doubled to tell the start states,
+	// which have consumed nothing, from the others when the empty string
+	// needs telling apart.
+	type step struct {
+		prev int
+		char rune // -1 for a start state
+	}
+	parent := make(map[int]step)
+	accept := (len(a.prog)-1)*width + len(b.prog) - 1
+	found := -1
+	// visit appends to group the pairs of setA and setB not seen before.
+	visit := func(group []int, setA, setB []bool, from int, char rune) []int {
+		consumed := 0
+		if char >= 0 && (a.nonEmpty || b.nonEmpty) {
+			consumed = 1
+		}
+		for i, inA := range setA {
+			for j, inB := range setB {
+				s := (i*width+j)*2 + consumed
+				if !inA || !inB || found >= 0 {
+					continue
+				}
+				if _, ok := parent[s]; ok {
+					continue
+				}
+				parent[s] = step{from, char}
+				group = append(group, s)
+				if s/2 == accept && (consumed == 1 || !a.nonEmpty && !b.nonEmpty) {
+					found = s
+				}
+			}
+		}
+		return group
+	}
+	layer := [][]int{visit(nil, a.start(), b.start(), -1, -1)}
+	for found < 0 && len(layer) > 0 {
+		var nextLayer [][]int
+		for _, group := range layer {
+			for _, char := range samples {
+				var next []int
+				for _, s := range group {
+					i, j := s/2/width, s/2%width
+					inA, inB := &a.prog[i], &b.prog[j]
+					if inA.op != instRune || inB.op != instRune || !a.matches(inA, char) || !b.matches(inB, char) {
+						continue
+					}
+					setA, setB := make([]bool, len(a.prog)), make([]bool, len(b.prog))
+					a.closure(i+1, setA, make([]bool, len(a.prog)))
+					b.closure(j+1, setB, make([]bool, len(b.prog)))
+					next = visit(next, setA, setB, s, char)
+				}
+				if len(next) > 0 {
+					nextLayer = append(nextLayer, next)
+				}
+			}
+		}
+		layer = nextLayer
+	}
+	if found < 0 {
+		return false, ""
+	}
+	var example []rune
+	for s := found; parent[s].char >= 0; s = parent[s].prev {
+		example = append(example, parent[s].char)
+	}
+	slices.Reverse(example)
+	if a.bytes {
+		buf := make([]byte, len(example))
+		for i, char := range example {
+			buf[i] = byte(char)
+		}
+		return true, string(buf)
+	}
+	return true, string(example)
+}
@@ -0,0 +1,256 @@
+package redglob
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestSubsumes(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"cache:*", "cache:user:*", true},
+		{"cache:user:*", "cache:*", false},
+		{"cache:*", "cache:[0-9]", true},
+		{"*", "", true},
+		{"*", "[^a]", true},
+		{"?*", "*", false},
+		{"?*", "a*", true},
+		{"*a*", "*ab*", true},
+		{"*ab*", "*a*b*", false},
+		{"[a-z]", "[b-c]", true},
+		{"[a-z]", "[^0-9]", false},
+		{"[^0-9]", "[a-z]", true},
+		{"[^a-c]", "[^a-b]", false},
+		{"[^a-b]", "[^a-c]", true},
+		{"a?c", "a[bx]c", true},
+		{"???", "*", false},
+		{"**", "*", true},
+		{"a*", "[]", true},
+		{"a*", "a[b", true},
+		{"a[b", "a", false},
+		{"a*b*c", "a*c*b*c", true},
+	}
+	for _, tt := range tests {
+		if got := Subsumes(Compile(tt.a), Compile(tt.b)); got != tt.want {
+			t.Errorf("Subsumes(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    bool
+		example string
+	}{
+		{"cache:*", "*:user", true, "cache:user"},
+		{"user:[0-9]*", "user:a*", false, ""},
+		{"[^a]", "[a]", false, ""},
+		{"[^a]", "[a-b]", true, "b"},
+		{"*", "", true, ""},
+		{"a?", "?b", true, "ab"},
+		{"a*", "b*", false, ""},
+		{"*x*y*", "*y*x*", true, "xyx"},
+		{"[]", "*", false, ""},
+	}
+	for _, tt := range tests {
+		got, example := Overlaps(Compile(tt.a), Compile(tt.b))
+		if got != tt.want || example != tt.example {
+			t.Errorf("Overlaps(%q, %q) = %v, %q, want %v, %q", tt.a, tt.b, got, example, tt.want, tt.example)
+		}
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"a**b", "a*b", true},
+		{"*?", "?*", true},
+		{"[a-c]", "[cba]", true},
+		{"[c-a]", "[a-c]", true},
+		{`\a`, "a", true},
+		{"a*", "a?*", false},
+		{"[]", "a[b", true},
+	}
+	for _, tt := range tests {
+		if got := Equivalent(Compile(tt.a), Compile(tt.b)); got != tt.want {
+			t.Errorf("Equivalent(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAnalysisRedisMode(t *testing.T) {
+	redis := func(pattern string) *Pattern {
+		return compileRedisMode(t, pattern)
+	}
+	if !Subsumes(redis("a*"), redis("a[bc]")) || Subsumes(redis("a[bc]"), redis("a*")) {
+		t.Error(`Subsumes("a*", "a[bc]") in ModeRedisBytes is wrong`)
+	}
+	// '?' is one byte, so "??" matches every two-byte string, such as "é".
+	if Equivalent(redis("??"), redis("[^x]?")) {
+		t.Error("Equivalent ignored a byte class")
+	}
+	if !Subsumes(redis("??"), redis("é")) {
+		t.Error(`Subsumes("??", "é") = false in ModeRedisBytes`)
+	}
+	// "*" does not match the empty string, which "" does.
+	if Subsumes(redis("*"), redis("")) || !Subsumes(redis("*"), redis("?*")) || !Equivalent(redis("*"), redis("?*")) {
+		t.Error("the empty string quirk is not analyzed")
+	}
+	if ok, example := Overlaps(redis("*"), redis("*")); !ok || example != "a" {
+		t.Errorf(`Overlaps("*", "*") = %v, %q; want true, "a"`, ok, example)
+	}
+	if ok, example := Overlaps(redis("[\xff]*"), redis("?b")); !ok || example != "\xffb" {
+		t.Errorf("Overlaps = %v, %q; want true, %q", ok, example, "\xffb")
+	}
+	deep := redis(strings.Repeat("a*", 1002))
+	if !Subsumes(redis("x"), deep) || Subsumes(deep, redis("a")) {
+		t.Error("a pattern beyond the nesting limit should match nothing")
+	}
+	// A Unicode and a ModeRedisBytes pattern are not compared.
+	if Subsumes(redis("*"), Compile("a")) || Equivalent(redis("a"), Compile("a")) {
+		t.Error("mixed modes should report false")
+	}
+	if ok, _ := Overlaps(redis("a*"), Compile("*")); ok {
+		t.Error("mixed modes should report false")
+	}
+	if !Subsumes(redis("a*"), nil) || !Subsumes(redis("a*"), Compile("[")) {
+		t.Error("a pattern that matches nothing is subsumed by every pattern")
+	}
+}
+
+func TestAnalysisNil(t *testing.T) {
+	var p *Pattern
+	if !Subsumes(Compile("a"), p) || Subsumes(p, Compile("a")) || !Equivalent(p, Compile("[")) {
+		t.Error("a nil Pattern should match nothing")
+	}
+	if ok, _ := Overlaps(p, Compile("*")); ok {
+		t.Error("Overlaps(nil, \"*\") = true")
+	}
+}
+
+// TestAnalysisRandom checks the analysis against Match on every string up to
+// a small length.
+func TestAnalysisRandom(t *testing.T) {
+	const patternAlphabet = "ab*?[]^-\\é"
+	r := rand.New(rand.NewPCG(3, 4))
+	patternRunes := []rune(patternAlphabet)
+	randomPattern := func() string {
+		var b strings.Builder
+		for range r.IntN(5) {
+			b.WriteRune(patternRunes[r.IntN(len(patternRunes))])
+		}
+		return b.String()
+	}
+	strs := []string{""}
+	for prev, n := strs, 0; n < 4; n++ {
+		var next []string
+		for _, s := range prev {
+			for _, c := range []rune(patternAlphabet + "cz") {
+				next = append(next, s+string(c))
+			}
+		}
+		strs = append(strs, next...)
+		prev = next
+	}
+	for range 1000 {
+		pa, pb := randomPattern(), randomPattern()
+		a, b := Compile(pa), Compile(pb)
+		subsumed, counter := true, ""
+		overlap := false
+		for _, s := range strs {
+			if b.Match(s) && !a.Match(s) && subsumed {
+				subsumed, counter = false, s
+			}
+			overlap = overlap || a.Match(s) && b.Match(s)
+		}
+		if got := Subsumes(a, b); got != subsumed {
+			t.Fatalf("Subsumes(%q, %q) = %v, counterexample %q", pa, pb, got, counter)
+		}
+		got, example := Overlaps(a, b)
+		if got != overlap && (overlap || !a.Match(example)) {
+			t.Fatalf("Overlaps(%q, %q) = %v, %q", pa, pb, got, example)
+		}
+		if got && (!a.Match(example) || !b.Match(example)) {
+			t.Fatalf("Overlaps(%q, %q) example %q does not match both", pa, pb, example)
+		}
+	}
+}
+
+// TestAnalysisOptions is TestAnalysisRandom for brace, Separator and
+// ModeRedisBytes patterns.
+func TestAnalysisOptions(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	random := func(alphabet string) string {
+		var b strings.Builder
+		for range r.IntN(5) {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+	// strings returns every string of up to five bytes from alphabet, or
+	// four for a large one, which is enough for a counterexample to most
+	// pairs of four-byte patterns.
+	strings := func(alphabet string) []string {
+		strs := []string{""}
+		limit := 5
+		if len(alphabet) > 6 {
+			limit = 4
+		}
+		for prev, n := strs, 0; n < limit; n++ {
+			var next []string
+			for _, s := range prev {
+				for i := range len(alphabet) {
+					next = append(next, s+alphabet[i:i+1])
+				}
+			}
+			strs = append(strs, next...)
+			prev = next
+		}
+		return strs
+	}
+	options := []struct {
+		opts              CompileOptions
+		alphabet, strings string
+	}{
+		{CompileOptions{Braces: true}, "ab*?{,}", "ab{},"},
+		{CompileOptions{Separator: '/'}, "ab/*?", "ab/"},
+		{CompileOptions{Separator: '/', Braces: true}, "ab/*{,}", "ab/{},"},
+		{CompileOptions{Mode: ModeRedisBytes}, "ab*?[^]\\\xff", "ab*?[^]\\\xff"},
+	}
+	for _, o := range options {
+		strs := strings(o.strings)
+		for range 500 {
+			pa, pb := random(o.alphabet), random(o.alphabet)
+			a, errA := o.opts.Compile(pa)
+			b, errB := o.opts.Compile(pb)
+			if errA != nil || errB != nil {
+				continue
+			}
+			subsumed, equivalent, overlap := true, true, false
+			for _, s := range strs {
+				subsumed = subsumed && (!b.Match(s) || a.Match(s))
+				equivalent = equivalent && a.Match(s) == b.Match(s)
+				overlap = overlap || a.Match(s) && b.Match(s)
+			}
+			if got := Subsumes(a, b); got != subsumed {
+				t.Fatalf("%+v: Subsumes(%q, %q) = %v", o.opts, pa, pb, got)
+			}
+			if got := Equivalent(a, b); got != equivalent {
+				t.Fatalf("%+v: Equivalent(%q, %q) = %v", o.opts, pa, pb, got)
+			}
+			got, example := Overlaps(a, b)
+			if got != overlap && (overlap || !a.Match(example)) {
+				t.Fatalf("%+v: Overlaps(%q, %q) = %v, %q", o.opts, pa, pb, got, example)
+			}
+			if got && (!a.Match(example) || !b.Match(example)) {
+				t.Fatalf("%+v: Overlaps(%q, %q) example %q does not match both", o.opts, pa, pb, example)
+			}
+		}
+	}
+}